@@ -0,0 +1,74 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// apiServicesPath is the path of the APIService objects registering aggregated APIs.
+const apiServicesPath = "/apis/apiregistration.k8s.io/v1beta1/apiservices"
+
+// aggregatedAPIError explains err when it was caused by the extension API server behind
+// gv being unavailable, rather than leaving a bare 503 that looks like a failure of this
+// tool. Other errors, and errors from APIs served by the API server itself, are
+// returned unchanged.
+func aggregatedAPIError(gv schema.GroupVersion, err error) error {
+	if err == nil || !apierrors.IsServiceUnavailable(err) {
+		return err
+	}
+	return discoveryFailedError(gv, err)
+}
+
+// discoveryFailedError explains err, a failure to discover or reach the resources of gv,
+// in terms of the APIService backing gv when it is an aggregated API.
+func discoveryFailedError(gv schema.GroupVersion, err error) error {
+	apiService := gv.Version + "." + gv.Group
+	service, found := apiServiceBackend(apiService)
+	if !found {
+		return err
+	}
+	logRawError(err)
+	return withExitCode(
+		fmt.Errorf("the %s API is unavailable: APIService %s is backed by service %s, which is not responding: %v", gv, apiService, service, err),
+		exitUnreachable,
+	)
+}
+
+// apiServiceBackend returns the namespace/name of the service backing the named
+// APIService. It returns false if the APIService is served by the API server itself, or
+// can't be read.
+func apiServiceBackend(name string) (string, bool) {
+	raw, err := kubeClient.CoreV1().RESTClient().Get().AbsPath(apiServicesPath, name).DoRaw()
+	if err != nil {
+		namedLogger("discovery").Debug("failed to get APIService", zap.String("name", name), zap.Error(err))
+		return "", false
+	}
+	apiService := &unstructured.Unstructured{}
+	if err := apiService.UnmarshalJSON(raw); err != nil {
+		namedLogger("discovery").Debug("failed to decode APIService", zap.String("name", name), zap.Error(err))
+		return "", false
+	}
+	service, found, err := unstructured.NestedStringMap(apiService.Object, "spec", "service")
+	if err != nil || !found {
+		return "", false
+	}
+	return service["namespace"] + "/" + service["name"], true
+}
@@ -0,0 +1,201 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jimmidyson/kube-client-template/pkg/output"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/discovery"
+)
+
+var (
+	apiResourcesAPIGroup   string
+	apiResourcesNamespaced bool
+	apiResourcesVerbs      []string
+	apiResourcesOutput     string
+	apiResourcesSortBy     string
+	apiResourcesNoHeaders  bool
+)
+
+// apiResourcesCmd represents the api-resources command
+var apiResourcesCmd = &cobra.Command{
+	Use:   "api-resources",
+	Short: "Print the supported API resources on the server",
+	Long: `Print the supported API resources on the server.
+
+Resources can be filtered by API group, scope and supported verbs, which
+is useful when generating RBAC rules from discovered resources. For example:
+
+  kube-client-template api-resources --namespaced=false --verbs=list,watch
+
+When some API groups can't be discovered, e.g. as an aggregated API is
+unavailable, their resources are missing. Pass --show-discovery-errors to log
+why each failed, or use --output json, which lists them under
+discoveryErrors.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch apiResourcesOutput {
+		case "", "wide", "name", "json":
+		default:
+			return fmt.Errorf("invalid output format %q: must be one of wide, name or json", apiResourcesOutput)
+		}
+		switch apiResourcesSortBy {
+		case "name", "kind":
+		default:
+			return fmt.Errorf("invalid sort field %q: must be one of name or kind", apiResourcesSortBy)
+		}
+
+		lists, discoveryErr := discoveryClient.ServerPreferredResources()
+		if discoveryErr != nil {
+			if !discovery.IsGroupDiscoveryFailedError(discoveryErr) {
+				return discoveryErr
+			}
+			logDiscoveryFailure(discoveryErr)
+		}
+
+		filterNamespaced := cmd.Flags().Changed("namespaced")
+		verbs := sets.NewString(apiResourcesVerbs...)
+
+		var resources []apiResource
+		for _, list := range lists {
+			gv, err := schema.ParseGroupVersion(list.GroupVersion)
+			if err != nil {
+				logger.Debug("skipping unparseable group version", zap.String("groupVersion", list.GroupVersion), zap.Error(err))
+				continue
+			}
+			if cmd.Flags().Changed("api-group") && gv.Group != apiResourcesAPIGroup {
+				continue
+			}
+			for _, r := range list.APIResources {
+				// Subresources such as pods/log are not listed on their own.
+				if strings.Contains(r.Name, "/") {
+					continue
+				}
+				if filterNamespaced && r.Namespaced != apiResourcesNamespaced {
+					continue
+				}
+				if verbs.Len() > 0 && !sets.NewString(r.Verbs...).HasAll(verbs.List()...) {
+					continue
+				}
+				resources = append(resources, apiResource{group: gv.Group, APIResource: r})
+			}
+		}
+
+		sort.SliceStable(resources, func(i, j int) bool {
+			a, b := resources[i], resources[j]
+			if apiResourcesSortBy == "kind" && a.Kind != b.Kind {
+				return a.Kind < b.Kind
+			}
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+			return a.group < b.group
+		})
+
+		if apiResourcesOutput == "json" {
+			return printAPIResourcesJSON(os.Stdout, resources, discoveryErrors(discoveryErr))
+		}
+		defer pageOutput(true)()
+		return printAPIResources(os.Stdout, resources)
+	},
+}
+
+// apiResource is a discovered API resource along with the group it was discovered in.
+type apiResource struct {
+	group string
+	metav1.APIResource
+}
+
+// apiResourcesDocument is the JSON output of api-resources. The group versions that failed
+// discovery are included, as their resources are missing.
+type apiResourcesDocument struct {
+	Resources       []apiResourceJSON `json:"resources"`
+	DiscoveryErrors []discoveryError  `json:"discoveryErrors"`
+}
+
+type apiResourceJSON struct {
+	Group string `json:"group"`
+	metav1.APIResource
+}
+
+func printAPIResourcesJSON(out io.Writer, resources []apiResource, discoveryErrs []discoveryError) error {
+	doc := apiResourcesDocument{Resources: []apiResourceJSON{}, DiscoveryErrors: discoveryErrs}
+	if doc.DiscoveryErrors == nil {
+		doc.DiscoveryErrors = []discoveryError{}
+	}
+	for _, r := range resources {
+		doc.Resources = append(doc.Resources, apiResourceJSON{Group: r.group, APIResource: r.APIResource})
+	}
+	data, err := json.MarshalIndent(doc, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, string(data))
+	return err
+}
+
+func printAPIResources(out io.Writer, resources []apiResource) error {
+	printer, err := output.PrinterFor(withTableStyle(output.Flags{NoHeaders: apiResourcesNoHeaders || apiResourcesOutput == "name"}))
+	if err != nil {
+		return err
+	}
+
+	table := &output.Table{Columns: []string{"NAME", "SHORTNAMES", "APIGROUP", "NAMESPACED", "KIND"}}
+	if apiResourcesOutput == "wide" {
+		table.Columns = append(table.Columns, "VERBS")
+	}
+	if apiResourcesOutput == "name" {
+		table.Columns = []string{"NAME"}
+	}
+	for _, r := range resources {
+		if apiResourcesOutput == "name" {
+			name := r.Name
+			if r.group != "" {
+				name += "." + r.group
+			}
+			table.Rows = append(table.Rows, []string{name})
+			continue
+		}
+		row := []string{r.Name, strings.Join(r.ShortNames, ","), r.group, strconv.FormatBool(r.Namespaced), r.Kind}
+		if apiResourcesOutput == "wide" {
+			row = append(row, strings.Join(r.Verbs, ","))
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return printer.PrintObj(table, out)
+}
+
+func init() {
+	rootCmd.AddCommand(apiResourcesCmd)
+
+	apiResourcesCmd.Flags().StringVar(&apiResourcesAPIGroup, "api-group", "", "limit to resources in the specified API group")
+	apiResourcesCmd.Flags().BoolVar(&apiResourcesNamespaced, "namespaced", true, "if false, only cluster-scoped resources are returned, otherwise only namespaced resources")
+	apiResourcesCmd.Flags().StringSliceVar(&apiResourcesVerbs, "verbs", nil, "limit to resources that support all of the specified verbs")
+	apiResourcesCmd.Flags().StringVarP(&apiResourcesOutput, "output", "o", "", "output format, one of: wide|name|json, json includes the group versions that failed discovery")
+	apiResourcesCmd.Flags().StringVar(&apiResourcesSortBy, "sort-by", "name", "field to sort by, one of: name|kind")
+	apiResourcesCmd.Flags().BoolVar(&apiResourcesNoHeaders, "no-headers", false, "don't print headers")
+}
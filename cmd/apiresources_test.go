@@ -0,0 +1,32 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import "testing"
+
+// TestAPIResources checks that the resources of every discovered group are listed.
+func TestAPIResources(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	result := runCommand(t, server, "api-resources", "-o", "name")
+	if result.err != nil {
+		t.Fatalf("api-resources failed: %v", result.err)
+	}
+	want := "deployments.apps\nendpoints\nevents\npods\nservices\n"
+	if result.stdout != want {
+		t.Errorf("stdout = %q, want %q", result.stdout, want)
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jimmidyson/kube-client-template/pkg/output"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// lastAppliedAnnotation records the configuration an object was last applied with, so
+// that fields removed from the configuration can be removed from the object. It is the
+// annotation used by kubectl, so objects can be applied with either tool.
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+var (
+	applyFilenames []string
+	applyWait      bool
+	applyTimeout   time.Duration
+)
+
+// applyCmd represents the apply command
+var applyCmd = &cobra.Command{
+	Use:   "apply -f FILENAME",
+	Short: "Create or update resources from files or stdin",
+	Long: `Create or update resources from files or stdin.
+
+Objects that don't exist are created, and existing objects are updated with a
+merge patch. Fields removed from the configuration since it was last applied
+are removed from the object.
+
+With --wait, apply then waits for deployments, statefulsets and daemonsets to
+roll out, jobs to complete and pods to be ready, failing if any of them
+doesn't within --timeout. Other kinds are not waited for. For example:
+
+  kube-client-template apply -f manifests/ --wait --timeout=5m`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(applyFilenames) == 0 {
+			return errors.New("must specify at least one filename with -f")
+		}
+		objs, err := readManifests(applyFilenames)
+		if err != nil {
+			return err
+		}
+		if len(objs) == 0 {
+			return errors.New("no objects passed to apply")
+		}
+
+		var applied []appliedObject
+		for _, obj := range objs {
+			result, err := applyObject(obj)
+			if err != nil {
+				logRawError(err)
+				logger.Error("failed to apply object", append(errorFields(err),
+					zap.String("source", obj.source),
+					zap.String("kind", obj.GetKind()),
+					zap.String("name", obj.GetName()),
+				)...)
+				continue
+			}
+			applied = append(applied, result)
+		}
+		if failed := len(objs) - len(applied); failed > 0 {
+			return fmt.Errorf("failed to apply %d of %d objects", failed, len(objs))
+		}
+
+		if applyWait {
+			return waitForReady(applied, applyTimeout)
+		}
+		return nil
+	},
+}
+
+// appliedObject is an object that has been applied.
+type appliedObject struct {
+	mapping *meta.RESTMapping
+	obj     *unstructured.Unstructured
+	name    string
+}
+
+func applyObject(obj manifestObject) (appliedObject, error) {
+	mapping, client, err := objectClient(obj.Unstructured)
+	if err != nil {
+		return appliedObject{}, err
+	}
+	name, err := output.QualifiedName(obj.Unstructured)
+	if err != nil {
+		return appliedObject{}, err
+	}
+
+	modified, err := withLastApplied(obj.Unstructured)
+	if err != nil {
+		return appliedObject{}, err
+	}
+	live, err := client.Get(obj.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		created, err := client.Create(modified)
+		if err != nil {
+			return appliedObject{}, err
+		}
+		fmt.Fprintf(os.Stdout, "%s created\n", name)
+		return appliedObject{mapping: mapping, obj: created, name: name}, nil
+	}
+	if err != nil {
+		return appliedObject{}, err
+	}
+
+	patch := modified.DeepCopy().Object
+	if original := live.GetAnnotations()[lastAppliedAnnotation]; original != "" {
+		var originalObj map[string]interface{}
+		if err := json.Unmarshal([]byte(original), &originalObj); err != nil {
+			logger.Debug("ignoring unparseable last applied configuration", zap.String("name", name), zap.Error(err))
+		} else {
+			addDeletions(patch, originalObj)
+		}
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return appliedObject{}, err
+	}
+	patched, err := client.Patch(obj.GetName(), types.MergePatchType, data)
+	if err != nil {
+		return appliedObject{}, err
+	}
+	action := "configured"
+	if patched.GetResourceVersion() == live.GetResourceVersion() {
+		action = "unchanged"
+	}
+	fmt.Fprintf(os.Stdout, "%s %s\n", name, action)
+	return appliedObject{mapping: mapping, obj: patched, name: name}, nil
+}
+
+// withLastApplied returns a copy of obj annotated with its own configuration.
+func withLastApplied(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	configuration, err := obj.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	annotated := obj.DeepCopy()
+	annotations := annotated.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[lastAppliedAnnotation] = string(configuration)
+	annotated.SetAnnotations(annotations)
+	return annotated, nil
+}
+
+// addDeletions adds null values to the merge patch for every field of the original
+// configuration that is missing from patch, so that removed fields are deleted. Lists
+// are replaced as a whole by merge patches, so only maps are compared.
+func addDeletions(patch, original map[string]interface{}) {
+	for k, originalValue := range original {
+		patchValue, found := patch[k]
+		if !found {
+			patch[k] = nil
+			continue
+		}
+		originalMap, ok := originalValue.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if patchMap, ok := patchValue.(map[string]interface{}); ok {
+			addDeletions(patchMap, originalMap)
+		}
+	}
+}
+
+// waitForReady waits for each of objs that has a notion of readiness to become ready,
+// all within timeout. A timeout of zero waits forever.
+func waitForReady(objs []appliedObject, timeout time.Duration) error {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	var waited, failed int
+	for _, applied := range objs {
+		target := readinessTarget(applied.mapping, applied.obj.GetNamespace(), applied.obj.GetName(), applied.name)
+		if target == nil {
+			logger.Debug("not waiting for object without a readiness check", zap.String("name", applied.name))
+			continue
+		}
+		waited++
+
+		var remaining time.Duration
+		if !deadline.IsZero() {
+			if remaining = time.Until(deadline); remaining <= 0 {
+				remaining = time.Nanosecond
+			}
+		}
+		if err := waitForRollout(target, remaining); err != nil {
+			failed++
+			logRawError(err)
+			logger.Error("object did not become ready", append(errorFields(err), zap.String("name", applied.name))...)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d objects did not become ready", failed, waited)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+
+	applyCmd.Flags().StringSliceVarP(&applyFilenames, "filename", "f", nil, "files or directories containing the objects to apply, or - for stdin")
+	applyCmd.Flags().BoolVar(&applyWait, "wait", false, "wait for applied workloads, jobs and pods to become ready")
+	applyCmd.Flags().DurationVar(&applyTimeout, "timeout", 0, "how long to wait for all objects to become ready with --wait, zero means wait forever")
+}
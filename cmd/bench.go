@@ -0,0 +1,175 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jimmidyson/kube-client-template/pkg/kube"
+	"github.com/jimmidyson/kube-client-template/pkg/output"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	benchRequests    int
+	benchConcurrency int
+)
+
+// benchCmd represents the bench command
+var benchCmd = &cobra.Command{
+	Use:   "bench TYPE[/NAME] [NAME]",
+	Short: "Measure the latency of the API server",
+	Long: `Measure the latency of the API server by sending it many list requests, or
+get requests if a name is specified, and report the latency percentiles, error
+rate and achieved queries per second.
+
+Requests are subject to the client-side rate limits set by --kube-qps and
+--kube-burst, so the achieved QPS shows whether those limits are the
+bottleneck. Pass --disable-client-side-throttling to measure the API server
+alone. Failed requests are retried as configured by --retry-on, and the
+latency of a request includes its retries. For example:
+
+  kube-client-template bench pods --requests=500 --concurrency=20
+  kube-client-template bench configmap/kube-proxy --kubernetes-namespace=kube-system`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if benchRequests < 1 {
+			return errors.New("--requests must be at least 1")
+		}
+		if benchConcurrency < 1 {
+			return errors.New("--concurrency must be at least 1")
+		}
+		resourceArg, names, err := splitResourceArgs(args)
+		if err != nil {
+			return err
+		}
+		if len(names) > 1 {
+			return errors.New("only one name can be specified")
+		}
+
+		mapping, err := resourceMapping(resourceArg)
+		if err != nil {
+			return err
+		}
+		// Every request must reach the API server, rather than being served from the cache.
+		cacheTTL = 0
+		client, err := resourceClient(mapping, namespace)
+		if err != nil {
+			return err
+		}
+		verb, request := "list", func() error {
+			_, err := client.List(metav1.ListOptions{})
+			return err
+		}
+		if len(names) == 1 {
+			verb, request = "get", func() error {
+				_, err := client.Get(names[0], metav1.GetOptions{})
+				return err
+			}
+		}
+
+		result := runBench(request)
+		if result.errors > 0 {
+			logRawError(result.lastErr)
+			logger.Warn("requests failed", append(errorFields(result.lastErr), zap.Int("failed", result.errors))...)
+		}
+		return printBenchResult(verb, mapping.Resource, result)
+	},
+}
+
+// benchResult is the outcome of a benchmark.
+type benchResult struct {
+	latencies []time.Duration
+	errors    int
+	lastErr   error
+	elapsed   time.Duration
+}
+
+// runBench sends --requests requests with at most --concurrency in flight, recording
+// the latency of each. Unlike other commands, the pool isn't limited to --kube-burst,
+// as the rate limiter is part of what is measured.
+func runBench(request func() error) benchResult {
+	var (
+		result benchResult
+		mu     sync.Mutex
+	)
+	start := time.Now()
+	kube.NewPool(benchConcurrency).Run(benchRequests, func(int) error {
+		requestStart := time.Now()
+		err := request()
+		latency := time.Since(requestStart)
+
+		mu.Lock()
+		defer mu.Unlock()
+		result.latencies = append(result.latencies, latency)
+		if err != nil {
+			result.errors++
+			result.lastErr = err
+		}
+		return nil
+	})
+	result.elapsed = time.Since(start)
+	return result
+}
+
+func printBenchResult(verb, resource string, result benchResult) error {
+	sort.Slice(result.latencies, func(i, j int) bool { return result.latencies[i] < result.latencies[j] })
+	total := len(result.latencies)
+	table := &output.Table{
+		Columns: []string{"VERB", "RESOURCE", "REQUESTS", "ERRORS", "QPS", "P50", "P90", "P99"},
+		Rows: [][]string{{
+			verb,
+			resource,
+			fmt.Sprint(total),
+			fmt.Sprintf("%d (%.1f%%)", result.errors, 100*float64(result.errors)/float64(total)),
+			fmt.Sprintf("%.1f", float64(total)/result.elapsed.Seconds()),
+			formatLatency(percentile(result.latencies, 50)),
+			formatLatency(percentile(result.latencies, 90)),
+			formatLatency(percentile(result.latencies, 99)),
+		}},
+	}
+	printer, err := output.PrinterFor(withTableStyle(output.Flags{}))
+	if err != nil {
+		return err
+	}
+	return printer.PrintObj(table, os.Stdout)
+}
+
+// percentile returns the p-th percentile of sorted, using the nearest rank.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func formatLatency(d time.Duration) string {
+	return d.Round(100 * time.Microsecond).String()
+}
+
+func init() {
+	rootCmd.AddCommand(benchCmd)
+
+	benchCmd.Flags().IntVar(&benchRequests, "requests", 100, "the number of requests to send")
+	benchCmd.Flags().IntVar(&benchConcurrency, "concurrency", 10, "the maximum number of requests in flight at once")
+}
@@ -0,0 +1,185 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+var (
+	// cacheTTL is how long objects read by a command are reused by its later reads.
+	// Caching is off unless it is set, and is kept short when it is so that commands
+	// that poll still see changes.
+	cacheTTL time.Duration
+	objects  = &objectCache{entries: map[objectKey]cacheEntry{}}
+)
+
+// objectKey identifies an object in the cache.
+type objectKey struct {
+	resource  string
+	namespace string
+	name      string
+}
+
+type cacheEntry struct {
+	obj     *unstructured.Unstructured
+	expires time.Time
+}
+
+// objectCache holds the objects read in this invocation, so that commands reading the
+// same object more than once, e.g. to list and then describe it, only fetch it once.
+// It is emptied by every request that may change objects, see configureCacheInvalidation.
+type objectCache struct {
+	mu      sync.Mutex
+	entries map[objectKey]cacheEntry
+	// generation counts the times the cache has been emptied, so that objects read
+	// before a write that completes after it aren't added back.
+	generation uint64
+}
+
+// get returns the cached object for key. An object is returned after its TTL if it is
+// at the requested resourceVersion, as it can't have changed.
+func (c *objectCache) get(key objectKey, resourceVersion string) (*unstructured.Unstructured, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, found := c.entries[key]
+	if !found {
+		return nil, false
+	}
+	if resourceVersion != "" && resourceVersion != "0" {
+		if entry.obj.GetResourceVersion() != resourceVersion {
+			return nil, false
+		}
+	} else if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.obj.DeepCopy(), true
+}
+
+// currentGeneration returns the generation to add objects that are about to be read at.
+func (c *objectCache) currentGeneration() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// add adds obj, read at generation, unless the cache has been emptied since.
+func (c *objectCache) add(key objectKey, obj *unstructured.Unstructured, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		return
+	}
+	c.entries[key] = cacheEntry{obj: obj.DeepCopy(), expires: time.Now().Add(cacheTTL)}
+}
+
+// clear empties the cache.
+func (c *objectCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[objectKey]cacheEntry{}
+	c.generation++
+}
+
+// configureCacheInvalidation empties the object cache before and after every request of
+// config that may change objects, so that no write, whichever client sends it, is
+// followed by a read of what it changed from the cache.
+func configureCacheInvalidation(config *rest.Config) {
+	if cacheTTL <= 0 {
+		return
+	}
+	wrap := config.WrapTransport
+	config.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		if wrap != nil {
+			rt = wrap(rt)
+		}
+		return &invalidatingRoundTripper{delegate: rt}
+	}
+}
+
+// invalidatingRoundTripper empties the object cache around requests other than reads.
+type invalidatingRoundTripper struct {
+	delegate http.RoundTripper
+}
+
+func (rt *invalidatingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return rt.delegate.RoundTrip(req)
+	}
+	objects.clear()
+	defer objects.clear()
+	return rt.delegate.RoundTrip(req)
+}
+
+// cachingClient reads objects through the object cache, adding the objects it gets and
+// lists. Watches always go to the server, and writes empty the cache on their way to it.
+type cachingClient struct {
+	dynamic.ResourceInterface
+	resource  string
+	namespace string
+}
+
+// withCache wraps client so that its reads are cached, unless caching is disabled.
+func withCache(client dynamic.ResourceInterface, resource, namespace string) dynamic.ResourceInterface {
+	if cacheTTL <= 0 {
+		return client
+	}
+	return &cachingClient{ResourceInterface: client, resource: resource, namespace: namespace}
+}
+
+func (c *cachingClient) key(namespace, name string) objectKey {
+	return objectKey{resource: c.resource, namespace: namespace, name: name}
+}
+
+func (c *cachingClient) Get(name string, opts metav1.GetOptions) (*unstructured.Unstructured, error) {
+	key := c.key(c.namespace, name)
+	if obj, found := objects.get(key, opts.ResourceVersion); found {
+		logger.Debug("using cached object", zap.String("resource", c.resource), zap.String("name", name), zap.String("resourceVersion", obj.GetResourceVersion()))
+		return obj, nil
+	}
+	generation := objects.currentGeneration()
+	obj, err := c.ResourceInterface.Get(name, opts)
+	if err != nil {
+		return nil, err
+	}
+	objects.add(key, obj, generation)
+	return obj, nil
+}
+
+func (c *cachingClient) List(opts metav1.ListOptions) (runtime.Object, error) {
+	generation := objects.currentGeneration()
+	obj, err := c.ResourceInterface.List(opts)
+	if err != nil {
+		return nil, err
+	}
+	if list, ok := obj.(*unstructured.UnstructuredList); ok {
+		for i := range list.Items {
+			item := &list.Items[i]
+			objects.add(c.key(item.GetNamespace(), item.GetName()), item, generation)
+		}
+	}
+	return obj, nil
+}
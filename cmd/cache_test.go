@@ -0,0 +1,69 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// roundTripperFunc is an http.RoundTripper calling itself.
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestCacheIsInvalidatedByWrites(t *testing.T) {
+	defer func(ttl time.Duration) { cacheTTL = ttl }(cacheTTL)
+	cacheTTL = time.Minute
+
+	key := objectKey{resource: "pods", namespace: "default", name: "web"}
+	obj := &unstructured.Unstructured{}
+	obj.SetName("web")
+
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		t.Run(method, func(t *testing.T) {
+			objects.clear()
+			objects.add(key, obj, objects.currentGeneration())
+
+			// A read that started before the request completes during it.
+			var readDuring uint64
+			rt := &invalidatingRoundTripper{delegate: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				readDuring = objects.currentGeneration()
+				return &http.Response{StatusCode: http.StatusOK}, nil
+			})}
+			req, err := http.NewRequest(method, "https://example.com/api/v1/namespaces/default/pods/web", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := rt.RoundTrip(req); err != nil {
+				t.Fatalf("RoundTrip() failed: %v", err)
+			}
+			objects.add(objectKey{resource: "pods", namespace: "default", name: "api"}, obj, readDuring)
+
+			read := method == http.MethodGet || method == http.MethodHead
+			if _, found := objects.get(key, ""); found != read {
+				t.Errorf("object cached = %t after a %s, want %t", found, method, read)
+			}
+			if _, found := objects.get(objectKey{resource: "pods", namespace: "default", name: "api"}, ""); found != read {
+				t.Errorf("object read during a %s cached = %t, want %t", method, found, read)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jimmidyson/kube-client-template/pkg/output"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	authorizationv1 "k8s.io/api/authorization/v1"
+)
+
+// wildcard grants every API group, resource, name or verb in RBAC rules.
+const wildcard = "*"
+
+var (
+	canIList          bool
+	canIAllNamespaces bool
+)
+
+// canICmd represents the can-i command
+var canICmd = &cobra.Command{
+	Use:   "can-i VERB TYPE[/NAME] | --list",
+	Short: "Check what the current user is allowed to do",
+	Long: `Check whether the current user is allowed to perform an action, exiting 0 if
+they are and 1 if not.
+
+With --list, all the actions the user is allowed to perform in the namespace
+are printed, grouped by API group. Wildcards granting every group, resource
+or verb are shown as "* (all)". Rules can only be reviewed in a namespace, so
+--list can't be combined with --all-namespaces; the rules printed include
+those granted cluster-wide. For example:
+
+  kube-client-template can-i create deployments.apps
+  kube-client-template can-i delete pod/nginx
+  kube-client-template can-i --list --kubernetes-namespace=kube-system`,
+	PreRunE: checkFlagRules(canIFlagRules),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if canIList {
+			if len(args) > 0 {
+				return errors.New("--list cannot be combined with a verb and resource")
+			}
+			return listRules(namespace)
+		}
+		if len(args) != 2 {
+			return errors.New("must specify a verb and a resource, or --list")
+		}
+		ns := namespace
+		if canIAllNamespaces {
+			ns = ""
+		}
+		return checkAccess(args[0], args[1], ns)
+	},
+}
+
+// checkAccess prints whether the current user can perform verb on the resource in
+// TYPE[/NAME] form, returning an error causing exitFailure if they can't.
+func checkAccess(verb, ref, namespace string) error {
+	resourceArg, name := ref, ""
+	if i := strings.Index(ref, "/"); i >= 0 {
+		resourceArg, name = ref[:i], ref[i+1:]
+	}
+	mapping, err := resourceMapping(resourceArg)
+	if err != nil {
+		return err
+	}
+	if !isNamespaced(mapping) {
+		namespace = ""
+	}
+	review, err := kubeClient.AuthorizationV1().SelfSubjectAccessReviews().Create(&authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      verb,
+				Group:     mapping.GroupVersionKind.Group,
+				Resource:  mapping.Resource,
+				Name:      name,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if !review.Status.Allowed {
+		fmt.Fprintln(os.Stdout, "no")
+		return withExitCode(fmt.Errorf("not allowed to %s %s", verb, ref), exitFailure)
+	}
+	fmt.Fprintln(os.Stdout, "yes")
+	return nil
+}
+
+// listRules prints the rules of the current user in namespace, grouped by API group.
+func listRules(namespace string) error {
+	review, err := kubeClient.AuthorizationV1().SelfSubjectRulesReviews().Create(&authorizationv1.SelfSubjectRulesReview{
+		Spec: authorizationv1.SelfSubjectRulesReviewSpec{Namespace: namespace},
+	})
+	if err != nil {
+		return err
+	}
+	if review.Status.Incomplete {
+		logger.Warn("the list of rules may be incomplete, e.g. as the server uses an authorizer that can't list rules", zap.String("error", review.Status.EvaluationError))
+	}
+	defer pageOutput(true)()
+	return printRules(os.Stdout, review.Status.ResourceRules, review.Status.NonResourceRules)
+}
+
+// ruleKey identifies the rows of the rules table, whose verbs are merged.
+type ruleKey struct {
+	group         string
+	resource      string
+	resourceNames string
+}
+
+func printRules(out io.Writer, resourceRules []authorizationv1.ResourceRule, nonResourceRules []authorizationv1.NonResourceRule) error {
+	verbs := map[ruleKey]map[string]bool{}
+	for _, rule := range resourceRules {
+		names := strings.Join(rule.ResourceNames, ",")
+		for _, group := range rule.APIGroups {
+			for _, resource := range rule.Resources {
+				key := ruleKey{group: group, resource: resource, resourceNames: names}
+				if verbs[key] == nil {
+					verbs[key] = map[string]bool{}
+				}
+				for _, verb := range rule.Verbs {
+					verbs[key][verb] = true
+				}
+			}
+		}
+	}
+	var keys []ruleKey
+	for key := range verbs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].group != keys[j].group {
+			return keys[i].group < keys[j].group
+		}
+		if keys[i].resource != keys[j].resource {
+			return keys[i].resource < keys[j].resource
+		}
+		return keys[i].resourceNames < keys[j].resourceNames
+	})
+
+	table := &output.Table{Columns: []string{"APIGROUP", "RESOURCE", "RESOURCE-NAMES", "VERBS"}}
+	var lastGroup string
+	for i, key := range keys {
+		// The group is only printed on the first row of each group, so that groups stand out.
+		group := ""
+		if i == 0 || key.group != lastGroup {
+			group = formatRuleGroup(key.group)
+		}
+		lastGroup = key.group
+		table.Rows = append(table.Rows, []string{group, formatWildcard(key.resource), key.resourceNames, formatVerbs(verbs[key])})
+	}
+	for _, rule := range nonResourceRules {
+		for _, url := range rule.NonResourceURLs {
+			table.Rows = append(table.Rows, []string{"<non-resource>", formatWildcard(url), "", formatVerbs(setOf(rule.Verbs))})
+		}
+	}
+
+	printer, err := output.PrinterFor(withTableStyle(output.Flags{}))
+	if err != nil {
+		return err
+	}
+	return printer.PrintObj(table, out)
+}
+
+func formatRuleGroup(group string) string {
+	if group == "" {
+		return "core"
+	}
+	return formatWildcard(group)
+}
+
+// formatWildcard marks a wildcard, so that rules granting everything stand out.
+func formatWildcard(s string) string {
+	if s == wildcard {
+		return "* (all)"
+	}
+	return s
+}
+
+func formatVerbs(verbs map[string]bool) string {
+	if verbs[wildcard] {
+		return formatWildcard(wildcard)
+	}
+	var list []string
+	for verb := range verbs {
+		list = append(list, verb)
+	}
+	sort.Strings(list)
+	return strings.Join(list, ",")
+}
+
+func setOf(values []string) map[string]bool {
+	set := map[string]bool{}
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+func init() {
+	rootCmd.AddCommand(canICmd)
+
+	canICmd.Flags().BoolVar(&canIList, "list", false, "list all the actions the current user can perform, grouped by API group")
+	canICmd.Flags().BoolVar(&canIAllNamespaces, "all-namespaces", false, "check cluster-wide, rather than in the current namespace")
+}
@@ -0,0 +1,55 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCanIList(t *testing.T) {
+	tests := []struct {
+		args []string
+		// wantErr is part of the error the command fails with, or empty if it succeeds.
+		wantErr    string
+		wantStdout string
+		// wantReviews are the namespaces rules are reviewed in.
+		wantReviews []string
+	}{
+		{args: []string{"--list"}, wantStdout: "pods", wantReviews: []string{"default"}},
+		{args: []string{"--list", "--kubernetes-namespace", "kube-system"}, wantStdout: "pods", wantReviews: []string{"kube-system"}},
+		{args: []string{"--list", "--all-namespaces"}, wantErr: "--list and --all-namespaces cannot be combined"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+
+			result := runCommand(t, server, append([]string{"can-i"}, tt.args...)...)
+			switch {
+			case tt.wantErr == "" && result.err != nil:
+				t.Errorf("unexpected error: %v", result.err)
+			case tt.wantErr != "" && (result.err == nil || !strings.Contains(result.err.Error(), tt.wantErr)):
+				t.Errorf("error = %v, want it to contain %q", result.err, tt.wantErr)
+			}
+			if !strings.Contains(result.stdout, tt.wantStdout) {
+				t.Errorf("stdout = %q, want it to contain %q", result.stdout, tt.wantStdout)
+			}
+			if strings.Join(server.rulesReviews, ",") != strings.Join(tt.wantReviews, ",") {
+				t.Errorf("rules were reviewed in namespaces %q, want %q", server.rulesReviews, tt.wantReviews)
+			}
+		})
+	}
+}
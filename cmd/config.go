@@ -0,0 +1,317 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/jimmidyson/kube-client-template/pkg/output"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// defaultConfigFile is the config file written by config set when no config file is
+// passed with --config or was found at startup.
+const defaultConfigFile = ".kube-client-template.yaml"
+
+// configKey is a setting that can be persisted in the config file.
+type configKey struct {
+	name string
+	// parse validates a value passed on the command line and converts it to the type
+	// stored in the config file.
+	parse func(value string) (interface{}, error)
+}
+
+// configKeys are the settings that can be persisted in the config file. Keys ending
+// in "." are prefixes, e.g. loggers.client.
+var configKeys = []configKey{
+	{name: "log-level", parse: parseLevelValue},
+	{name: "log-format", parse: func(value string) (interface{}, error) {
+		if value != "json" && value != "console" {
+			return nil, fmt.Errorf("invalid log format %q: must be one of json|console", value)
+		}
+		return value, nil
+	}},
+	{name: "output", parse: func(value string) (interface{}, error) {
+		if _, err := output.PrinterFor(output.Flags{Output: value}); err != nil {
+			return nil, err
+		}
+		return value, nil
+	}},
+	{name: "redact-secrets", parse: parseBoolValue},
+	{name: "no-pager", parse: parseBoolValue},
+	{name: "table-style", parse: func(value string) (interface{}, error) {
+		if _, err := output.PrinterFor(output.Flags{TableStyle: value}); err != nil {
+			return nil, err
+		}
+		return value, nil
+	}},
+	{name: "require-namespace", parse: parseBoolValue},
+	{name: loggersConfigKey + ".", parse: parseLevelValue},
+}
+
+func parseLevelValue(value string) (interface{}, error) {
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return nil, err
+	}
+	return level.String(), nil
+}
+
+func parseBoolValue(value string) (interface{}, error) {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid boolean %q", value)
+	}
+	return b, nil
+}
+
+// lookupConfigKey returns the known key matching name, or an error listing the known keys.
+func lookupConfigKey(name string) (*configKey, error) {
+	for i, key := range configKeys {
+		if key.name == name || (strings.HasSuffix(key.name, ".") && strings.HasPrefix(name, key.name) && len(name) > len(key.name)) {
+			return &configKeys[i], nil
+		}
+	}
+	var names []string
+	for _, key := range configKeys {
+		if strings.HasSuffix(key.name, ".") {
+			names = append(names, key.name+"NAME")
+			continue
+		}
+		names = append(names, key.name)
+	}
+	return nil, fmt.Errorf("unknown config key %q, known keys are: %s", name, strings.Join(names, ", "))
+}
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Read and modify the config file",
+	Long: `Read and modify the config file.
+
+The config file is the file passed with --config, or $HOME/.kube-client-template.yaml,
+which is created when a value is first set. Settings in the config file apply
+when the matching flag isn't passed. They can also be set in the environment,
+prefixed with KUBE_CLIENT_TEMPLATE_ and with dashes replaced by underscores,
+e.g. KUBE_CLIENT_TEMPLATE_LOG_LEVEL=debug, which takes precedence over the
+config file. Known keys are:
+
+  log-level          level of the root logger
+  log-format         log encoding, one of: json|console
+  output             default output format of get
+  redact-secrets     redact the data of secrets in printed output
+  no-pager           never page output through a pager
+  table-style        style of tables, one of: compact|bordered
+  require-namespace  only change objects in the default namespace when passed explicitly
+  loggers.NAME       level of a named logger, e.g. loggers.client
+
+config validate checks the kubeconfig, rather than this config file.`,
+	// The config commands don't talk to the cluster, so they skip setting up the clients.
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging(cmd)
+	},
+}
+
+// configSetCmd represents the config set command
+var configSetCmd = &cobra.Command{
+	Use:   "set KEY VALUE",
+	Short: "Set a value in the config file",
+	Long: `Set a value in the config file, creating the file if it doesn't exist. For example:
+
+  kube-client-template config set output wide
+  kube-client-template config set loggers.client debug`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, err := lookupConfigKey(args[0])
+		if err != nil {
+			return err
+		}
+		value, err := key.parse(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %v", args[0], err)
+		}
+		return updateConfigFile(func(config map[string]interface{}) {
+			setConfigValue(config, args[0], value)
+		})
+	},
+}
+
+// configUnsetCmd represents the config unset command
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset KEY",
+	Short: "Remove a value from the config file",
+	Long:  `Remove a value from the config file, restoring its default.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := lookupConfigKey(args[0]); err != nil {
+			return err
+		}
+		return updateConfigFile(func(config map[string]interface{}) {
+			unsetConfigValue(config, args[0])
+		})
+	},
+}
+
+// configGetCmd represents the config get command
+var configGetCmd = &cobra.Command{
+	Use:   "get KEY",
+	Short: "Print a value from the config file",
+	Long: `Print a value from the config file. Keys that aren't set print nothing
+and exit non-zero.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := lookupConfigKey(args[0]); err != nil {
+			return err
+		}
+		config, err := readConfigFile(configFilePath())
+		if err != nil {
+			return err
+		}
+		value, found := getConfigValue(config, args[0])
+		if !found {
+			return fmt.Errorf("%s is not set", args[0])
+		}
+		fmt.Fprintln(os.Stdout, value)
+		return nil
+	},
+}
+
+// configFilePath is the config file to modify: the file passed with --config, the file
+// found at startup, or the default file in the home directory.
+func configFilePath() string {
+	if cfgFile != "" {
+		return cfgFile
+	}
+	if file := viper.ConfigFileUsed(); file != "" {
+		return file
+	}
+	home, err := homedir.Dir()
+	if err != nil {
+		return defaultConfigFile
+	}
+	return filepath.Join(home, defaultConfigFile)
+}
+
+// readConfigFile reads the config file at path, returning an empty config if it doesn't exist.
+// The file is read directly rather than through viper so that flags and environment
+// variables aren't written back to it.
+func readConfigFile(path string) (map[string]interface{}, error) {
+	config := map[string]interface{}{}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("error parsing config file %s: %v", path, err)
+	}
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+	return config, nil
+}
+
+// updateConfigFile applies update to the config file, creating it if it doesn't exist.
+func updateConfigFile(update func(config map[string]interface{})) error {
+	path := configFilePath()
+	config, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+	update(config)
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	logger.Debug("updated config file", zap.String("file", path))
+	return nil
+}
+
+// setConfigValue sets a dotted key, creating the maps of its parents as needed.
+func setConfigValue(config map[string]interface{}, key string, value interface{}) {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		child, ok := config[part].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			config[part] = child
+		}
+		config = child
+	}
+	config[parts[len(parts)-1]] = value
+}
+
+// unsetConfigValue removes a dotted key, and any of its parents left empty.
+func unsetConfigValue(config map[string]interface{}, key string) {
+	parts := strings.SplitN(key, ".", 2)
+	if len(parts) == 1 {
+		delete(config, key)
+		return
+	}
+	child, ok := config[parts[0]].(map[string]interface{})
+	if !ok {
+		return
+	}
+	unsetConfigValue(child, parts[1])
+	if len(child) == 0 {
+		delete(config, parts[0])
+	}
+}
+
+// getConfigValue returns the value of a dotted key. Maps are printed as sorted key=value pairs.
+func getConfigValue(config map[string]interface{}, key string) (string, bool) {
+	var value interface{} = config
+	for _, part := range strings.Split(key, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		if value, ok = m[part]; !ok {
+			return "", false
+		}
+	}
+	if m, ok := value.(map[string]interface{}); ok {
+		var pairs []string
+		for k, v := range m {
+			pairs = append(pairs, fmt.Sprintf("%s=%v", k, v))
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ","), true
+	}
+	return fmt.Sprint(value), true
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configUnsetCmd)
+	configCmd.AddCommand(configGetCmd)
+}
@@ -0,0 +1,137 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// authProviderCommandKey is the auth provider config key naming a command run to get
+// credentials, as used by the gcp auth provider.
+const authProviderCommandKey = "cmd-path"
+
+// configValidateCmd represents the config validate command
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the kubeconfig for problems",
+	Long: `Check the kubeconfig for problems before it is used, reporting each one.
+
+The kubeconfig must parse, and the selected context must reference a cluster
+and user that exist. Files referenced by the cluster and user, i.e. the
+certificate authority, client certificate and key, and token file, must be
+readable, and the commands run by auth providers must be on the PATH. For
+example:
+
+  kube-client-template config validate
+  kube-client-template config validate --kubernetes-context=staging`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		loader := clientcmd.NewDefaultClientConfigLoadingRules()
+		if kubeConfigFile != "" {
+			loader.ExplicitPath = kubeConfigFile
+		}
+		config, err := loader.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load kubeconfig: %v", err)
+		}
+
+		contextName, issues := validateKubeConfig(config, kubeClientConfigOverrides)
+		for _, issue := range issues {
+			logger.Error("invalid kubeconfig", zap.String("context", contextName), zap.String("problem", issue))
+		}
+		if len(issues) > 0 {
+			return fmt.Errorf("found %d problems in kubeconfig context %q", len(issues), contextName)
+		}
+		fmt.Fprintf(os.Stdout, "kubeconfig context %q is valid\n", contextName)
+		return nil
+	},
+}
+
+// validateKubeConfig returns the context selected in config, taking overrides into
+// account, and the problems found with it.
+func validateKubeConfig(config *clientcmdapi.Config, overrides *clientcmd.ConfigOverrides) (string, []string) {
+	contextName := config.CurrentContext
+	if overrides.CurrentContext != "" {
+		contextName = overrides.CurrentContext
+	}
+	if contextName == "" {
+		return "", []string{"no context is selected, set current-context or pass --kubernetes-context"}
+	}
+	context, found := config.Contexts[contextName]
+	if !found {
+		return contextName, []string{fmt.Sprintf("context %q does not exist", contextName)}
+	}
+
+	var issues []string
+	clusterName, userName := context.Cluster, context.AuthInfo
+	if overrides.Context.Cluster != "" {
+		clusterName = overrides.Context.Cluster
+	}
+	if overrides.Context.AuthInfo != "" {
+		userName = overrides.Context.AuthInfo
+	}
+
+	if cluster, found := config.Clusters[clusterName]; !found {
+		issues = append(issues, fmt.Sprintf("cluster %q referenced by the context does not exist", clusterName))
+	} else {
+		if cluster.Server == "" {
+			issues = append(issues, fmt.Sprintf("cluster %q has no server", clusterName))
+		}
+		issues = appendFileIssue(issues, "certificate authority of cluster "+clusterName, cluster.CertificateAuthority)
+	}
+
+	if userName == "" {
+		return contextName, issues
+	}
+	user, found := config.AuthInfos[userName]
+	if !found {
+		return contextName, append(issues, fmt.Sprintf("user %q referenced by the context does not exist", userName))
+	}
+	issues = appendFileIssue(issues, "client certificate of user "+userName, user.ClientCertificate)
+	issues = appendFileIssue(issues, "client key of user "+userName, user.ClientKey)
+	issues = appendFileIssue(issues, "token file of user "+userName, user.TokenFile)
+	if provider := user.AuthProvider; provider != nil {
+		if command := provider.Config[authProviderCommandKey]; command != "" {
+			if _, err := exec.LookPath(command); err != nil {
+				issues = append(issues, fmt.Sprintf("command %q of the %s auth provider of user %q is not on the PATH", command, provider.Name, userName))
+			}
+		}
+	}
+	return contextName, issues
+}
+
+// appendFileIssue appends a problem to issues if path is set and can't be read.
+func appendFileIssue(issues []string, description, path string) []string {
+	if path == "" {
+		return issues
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return append(issues, fmt.Sprintf("%s is not readable: %v", description, err))
+	}
+	f.Close()
+	return issues
+}
+
+func init() {
+	configCmd.AddCommand(configValidateCmd)
+}
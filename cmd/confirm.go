@@ -0,0 +1,85 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// assumeYes skips the confirmation of destructive commands.
+var assumeYes bool
+
+// errNotConfirmed is returned when the user declines a confirmation.
+var errNotConfirmed = errors.New("aborted, nothing was changed")
+
+// confirm asks the user to confirm the action described by summary before a destructive
+// command goes ahead. Without --yes, stdin must be a terminal so that scripts never
+// block on, or unknowingly skip, the prompt.
+func confirm(summary string) error {
+	if assumeYes {
+		return nil
+	}
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("refusing to %s without confirmation, pass --yes when stdin is not a terminal", summary)
+	}
+
+	fmt.Fprintf(os.Stderr, "This will %s.\nContinue? [y/N]: ", summary)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return errNotConfirmed
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	default:
+		return errNotConfirmed
+	}
+}
+
+// objectsSummary describes objs for a confirmation, e.g. "5 pods in namespaces
+// default (3), kube-system (2)".
+func objectsSummary(resource string, objs []*unstructured.Unstructured) string {
+	counts := map[string]int{}
+	for _, obj := range objs {
+		counts[obj.GetNamespace()]++
+	}
+	summary := fmt.Sprintf("%d %s", len(objs), resource)
+	if _, clusterScoped := counts[""]; clusterScoped {
+		return summary
+	}
+	if len(counts) == 1 {
+		return summary + " in namespace " + objs[0].GetNamespace()
+	}
+	var namespaces []string
+	for ns, count := range counts {
+		namespaces = append(namespaces, fmt.Sprintf("%s (%d)", ns, count))
+	}
+	sort.Strings(namespaces)
+	return summary + " in namespaces " + strings.Join(namespaces, ", ")
+}
+
+// addConfirmFlag adds the --yes flag to a destructive command.
+func addConfirmFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "don't ask for confirmation, required when stdin is not a terminal")
+}
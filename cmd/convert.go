@@ -0,0 +1,245 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jimmidyson/kube-client-template/pkg/kube"
+	"github.com/jimmidyson/kube-client-template/pkg/output"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+var (
+	convertFilenames     []string
+	convertOutputVersion string
+	convertOutput        string
+)
+
+// convertCmd represents the convert command
+var convertCmd = &cobra.Command{
+	Use:   "convert -f FILENAME --output-version=GROUP/VERSION",
+	Short: "Convert manifests between API versions",
+	Long: `Convert manifests between API versions, printing the result.
+
+The requested version must be served by the cluster. Built-in kinds are
+converted between versions of the same API group. The API server has no
+conversion endpoint, so conversion between groups, e.g. from
+extensions/v1beta1 to apps/v1, and conversion of custom resources is not
+supported and is reported as an error.
+
+Fields are converted by name, so conversions that would drop fields, e.g.
+the rollbackTo of apps/v1beta1 deployments, are reported as an error naming
+them. Workloads converted to apps/v1beta2 or later, where the pod selector
+is required, get the pod template labels as their selector if they had none,
+as older versions defaulted it. For example:
+
+  kube-client-template convert -f deployment.yaml --output-version=apps/v1`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(convertFilenames) == 0 {
+			return errors.New("must specify at least one filename with -f")
+		}
+		if convertOutputVersion == "" {
+			return errors.New("must specify the version to convert to with --output-version")
+		}
+		target, err := schema.ParseGroupVersion(convertOutputVersion)
+		if err != nil {
+			return fmt.Errorf("invalid --output-version %q: %v", convertOutputVersion, err)
+		}
+		printer, err := output.PrinterFor(output.Flags{Output: convertOutput, Transforms: outputTransforms()})
+		if err != nil {
+			return err
+		}
+
+		objs, err := readManifests(convertFilenames)
+		if err != nil {
+			return err
+		}
+		if len(objs) == 0 {
+			return errors.New("no objects passed to convert")
+		}
+
+		var converted []unstructured.Unstructured
+		for _, obj := range objs {
+			out, err := convertObject(obj.Unstructured, target)
+			if err != nil {
+				logRawError(err)
+				logger.Error("failed to convert object", append(errorFields(err),
+					zap.String("source", obj.source),
+					zap.String("kind", obj.GetKind()),
+					zap.String("name", obj.GetName()),
+				)...)
+				continue
+			}
+			converted = append(converted, *out)
+		}
+
+		switch {
+		case len(converted) == 1:
+			err = printer.PrintObj(&converted[0], os.Stdout)
+		case len(converted) > 1:
+			err = printer.PrintObj(kube.NewList(converted), os.Stdout)
+		}
+		if err != nil {
+			return err
+		}
+		if failed := len(objs) - len(converted); failed > 0 {
+			return fmt.Errorf("failed to convert %d of %d objects", failed, len(objs))
+		}
+		return nil
+	},
+}
+
+// convertObject converts obj to the target version of its group.
+func convertObject(obj *unstructured.Unstructured, target schema.GroupVersion) (*unstructured.Unstructured, error) {
+	gvk := obj.GroupVersionKind()
+	if gvk.GroupVersion() == target {
+		return obj, nil
+	}
+	if _, err := restMapper.RESTMapping(schema.GroupKind{Group: target.Group, Kind: gvk.Kind}, target.Version); err != nil {
+		return nil, fmt.Errorf("%s is not served by %s in this cluster", gvk.Kind, target)
+	}
+	if gvk.Group != target.Group {
+		return nil, fmt.Errorf("converting %s from %s to %s requires conversion between API groups, which is not supported", gvk.Kind, gvk.GroupVersion(), target)
+	}
+
+	return convertBuiltin(obj, target)
+}
+
+// convertBuiltin converts obj, of a built-in kind, to the target version of its group.
+// The client only converts fields with the same name in both versions, so conversions
+// that would drop fields are refused, and the fields that the server used to default
+// are set: the pod selector of workloads is required from apps/v1beta2 on, but was
+// defaulted from the pod template labels before.
+func convertBuiltin(obj *unstructured.Unstructured, target schema.GroupVersion) (*unstructured.Unstructured, error) {
+	gvk := obj.GroupVersionKind()
+	typed, err := scheme.Scheme.New(gvk)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s is not a built-in kind, so it can't be converted client-side", gvk.GroupVersion(), gvk.Kind)
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, typed); err != nil {
+		return nil, err
+	}
+	out, err := scheme.Scheme.ConvertToVersion(typed, target)
+	if err != nil {
+		return nil, fmt.Errorf("converting %s from %s to %s is not supported: %v", gvk.Kind, gvk.GroupVersion(), target, err)
+	}
+
+	original, err := runtime.DefaultUnstructuredConverter.ToUnstructured(typed)
+	if err != nil {
+		return nil, err
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(out)
+	if err != nil {
+		return nil, err
+	}
+	if dropped := droppedFields(original, content, ""); len(dropped) > 0 {
+		return nil, fmt.Errorf("converting %s from %s to %s would drop %s, which must be migrated by hand",
+			gvk.Kind, gvk.GroupVersion(), target, strings.Join(dropped, ", "))
+	}
+	converted := &unstructured.Unstructured{Object: content}
+	if err := defaultSelector(converted, target); err != nil {
+		return nil, err
+	}
+	// Typed objects have fields that the input didn't set, e.g. a null creation
+	// timestamp and an empty status, which would only clutter the manifest.
+	pruneUnset(converted.Object, obj.Object)
+	return converted, nil
+}
+
+// selectorDefaultedKinds are the workloads whose pod selector was defaulted from their
+// pod template labels before apps/v1beta2.
+var selectorDefaultedKinds = map[string]bool{"Deployment": true, "StatefulSet": true, "DaemonSet": true, "ReplicaSet": true}
+
+// defaultSelector sets the pod selector of a workload converted to target from the labels
+// of its pod template, as the server did for the versions where it was optional.
+func defaultSelector(obj *unstructured.Unstructured, target schema.GroupVersion) error {
+	if target.Group != "apps" || target.Version == "v1beta1" || !selectorDefaultedKinds[obj.GetKind()] {
+		return nil
+	}
+	if selector, _, _ := unstructured.NestedFieldCopy(obj.Object, "spec", "selector"); selector != nil {
+		return nil
+	}
+	labels, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "template", "metadata", "labels")
+	if len(labels) == 0 {
+		return fmt.Errorf("%s %s has neither a pod selector nor pod template labels, one of which is required by %s", obj.GetKind(), obj.GetName(), target)
+	}
+	matchLabels := map[string]interface{}{}
+	for k, v := range labels {
+		matchLabels[k] = v
+	}
+	return unstructured.SetNestedMap(obj.Object, map[string]interface{}{"matchLabels": matchLabels}, "spec", "selector")
+}
+
+// pruneUnset removes the null values and empty maps and lists of out that aren't set in
+// in, recursively.
+func pruneUnset(out, in map[string]interface{}) {
+	for key, value := range out {
+		inValue, set := in[key]
+		if m, ok := value.(map[string]interface{}); ok {
+			inMap, _ := inValue.(map[string]interface{})
+			pruneUnset(m, inMap)
+		}
+		if set {
+			continue
+		}
+		switch v := value.(type) {
+		case nil:
+			delete(out, key)
+		case map[string]interface{}:
+			if len(v) == 0 {
+				delete(out, key)
+			}
+		case []interface{}:
+			if len(v) == 0 {
+				delete(out, key)
+			}
+		}
+	}
+}
+
+// droppedFields returns the dot separated paths of the fields of in that are set but
+// missing in out, under prefix. Fields within lists aren't compared.
+func droppedFields(in, out map[string]interface{}, prefix string) []string {
+	var dropped []string
+	for key, value := range in {
+		path := prefix + key
+		if value == nil {
+			continue
+		}
+		outValue, found := out[key]
+		if !found {
+			dropped = append(dropped, path)
+			continue
+		}
+		if m, ok := value.(map[string]interface{}); ok {
+			if outMap, ok := outValue.(map[string]interface{}); ok {
+				dropped = append(dropped, droppedFields(m, outMap, path+".")...)
+			}
+		}
+	}
+	sort.Strings(dropped)
+	return dropped
+}
@@ -0,0 +1,108 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// v1beta1Deployment returns an apps/v1beta1 deployment without a pod selector, as that
+// version defaulted it.
+func v1beta1Deployment() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1beta1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "default"},
+		"spec": map[string]interface{}{
+			"replicas": int64(2),
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "web"}},
+				"spec": map[string]interface{}{
+					"containers": []interface{}{map[string]interface{}{"name": "app", "image": "nginx:1.13"}},
+				},
+			},
+		},
+	}}
+}
+
+// TestConvertDeployment checks that a deployment converted to apps/v1 gets the selector
+// it was defaulted before, and no fields that it didn't set.
+func TestConvertDeployment(t *testing.T) {
+	converted, err := convertBuiltin(v1beta1Deployment(), schema.GroupVersion{Group: "apps", Version: "v1"})
+	if err != nil {
+		t.Fatalf("converting failed: %v", err)
+	}
+	if got := converted.GetAPIVersion(); got != "apps/v1" {
+		t.Errorf("apiVersion is %s, want apps/v1", got)
+	}
+	selector, _, _ := unstructured.NestedStringMap(converted.Object, "spec", "selector", "matchLabels")
+	if want := map[string]string{"app": "web"}; !reflect.DeepEqual(selector, want) {
+		t.Errorf("selector is %v, want %v", selector, want)
+	}
+	for _, field := range [][]string{{"status"}, {"metadata", "creationTimestamp"}, {"spec", "strategy"}, {"spec", "template", "metadata", "creationTimestamp"}} {
+		if value, found, _ := unstructured.NestedFieldCopy(converted.Object, field...); found {
+			t.Errorf("%s is %v, want it unset", strings.Join(field, "."), value)
+		}
+	}
+	if replicas, _, _ := unstructured.NestedInt64(converted.Object, "spec", "replicas"); replicas != 2 {
+		t.Errorf("replicas is %d, want 2", replicas)
+	}
+}
+
+// TestConvertKeepsSelector checks that a selector set on the input is kept.
+func TestConvertKeepsSelector(t *testing.T) {
+	obj := v1beta1Deployment()
+	_ = unstructured.SetNestedStringMap(obj.Object, map[string]string{"app": "web", "tier": "front"}, "spec", "selector", "matchLabels")
+	converted, err := convertBuiltin(obj, schema.GroupVersion{Group: "apps", Version: "v1"})
+	if err != nil {
+		t.Fatalf("converting failed: %v", err)
+	}
+	selector, _, _ := unstructured.NestedStringMap(converted.Object, "spec", "selector", "matchLabels")
+	if want := map[string]string{"app": "web", "tier": "front"}; !reflect.DeepEqual(selector, want) {
+		t.Errorf("selector is %v, want %v", selector, want)
+	}
+}
+
+// TestConvertRefusesDroppingFields checks that conversions losing fields are refused,
+// naming the fields.
+func TestConvertRefusesDroppingFields(t *testing.T) {
+	obj := v1beta1Deployment()
+	_ = unstructured.SetNestedField(obj.Object, map[string]interface{}{"revision": int64(3)}, "spec", "rollbackTo")
+	_, err := convertBuiltin(obj, schema.GroupVersion{Group: "apps", Version: "v1"})
+	if err == nil {
+		t.Fatal("converting a deployment with rollbackTo to apps/v1 succeeded, want an error")
+	}
+	if !strings.Contains(err.Error(), "would drop spec.rollbackTo") {
+		t.Errorf("error is %q, want it to name spec.rollbackTo", err)
+	}
+}
+
+// TestConvertUnknownKind checks that kinds the client doesn't know are refused.
+func TestConvertUnknownKind(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1alpha1",
+		"kind":       "Widget",
+		"metadata":   map[string]interface{}{"name": "w"},
+	}}
+	_, err := convertBuiltin(obj, schema.GroupVersion{Group: "example.com", Version: "v1"})
+	if err == nil || !strings.Contains(err.Error(), "not a built-in kind") {
+		t.Errorf("error is %v, want one saying Widget isn't a built-in kind", err)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jimmidyson/kube-client-template/pkg/output"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	createFilenames  []string
+	createDryRun     bool
+	createSaveConfig bool
+)
+
+// createCmd represents the create command
+var createCmd = &cobra.Command{
+	Use:   "create -f FILENAME",
+	Short: "Create resources from files or stdin",
+	Long: `Create resources from files or stdin.
+
+Unlike apply, creating an object that already exists is an error. Files may
+contain multiple YAML documents or List objects, and directories are read
+for .json, .yaml and .yml files.
+
+With --save-config, objects are created with the configuration they were
+created from recorded in the kubectl.kubernetes.io/last-applied-configuration
+annotation, as apply does, so that they can later be applied with either
+this tool or kubectl. For example:
+
+  kube-client-template create -f deployment.yaml --save-config
+  cat manifests.yaml | kube-client-template create -f -`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(createFilenames) == 0 {
+			return errors.New("must specify at least one filename with -f")
+		}
+		objs, err := readManifests(createFilenames)
+		if err != nil {
+			return err
+		}
+		if len(objs) == 0 {
+			return errors.New("no objects passed to create")
+		}
+
+		var failed int
+		for _, obj := range objs {
+			if err := createObject(obj); err != nil {
+				failed++
+				logRawError(err)
+				logger.Error("failed to create object", append(errorFields(err),
+					zap.String("source", obj.source),
+					zap.String("kind", obj.GetKind()),
+					zap.String("name", obj.GetName()),
+				)...)
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("failed to create %d of %d objects", failed, len(objs))
+		}
+		return nil
+	},
+}
+
+func createObject(obj manifestObject) error {
+	_, client, err := objectClient(obj.Unstructured)
+	if err != nil {
+		return err
+	}
+	name, err := output.QualifiedName(obj.Unstructured)
+	if err != nil {
+		return err
+	}
+	if createDryRun {
+		fmt.Fprintf(os.Stdout, "%s created (dry run)\n", name)
+		return nil
+	}
+
+	toCreate := obj.Unstructured
+	if createSaveConfig {
+		if toCreate, err = withLastApplied(toCreate); err != nil {
+			return err
+		}
+	}
+	created, err := client.Create(toCreate)
+	if err != nil {
+		return err
+	}
+	if name, err = output.QualifiedName(created); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "%s created\n", name)
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(createCmd)
+
+	createCmd.Flags().StringSliceVarP(&createFilenames, "filename", "f", nil, "files or directories containing the objects to create, or - for stdin")
+	createCmd.Flags().BoolVar(&createDryRun, "dry-run", false, "only print the objects that would be created, without creating them")
+	createCmd.Flags().BoolVar(&createSaveConfig, "save-config", false, "record the configuration of each object in its last applied configuration annotation, so it can later be applied")
+}
@@ -0,0 +1,125 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+
+	"github.com/jimmidyson/kube-client-template/pkg/output"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// dedupIgnoredFields are removed from objects before they are compared by --dedup, as the
+// server changes them without anything else about the object changing.
+var dedupIgnoredFields = []string{"metadata.resourceVersion", "metadata.managedFields"}
+
+// dedupIgnoredConditionFields are removed from status conditions before objects are
+// compared by --dedup, as they are bumped by periodic probes and heartbeats.
+var dedupIgnoredConditionFields = []string{"lastHeartbeatTime", "lastProbeTime", "lastUpdateTime"}
+
+// deduper tells which watch events change how an object prints, for --dedup.
+type deduper struct {
+	// flags configure the printer that objects are rendered with to compare them.
+	flags output.Flags
+	// rendered holds the last rendering of each object printed, by UID.
+	rendered map[types.UID][]byte
+}
+
+// newDeduper returns a deduper comparing objects as printed with flags.
+func newDeduper(flags output.Flags) *deduper {
+	// Objects are rendered on their own, so the header would be repeated in every
+	// rendering rather than only in the first one printed.
+	flags.NoHeaders = true
+	return &deduper{flags: flags, rendered: map[types.UID][]byte{}}
+}
+
+// seed records objs as printed, so that changes to them that don't print differently
+// aren't printed again. It does nothing on a nil deduper.
+func (d *deduper) seed(objs []unstructured.Unstructured) error {
+	if d == nil {
+		return nil
+	}
+	for i := range objs {
+		data, err := d.render(&objs[i])
+		if err != nil {
+			return err
+		}
+		d.rendered[objs[i].GetUID()] = data
+	}
+	return nil
+}
+
+// changed returns whether event should be printed: added and deleted objects always are,
+// modified objects only when they render differently than they were last printed.
+func (d *deduper) changed(event watch.Event) (bool, error) {
+	obj, err := meta.Accessor(event.Object)
+	if err != nil {
+		return false, err
+	}
+	if event.Type == watch.Deleted {
+		delete(d.rendered, obj.GetUID())
+		return true, nil
+	}
+	data, err := d.render(event.Object)
+	if err != nil {
+		return false, err
+	}
+	last, seen := d.rendered[obj.GetUID()]
+	if event.Type == watch.Modified && seen && bytes.Equal(last, data) {
+		return false, nil
+	}
+	d.rendered[obj.GetUID()] = data
+	return true, nil
+}
+
+// render prints obj without the fields that change on their own. A new printer is used
+// each time, as printers print differently after their first object, e.g. YAML
+// document separators.
+func (d *deduper) render(obj runtime.Object) ([]byte, error) {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		obj = withoutIgnoredFields(u)
+	}
+	printer, err := output.PrinterFor(d.flags)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := printer.PrintObj(obj, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// withoutIgnoredFields returns a copy of obj without the fields ignored by --dedup.
+func withoutIgnoredFields(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	stripped := output.RemoveFields(dedupIgnoredFields)(obj)
+	conditions, found, err := unstructured.NestedSlice(stripped.Object, "status", "conditions")
+	if err != nil || !found {
+		return stripped
+	}
+	for _, c := range conditions {
+		if condition, ok := c.(map[string]interface{}); ok {
+			for _, field := range dedupIgnoredConditionFields {
+				delete(condition, field)
+			}
+		}
+	}
+	_ = unstructured.SetNestedSlice(stripped.Object, conditions, "status", "conditions")
+	return stripped
+}
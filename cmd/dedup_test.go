@@ -0,0 +1,119 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/jimmidyson/kube-client-template/pkg/output"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// dedupPod returns a ready pod named web at resourceVersion, probed at probeTime.
+func dedupPod(resourceVersion, probeTime string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata": map[string]interface{}{
+			"name":            "web",
+			"namespace":       "default",
+			"uid":             "uid-web",
+			"resourceVersion": resourceVersion,
+		},
+		"status": map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Ready", "status": "True", "lastProbeTime": probeTime},
+			},
+		},
+	}}
+}
+
+func TestDeduper(t *testing.T) {
+	relabelled := dedupPod("3", "")
+	relabelled.SetLabels(map[string]string{"app": "web"})
+
+	tests := []struct {
+		name   string
+		output string
+		// seeded is whether the pod was listed before the watch.
+		seeded bool
+		events []watch.Event
+		want   []bool
+	}{
+		{
+			name:   "listed objects aren't printed again unchanged",
+			seeded: true,
+			events: []watch.Event{{Type: watch.Modified, Object: dedupPod("2", "")}},
+			want:   []bool{false},
+		},
+		{
+			name:   "the first render with a header matches later ones",
+			events: []watch.Event{{Type: watch.Added, Object: dedupPod("1", "")}, {Type: watch.Modified, Object: dedupPod("2", "")}},
+			want:   []bool{true, false},
+		},
+		{
+			name:   "fields that aren't columns are ignored by tables",
+			seeded: true,
+			events: []watch.Event{{Type: watch.Modified, Object: relabelled}},
+			want:   []bool{false},
+		},
+		{
+			name:   "resource versions and probe times are ignored by yaml",
+			output: "yaml",
+			seeded: true,
+			events: []watch.Event{{Type: watch.Modified, Object: dedupPod("2", "2018-01-01T00:00:00Z")}, {Type: watch.Modified, Object: dedupPod("3", "2018-01-01T00:01:00Z")}},
+			want:   []bool{false, false},
+		},
+		{
+			name:   "other changes are printed by yaml",
+			output: "yaml",
+			seeded: true,
+			events: []watch.Event{{Type: watch.Modified, Object: relabelled}, {Type: watch.Modified, Object: relabelled}},
+			want:   []bool{true, false},
+		},
+		{
+			name:   "modified objects that weren't listed are printed",
+			output: "json",
+			events: []watch.Event{{Type: watch.Modified, Object: dedupPod("2", "")}},
+			want:   []bool{true},
+		},
+		{
+			name:   "deleted and re-added objects are printed",
+			seeded: true,
+			events: []watch.Event{{Type: watch.Deleted, Object: dedupPod("2", "")}, {Type: watch.Added, Object: dedupPod("3", "")}},
+			want:   []bool{true, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dedup := newDeduper(output.Flags{Output: tt.output})
+			if tt.seeded {
+				if err := dedup.seed([]unstructured.Unstructured{*dedupPod("1", "")}); err != nil {
+					t.Fatalf("seed() failed: %v", err)
+				}
+			}
+			for i, event := range tt.events {
+				changed, err := dedup.changed(event)
+				if err != nil {
+					t.Fatalf("changed() failed: %v", err)
+				}
+				if changed != tt.want[i] {
+					t.Errorf("changed() of %s event %d = %t, want %t", event.Type, i, changed, tt.want[i])
+				}
+			}
+		})
+	}
+}
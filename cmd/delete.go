@@ -0,0 +1,185 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jimmidyson/kube-client-template/pkg/output"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// evictionRetryInterval is how often an eviction blocked by a disruption budget is retried.
+const evictionRetryInterval = 5 * time.Second
+
+var (
+	deleteSelector       string
+	deleteGracePeriod    int
+	deleteUseEviction    bool
+	deleteTimeout        time.Duration
+	deleteIgnoreNotFound bool
+)
+
+// deleteCmd represents the delete command
+var deleteCmd = &cobra.Command{
+	Use:   "delete TYPE[/NAME] [NAME...]",
+	Short: "Delete resources by name or label selector",
+	Long: `Delete resources by name or label selector.
+
+With --use-eviction, pods are evicted rather than deleted, so that pod
+disruption budgets are respected. Evictions blocked by a disruption budget
+are retried until --timeout, and the pods still blocked are reported at the
+end.
+
+Deleting asks for confirmation, showing how many objects will be deleted in
+which namespaces. Pass --yes to skip it, which is required when stdin is not
+a terminal. For example:
+
+  kube-client-template delete deployment/nginx
+  kube-client-template delete pods -l app=nginx --use-eviction --timeout=2m --yes`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resourceArg, names, err := splitResourceArgs(args)
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 && deleteSelector == "" {
+			return errors.New("must specify the names of the objects to delete, or a selector with -l")
+		}
+		if len(names) > 0 && deleteSelector != "" {
+			return errors.New("names and a selector cannot both be specified")
+		}
+
+		mapping, err := resourceMapping(resourceArg)
+		if err != nil {
+			return err
+		}
+		if deleteUseEviction && !isPodMapping(mapping) {
+			return errors.New("--use-eviction is only supported for pods")
+		}
+		objs, err := selectObjects(mapping, namespace, names, deleteSelector)
+		if err != nil {
+			return err
+		}
+		if len(objs) == 0 {
+			fmt.Fprintln(os.Stderr, "No resources found.")
+			return nil
+		}
+		verb := "delete"
+		if deleteUseEviction {
+			verb = "evict"
+		}
+		if err := confirm(verb + " " + objectsSummary(mapping.Resource, objs)); err != nil {
+			return err
+		}
+		return deleteAll(mapping, objs)
+	},
+}
+
+func deleteAll(mapping *meta.RESTMapping, objs []*unstructured.Unstructured) error {
+	opts := &metav1.DeleteOptions{}
+	if deleteGracePeriod >= 0 {
+		gracePeriod := int64(deleteGracePeriod)
+		opts.GracePeriodSeconds = &gracePeriod
+	}
+	var deadline time.Time
+	if deleteTimeout > 0 {
+		deadline = time.Now().Add(deleteTimeout)
+	}
+	verb, action := "delete", "deleted"
+	if deleteUseEviction {
+		verb, action = "evict", "evicted"
+	}
+
+	var failed, blocked int
+	for _, obj := range objs {
+		name, err := output.QualifiedName(obj)
+		if err != nil {
+			return err
+		}
+		if deleteUseEviction {
+			err = evictPod(obj.GetNamespace(), obj.GetName(), opts, deadline)
+		} else {
+			err = deleteObject(mapping, obj, opts)
+		}
+		switch {
+		case err == nil:
+			fmt.Fprintf(os.Stdout, "%s %s\n", name, action)
+		case apierrors.IsNotFound(err) && deleteIgnoreNotFound:
+			logger.Debug("ignoring object that was not found", zap.String("name", name))
+		case deleteUseEviction && apierrors.IsTooManyRequests(err):
+			blocked++
+			logRawError(err)
+			logger.Error("eviction would violate a pod disruption budget", append(errorFields(err), zap.String("name", name))...)
+		default:
+			failed++
+			logRawError(err)
+			logger.Error("failed to "+verb+" object", append(errorFields(err), zap.String("name", name))...)
+		}
+	}
+
+	if blocked > 0 {
+		return fmt.Errorf("failed to evict %d of %d pods, %d of them blocked by pod disruption budgets", failed+blocked, len(objs), blocked)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to %s %d of %d objects", verb, failed, len(objs))
+	}
+	return nil
+}
+
+func deleteObject(mapping *meta.RESTMapping, obj *unstructured.Unstructured, opts *metav1.DeleteOptions) error {
+	client, err := resourceClient(mapping, obj.GetNamespace())
+	if err != nil {
+		return err
+	}
+	return client.Delete(obj.GetName(), opts)
+}
+
+// evictPod evicts the named pod, retrying while the eviction is blocked by a disruption
+// budget until deadline. A zero deadline only tries once.
+func evictPod(namespace, name string, opts *metav1.DeleteOptions, deadline time.Time) error {
+	eviction := &policyv1beta1.Eviction{
+		ObjectMeta:    metav1.ObjectMeta{Namespace: namespace, Name: name},
+		DeleteOptions: opts,
+	}
+	for {
+		err := kubeClient.CoreV1().Pods(namespace).Evict(eviction)
+		if err == nil || !apierrors.IsTooManyRequests(err) || deadline.IsZero() || time.Now().Add(evictionRetryInterval).After(deadline) {
+			return err
+		}
+		logger.Info("eviction blocked by a pod disruption budget, retrying", zap.String("pod", name), zap.Duration("interval", evictionRetryInterval))
+		time.Sleep(evictionRetryInterval)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(deleteCmd)
+
+	deleteCmd.Flags().StringVarP(&deleteSelector, "selector", "l", "", "label selector of the objects to delete, supports '=', '==', and '!=' (e.g. -l key1=value1,key2=value2)")
+	deleteCmd.Flags().IntVar(&deleteGracePeriod, "grace-period", -1, "seconds given to pods to terminate gracefully, -1 uses the pod's own grace period")
+	deleteCmd.Flags().BoolVar(&deleteUseEviction, "use-eviction", false, "evict pods with the eviction API, respecting pod disruption budgets, rather than deleting them")
+	deleteCmd.Flags().DurationVar(&deleteTimeout, "timeout", 0, "how long to retry evictions blocked by pod disruption budgets, zero means try once")
+	addConfirmFlag(deleteCmd)
+	deleteCmd.Flags().BoolVar(&deleteIgnoreNotFound, "ignore-not-found", false, "treat objects that don't exist as successfully deleted")
+}
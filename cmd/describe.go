@@ -0,0 +1,419 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jimmidyson/kube-client-template/pkg/kube"
+	"github.com/jimmidyson/kube-client-template/pkg/output"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	describeSelector       string
+	describeOutput         string
+	describeAllEvents      bool
+	describeIgnoreNotFound bool
+)
+
+// describeCmd represents the describe command
+var describeCmd = &cobra.Command{
+	Use:   "describe TYPE[/NAME] [NAME...]",
+	Short: "Show details of resources, including their events",
+	Long: `Show details of resources by name or label selector, including fields computed
+from the object and its related objects: its age, how many of its replicas or
+containers are ready, and the events about it. Objects that can't be got are
+reported once the others have been described, and with --ignore-not-found
+those that don't exist are skipped.
+
+Events are grouped by type and reason, showing how many times each occurred,
+when it was first and last seen, and its latest message, so that a repeated
+event such as BackOff is shown once. Use --all-events to list every event
+instead.
+
+The details are printed as text by default. With --output json or yaml, each
+object is printed as a document holding the object itself and the computed
+fields, so that automation can consume the same view. These hold both the
+grouped events and every event. For example:
+
+  kube-client-template describe deployment/nginx
+  kube-client-template describe pod/nginx --all-events
+  kube-client-template describe pods -l app=nginx -o yaml`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resourceArg, names, err := splitResourceArgs(args)
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 && describeSelector == "" {
+			return errors.New("must specify the names of the objects to describe, or a selector with -l")
+		}
+		var printer output.Printer
+		switch describeOutput {
+		case "":
+		case "json", "yaml":
+			if printer, err = output.PrinterFor(output.Flags{Output: describeOutput}); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported output format %q: must be one of json|yaml", describeOutput)
+		}
+
+		mapping, err := resourceMapping(resourceArg)
+		if err != nil {
+			return err
+		}
+		result, err := describeObjects(mapping, names)
+		if err != nil {
+			return err
+		}
+		// Objects that couldn't be got don't stop the others from being described.
+		err = namedFailuresError(result, names, describeIgnoreNotFound)
+		if len(result.List.Items) == 0 {
+			if err == nil {
+				fmt.Fprintln(os.Stderr, "No resources found.")
+			}
+			return err
+		}
+
+		if printer == nil {
+			defer pageOutput(true)()
+		}
+		for i := range result.List.Items {
+			obj := &result.List.Items[i]
+			// The printers only transform unstructured objects, so the described object
+			// is transformed here, e.g. so that secrets are redacted.
+			for _, transform := range outputTransforms() {
+				obj = transform(obj)
+			}
+			d := describe(mapping, obj)
+			var printErr error
+			if printer != nil {
+				printErr = printer.PrintObj(d, os.Stdout)
+			} else {
+				if i > 0 {
+					fmt.Fprintln(os.Stdout)
+				}
+				printErr = printDescription(os.Stdout, d)
+			}
+			if printErr != nil {
+				return printErr
+			}
+		}
+		return err
+	},
+}
+
+// describeObjects gets the named objects, or lists those matching --selector. Failing to
+// get a named object is recorded in the result rather than stopping the others.
+func describeObjects(mapping *meta.RESTMapping, names []string) (*kube.Result, error) {
+	client, err := resourceClient(mapping, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return kube.List(client, metav1.ListOptions{LabelSelector: describeSelector})
+	}
+	return kube.Get(client, names), nil
+}
+
+// description is an object along with the fields computed for describe. It is printed
+// directly by the JSON and YAML printers.
+type description struct {
+	Object   *unstructured.Unstructured `json:"object"`
+	Computed computedFields             `json:"computed"`
+}
+
+// computedFields are the fields of a description that aren't part of the object.
+type computedFields struct {
+	Age            string           `json:"age"`
+	Ready          string           `json:"ready,omitempty"`
+	Events         []describedEvent `json:"events"`
+	EventsByReason []eventGroup     `json:"eventsByReason"`
+}
+
+// describedEvent is an event about a described object.
+type describedEvent struct {
+	Type    string `json:"type"`
+	Reason  string `json:"reason"`
+	Age     string `json:"age"`
+	From    string `json:"from"`
+	Message string `json:"message"`
+	Count   int32  `json:"count"`
+}
+
+// eventGroup is the events about a described object with the same type and reason.
+type eventGroup struct {
+	Type      string `json:"type"`
+	Reason    string `json:"reason"`
+	Count     int32  `json:"count"`
+	FirstSeen string `json:"firstSeen"`
+	LastSeen  string `json:"lastSeen"`
+	From      string `json:"from"`
+	Message   string `json:"message"`
+}
+
+// GetObjectKind implements runtime.Object.
+func (d *description) GetObjectKind() schema.ObjectKind {
+	return schema.EmptyObjectKind
+}
+
+// DeepCopyObject implements runtime.Object.
+func (d *description) DeepCopyObject() runtime.Object {
+	out := *d
+	out.Object = d.Object.DeepCopy()
+	out.Computed.Events = append([]describedEvent(nil), d.Computed.Events...)
+	out.Computed.EventsByReason = append([]eventGroup(nil), d.Computed.EventsByReason...)
+	return &out
+}
+
+// describe gathers the description of obj. Failing to list its events is logged rather
+// than failing the command, as the object itself is still worth showing.
+func describe(mapping *meta.RESTMapping, obj *unstructured.Unstructured) *description {
+	d := &description{
+		Object: obj,
+		Computed: computedFields{
+			Age:            output.TranslateTimestamp(obj.GetCreationTimestamp()),
+			Ready:          readyCount(obj),
+			Events:         []describedEvent{},
+			EventsByReason: []eventGroup{},
+		},
+	}
+	events, err := eventsAbout(mapping, obj)
+	if err != nil {
+		logRawError(err)
+		logger.Warn("failed to list events", append(errorFields(err), zap.String("name", obj.GetName()))...)
+		return d
+	}
+	for _, e := range events {
+		d.Computed.Events = append(d.Computed.Events, describedEvent{
+			Type:    e.Type,
+			Reason:  e.Reason,
+			Age:     output.TranslateTimestamp(e.LastTimestamp),
+			From:    eventSource(e.Source),
+			Message: strings.TrimSpace(e.Message),
+			Count:   e.Count,
+		})
+	}
+	d.Computed.EventsByReason = groupEvents(events)
+	return d
+}
+
+// readyCount returns how many of the replicas, or for pods containers, of obj are ready,
+// e.g. "2/3", or an empty string if obj doesn't report readiness.
+func readyCount(obj *unstructured.Unstructured) string {
+	if obj.GetKind() == "Pod" {
+		containers, _, _ := unstructured.NestedSlice(obj.Object, "spec", "containers")
+		statuses, _, _ := unstructured.NestedSlice(obj.Object, "status", "containerStatuses")
+		var ready int
+		for _, s := range statuses {
+			if status, ok := s.(map[string]interface{}); ok && status["ready"] == true {
+				ready++
+			}
+		}
+		return fmt.Sprintf("%d/%d", ready, len(containers))
+	}
+	if desired, found, _ := unstructured.NestedInt64(obj.Object, "status", "desiredNumberScheduled"); found {
+		ready, _, _ := unstructured.NestedInt64(obj.Object, "status", "numberReady")
+		return fmt.Sprintf("%d/%d", ready, desired)
+	}
+	if desired, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas"); found {
+		ready, _, _ := unstructured.NestedInt64(obj.Object, "status", "readyReplicas")
+		return fmt.Sprintf("%d/%d", ready, desired)
+	}
+	return ""
+}
+
+// eventsAbout returns the events about obj, oldest first.
+func eventsAbout(mapping *meta.RESTMapping, obj *unstructured.Unstructured) ([]corev1.Event, error) {
+	set := fields.Set{
+		"involvedObject.uid":  string(obj.GetUID()),
+		"involvedObject.name": obj.GetName(),
+		"involvedObject.kind": mapping.GroupVersionKind.Kind,
+	}
+	list, err := kubeClient.CoreV1().Events(obj.GetNamespace()).List(metav1.ListOptions{
+		FieldSelector: fields.SelectorFromSet(set).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(list.Items, func(i, j int) bool {
+		return list.Items[i].LastTimestamp.Before(&list.Items[j].LastTimestamp)
+	})
+	return list.Items, nil
+}
+
+// groupEvents groups events, oldest first, by type and reason. The groups are ordered by
+// when they were last seen, oldest first, and hold the source and message of their latest
+// event.
+func groupEvents(events []corev1.Event) []eventGroup {
+	type seenGroup struct {
+		eventGroup
+		first, last metav1.Time
+	}
+	var seen []*seenGroup
+	byReason := map[string]*seenGroup{}
+	for _, e := range events {
+		first := e.FirstTimestamp
+		if first.IsZero() {
+			first = e.LastTimestamp
+		}
+		// Events that haven't been deduplicated by the server have no count.
+		count := e.Count
+		if count < 1 {
+			count = 1
+		}
+		key := e.Type + "/" + e.Reason
+		g, found := byReason[key]
+		if !found {
+			g = &seenGroup{eventGroup: eventGroup{Type: e.Type, Reason: e.Reason}, first: first}
+			byReason[key] = g
+			seen = append(seen, g)
+		}
+		if first.Before(&g.first) {
+			g.first = first
+		}
+		g.Count += count
+		g.last = e.LastTimestamp
+		g.From = eventSource(e.Source)
+		g.Message = strings.TrimSpace(e.Message)
+	}
+	sort.SliceStable(seen, func(i, j int) bool {
+		return seen[i].last.Before(&seen[j].last)
+	})
+
+	groups := []eventGroup{}
+	for _, g := range seen {
+		g.FirstSeen = output.TranslateTimestamp(g.first)
+		g.LastSeen = output.TranslateTimestamp(g.last)
+		groups = append(groups, g.eventGroup)
+	}
+	return groups
+}
+
+func eventSource(source corev1.EventSource) string {
+	if source.Host == "" {
+		return source.Component
+	}
+	return source.Component + ", " + source.Host
+}
+
+// printDescription prints d as text.
+func printDescription(out io.Writer, d *description) error {
+	obj := d.Object
+	tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Name:\t%s\n", obj.GetName())
+	if ns := obj.GetNamespace(); ns != "" {
+		fmt.Fprintf(tw, "Namespace:\t%s\n", ns)
+	}
+	fmt.Fprintf(tw, "Kind:\t%s (%s)\n", obj.GetKind(), obj.GetAPIVersion())
+	printMap(tw, "Labels", obj.GetLabels())
+	printMap(tw, "Annotations", obj.GetAnnotations())
+	fmt.Fprintf(tw, "Created:\t%s (%s ago)\n", obj.GetCreationTimestamp().UTC().Format("2006-01-02T15:04:05Z"), d.Computed.Age)
+	if d.Computed.Ready != "" {
+		fmt.Fprintf(tw, "Ready:\t%s\n", d.Computed.Ready)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions"); len(conditions) > 0 {
+		fmt.Fprintln(out, "Conditions:")
+		tw = tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "  TYPE\tSTATUS\tREASON")
+		for _, c := range conditions {
+			if condition, ok := c.(map[string]interface{}); ok {
+				fmt.Fprintf(tw, "  %v\t%v\t%v\n", condition["type"], condition["status"], valueOrNone(condition["reason"]))
+			}
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(d.Computed.Events) == 0 {
+		fmt.Fprintln(out, "Events:\t<none>")
+		return nil
+	}
+	fmt.Fprintln(out, "Events:")
+	tw = tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	if !describeAllEvents {
+		fmt.Fprintln(tw, "  TYPE\tREASON\tCOUNT\tFIRST SEEN\tLAST SEEN\tFROM\tMESSAGE")
+		for _, g := range d.Computed.EventsByReason {
+			fmt.Fprintf(tw, "  %s\t%s\t%d\t%s\t%s\t%s\t%s\n", g.Type, g.Reason, g.Count, g.FirstSeen, g.LastSeen, g.From, g.Message)
+		}
+		return tw.Flush()
+	}
+	fmt.Fprintln(tw, "  TYPE\tREASON\tAGE\tFROM\tMESSAGE")
+	for _, e := range d.Computed.Events {
+		age := e.Age
+		if e.Count > 1 {
+			age = fmt.Sprintf("%s (x%d)", e.Age, e.Count)
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n", e.Type, e.Reason, age, e.From, e.Message)
+	}
+	return tw.Flush()
+}
+
+// printMap prints the sorted entries of m under title, one per line.
+func printMap(w io.Writer, title string, m map[string]string) {
+	if len(m) == 0 {
+		fmt.Fprintf(w, "%s:\t<none>\n", title)
+		return
+	}
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		if i == 0 {
+			fmt.Fprintf(w, "%s:\t%s=%s\n", title, k, m[k])
+			continue
+		}
+		fmt.Fprintf(w, "\t%s=%s\n", k, m[k])
+	}
+}
+
+func valueOrNone(v interface{}) interface{} {
+	if v == nil || v == "" {
+		return "<none>"
+	}
+	return v
+}
+
+func init() {
+	rootCmd.AddCommand(describeCmd)
+
+	describeCmd.Flags().StringVarP(&describeSelector, "selector", "l", "", "label selector of the objects to describe, supports '=', '==', and '!=' (e.g. -l key1=value1,key2=value2)")
+	describeCmd.Flags().StringVarP(&describeOutput, "output", "o", "", "output format, one of: json|yaml, the default prints text")
+	describeCmd.Flags().BoolVar(&describeIgnoreNotFound, "ignore-not-found", false, "don't fail when named objects don't exist")
+	describeCmd.Flags().BoolVar(&describeAllEvents, "all-events", false, "list every event about the objects rather than grouping them by type and reason")
+}
@@ -0,0 +1,59 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"sort"
+
+	"go.uber.org/zap"
+	"k8s.io/client-go/discovery"
+)
+
+var showDiscoveryErrors bool
+
+// discoveryError is the failure to discover the resources of a group version.
+type discoveryError struct {
+	GroupVersion string `json:"groupVersion"`
+	Error        string `json:"error"`
+}
+
+// discoveryErrors returns the group versions that failed discovery in err, sorted by
+// group version, along with why each failed. Unavailable aggregated APIs are explained
+// in terms of the service backing them.
+func discoveryErrors(err error) []discoveryError {
+	failed, ok := err.(*discovery.ErrGroupDiscoveryFailed)
+	if !ok {
+		return nil
+	}
+	errs := []discoveryError{}
+	for gv, gvErr := range failed.Groups {
+		errs = append(errs, discoveryError{GroupVersion: gv.String(), Error: discoveryFailedError(gv, gvErr).Error()})
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].GroupVersion < errs[j].GroupVersion })
+	return errs
+}
+
+// logDiscoveryFailure warns that discovery of some API groups failed. With
+// --show-discovery-errors, each failing group version is logged with its error.
+func logDiscoveryFailure(err error) {
+	logRawError(err)
+	if !showDiscoveryErrors {
+		logger.Warn("failed to discover some API groups, pass --show-discovery-errors for details", errorFields(err)...)
+		return
+	}
+	for _, e := range discoveryErrors(err) {
+		logger.Warn("failed to discover API group version", zap.String("groupVersion", e.GroupVersion), zap.String("error", e.Error))
+	}
+}
@@ -0,0 +1,295 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// mirrorPodAnnotation marks the mirror pods of static pods, which can't be evicted.
+const mirrorPodAnnotation = "kubernetes.io/config.mirror"
+
+var (
+	nodesSelector    string
+	drainGracePeriod int
+	drainTimeout     time.Duration
+	drainForce       bool
+)
+
+// cordonCmd represents the cordon command
+var cordonCmd = &cobra.Command{
+	Use:   "cordon [NODE...]",
+	Short: "Mark nodes as unschedulable",
+	Long: `Mark nodes as unschedulable, by name or label selector. For example:
+
+  kube-client-template cordon node-1 node-2
+  kube-client-template cordon -l pool=old`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runNodeOperation(args, "cordoned", "", func(node *corev1.Node, result *nodeResult) error {
+			return setUnschedulable(node, true, result)
+		})
+	},
+}
+
+// uncordonCmd represents the uncordon command
+var uncordonCmd = &cobra.Command{
+	Use:   "uncordon [NODE...]",
+	Short: "Mark nodes as schedulable",
+	Long: `Mark nodes as schedulable, by name or label selector. For example:
+
+  kube-client-template uncordon -l pool=old`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runNodeOperation(args, "uncordoned", "", func(node *corev1.Node, result *nodeResult) error {
+			return setUnschedulable(node, false, result)
+		})
+	},
+}
+
+// drainCmd represents the drain command
+var drainCmd = &cobra.Command{
+	Use:   "drain [NODE...]",
+	Short: "Cordon nodes and evict their pods",
+	Long: `Cordon nodes and evict their pods, by name or label selector.
+
+Pods are evicted with the eviction API, so that pod disruption budgets are
+respected. Evictions blocked by a disruption budget are retried until
+--timeout. Pods managed by daemon sets and the mirror pods of static pods are
+skipped, as they would be recreated on the node straight away. Pods not
+managed by a controller are skipped too, as nothing would recreate them
+elsewhere, unless --force is passed.
+
+Draining asks for confirmation, listing the nodes to be drained. Pass --yes
+to skip it, which is required when stdin is not a terminal.
+
+A row is printed for each node as it is drained, followed by a summary, unless
+--quiet is passed. Failures are reported at the end. For example:
+
+  kube-client-template drain -l pool=old --timeout=5m`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runNodeOperation(args, "drained", "drain", drainNode)
+	},
+}
+
+// nodeResult is the outcome of an operation on a single node.
+type nodeResult struct {
+	cordoned int
+	evicted  int
+	skipped  int
+	failed   int
+}
+
+// runNodeOperation applies op to the nodes named in args, or selected by -l, printing
+// a row of progress for each node and a summary at the end. If confirmVerb is set the
+// user is asked to confirm the operation first.
+func runNodeOperation(args []string, action, confirmVerb string, op func(node *corev1.Node, result *nodeResult) error) error {
+	nodes, err := selectNodes(args, nodesSelector)
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		fmt.Fprintln(os.Stderr, "No resources found.")
+		return nil
+	}
+	if confirmVerb != "" {
+		var names []string
+		for _, node := range nodes {
+			names = append(names, node.Name)
+		}
+		if err := confirm(fmt.Sprintf("%s %d nodes: %s", confirmVerb, len(nodes), strings.Join(names, ", "))); err != nil {
+			return err
+		}
+	}
+
+	width := len("NODE")
+	for _, node := range nodes {
+		if len(node.Name) > width {
+			width = len(node.Name)
+		}
+	}
+	row := fmt.Sprintf("%%-%ds   %%-8v   %%-7v   %%-7v   %%v\n", width)
+	if !quiet {
+		fmt.Fprintf(os.Stdout, row, "NODE", "CORDONED", "EVICTED", "SKIPPED", "FAILED")
+	}
+
+	var total nodeResult
+	var failedNodes int
+	for i := range nodes {
+		node := &nodes[i]
+		result := &nodeResult{}
+		if err := op(node, result); err != nil {
+			failedNodes++
+			logRawError(err)
+			logger.Error("failed to process node", append(errorFields(err), zap.String("node", node.Name))...)
+		}
+		if !quiet {
+			fmt.Fprintf(os.Stdout, row, node.Name, result.cordoned > 0, result.evicted, result.skipped, result.failed)
+		}
+		total.cordoned += result.cordoned
+		total.evicted += result.evicted
+		total.skipped += result.skipped
+		total.failed += result.failed
+	}
+
+	if !quiet {
+		fmt.Fprintf(os.Stdout, "\n%d of %d nodes %s: %d cordoned, %d pods evicted, %d skipped, %d failed\n",
+			len(nodes)-failedNodes, len(nodes), action, total.cordoned, total.evicted, total.skipped, total.failed)
+	}
+	if failedNodes > 0 {
+		return fmt.Errorf("failed to process %d of %d nodes", failedNodes, len(nodes))
+	}
+	return nil
+}
+
+// selectNodes returns the named nodes, or those matching selector.
+func selectNodes(names []string, selector string) ([]corev1.Node, error) {
+	if len(names) == 0 && selector == "" {
+		return nil, errors.New("must specify the names of the nodes, or a selector with -l")
+	}
+	if len(names) > 0 && selector != "" {
+		return nil, errors.New("names and a selector cannot both be specified")
+	}
+
+	client := kubeClient.CoreV1().Nodes()
+	if selector != "" {
+		list, err := client.List(metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, err
+		}
+		return list.Items, nil
+	}
+	var nodes []corev1.Node
+	for _, name := range names {
+		node, err := client.Get(name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, *node)
+	}
+	return nodes, nil
+}
+
+// setUnschedulable patches the unschedulable field of node, unless it already has the
+// wanted value. Nodes that are cordoned by the call are counted in result.
+func setUnschedulable(node *corev1.Node, unschedulable bool, result *nodeResult) error {
+	if node.Spec.Unschedulable == unschedulable {
+		logger.Debug("node already in wanted state", zap.String("node", node.Name), zap.Bool("unschedulable", unschedulable))
+		return nil
+	}
+	patch := fmt.Sprintf(`{"spec":{"unschedulable":%t}}`, unschedulable)
+	if _, err := kubeClient.CoreV1().Nodes().Patch(node.Name, types.StrategicMergePatchType, []byte(patch)); err != nil {
+		return err
+	}
+	if unschedulable {
+		result.cordoned++
+	}
+	return nil
+}
+
+// drainNode cordons node and evicts its pods, other than those that would be recreated
+// on the node.
+func drainNode(node *corev1.Node, result *nodeResult) error {
+	if err := setUnschedulable(node, true, result); err != nil {
+		return err
+	}
+
+	pods, err := kubeClient.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", node.Name).String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	opts := &metav1.DeleteOptions{}
+	if drainGracePeriod >= 0 {
+		gracePeriod := int64(drainGracePeriod)
+		opts.GracePeriodSeconds = &gracePeriod
+	}
+	var deadline time.Time
+	if drainTimeout > 0 {
+		deadline = time.Now().Add(drainTimeout)
+	}
+	for _, pod := range pods.Items {
+		if skip, reason := skipDrain(&pod); skip {
+			result.skipped++
+			logger.Debug("skipping pod", zap.String("node", node.Name), zap.String("namespace", pod.Namespace), zap.String("pod", pod.Name), zap.String("reason", reason))
+			continue
+		}
+		if metav1.GetControllerOf(&pod) == nil && !drainForce {
+			result.skipped++
+			logger.Warn("skipping pod not managed by a controller, as it wouldn't be recreated, pass --force to evict it anyway",
+				zap.String("node", node.Name), zap.String("namespace", pod.Namespace), zap.String("pod", pod.Name))
+			continue
+		}
+		err := evictPod(pod.Namespace, pod.Name, opts, deadline)
+		switch {
+		case err == nil:
+			result.evicted++
+		case apierrors.IsNotFound(err):
+			logger.Debug("ignoring pod that was not found", zap.String("namespace", pod.Namespace), zap.String("pod", pod.Name))
+		default:
+			result.failed++
+			logRawError(err)
+			logger.Error("failed to evict pod", append(errorFields(err),
+				zap.String("node", node.Name),
+				zap.String("namespace", pod.Namespace),
+				zap.String("pod", pod.Name),
+			)...)
+		}
+	}
+	if result.failed > 0 {
+		return fmt.Errorf("failed to evict %d pods", result.failed)
+	}
+	return nil
+}
+
+// skipDrain returns whether pod should be left on a drained node, and why.
+func skipDrain(pod *corev1.Pod) (bool, string) {
+	if _, found := pod.Annotations[mirrorPodAnnotation]; found {
+		return true, "mirror pod"
+	}
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return true, "terminated"
+	}
+	if ref := metav1.GetControllerOf(pod); ref != nil && ref.Kind == "DaemonSet" {
+		return true, "managed by a daemon set"
+	}
+	return false, ""
+}
+
+func init() {
+	rootCmd.AddCommand(cordonCmd)
+	rootCmd.AddCommand(uncordonCmd)
+	rootCmd.AddCommand(drainCmd)
+
+	for _, cmd := range []*cobra.Command{cordonCmd, uncordonCmd, drainCmd} {
+		cmd.Flags().StringVarP(&nodesSelector, "selector", "l", "", "label selector of the nodes, supports '=', '==', and '!=' (e.g. -l key1=value1,key2=value2)")
+	}
+	drainCmd.Flags().IntVar(&drainGracePeriod, "grace-period", -1, "seconds given to pods to terminate gracefully, -1 uses the pod's own grace period")
+	addConfirmFlag(drainCmd)
+	drainCmd.Flags().DurationVar(&drainTimeout, "timeout", 0, "how long to retry evictions blocked by pod disruption budgets, zero means try once")
+	drainCmd.Flags().BoolVar(&drainForce, "force", false, "evict pods that aren't managed by a controller, which won't be recreated")
+}
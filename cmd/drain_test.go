@@ -0,0 +1,78 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+// nodePod returns a pod on node-1, controlled by a replica set unless controller is empty.
+func nodePod(name, controller string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       corev1.PodSpec{NodeName: "node-1"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	if controller != "" {
+		isController := true
+		pod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: controller, Name: "owner", UID: "uid-owner", Controller: &isController}}
+	}
+	return pod
+}
+
+// TestDrainSkipsUnmanagedPods checks that pods without a controller are only evicted with
+// --force, and are counted as skipped otherwise.
+func TestDrainSkipsUnmanagedPods(t *testing.T) {
+	originalClient, originalForce := kubeClient, drainForce
+	defer func() { kubeClient, drainForce = originalClient, originalForce }()
+
+	for _, force := range []bool{false, true} {
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+		client := fake.NewSimpleClientset(node, nodePod("web", "ReplicaSet"), nodePod("agent", "DaemonSet"), nodePod("scratch", ""))
+		var evicted []string
+		client.PrependReactor("create", "pods", func(action clienttesting.Action) (bool, runtime.Object, error) {
+			if action.GetSubresource() != "eviction" {
+				return false, nil, nil
+			}
+			evicted = append(evicted, action.(clienttesting.CreateAction).GetObject().(metav1.Object).GetName())
+			return true, nil, nil
+		})
+		kubeClient, drainForce = client, force
+
+		result := &nodeResult{}
+		if err := drainNode(node, result); err != nil {
+			t.Fatalf("drainNode() with --force=%t failed: %v", force, err)
+		}
+		sort.Strings(evicted)
+		want, wantSkipped := []string{"web"}, 2
+		if force {
+			want, wantSkipped = []string{"scratch", "web"}, 1
+		}
+		if !reflect.DeepEqual(evicted, want) {
+			t.Errorf("--force=%t evicted %v, want %v", force, evicted, want)
+		}
+		if result.evicted != len(want) || result.skipped != wantSkipped {
+			t.Errorf("--force=%t counted %d evicted and %d skipped, want %d and %d", force, result.evicted, result.skipped, len(want), wantSkipped)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Exit codes returned when a command fails, so that scripts can tell categories of
+// failure apart.
+const (
+	// exitFailure is returned for all failures without a more specific exit code.
+	exitFailure = 1
+	// exitUnreachable is returned when the API server, or an API it serves, can't be reached.
+	exitUnreachable = 3
+	// exitUnauthorized is returned when the API server rejects the credentials, e.g. as
+	// a token has expired.
+	exitUnauthorized = 4
+)
+
+// exitError is an error that causes a specific exit code.
+type exitError struct {
+	err  error
+	code int
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+// withExitCode returns err annotated with the exit code it should cause.
+func withExitCode(err error, code int) error {
+	return &exitError{err: err, code: code}
+}
+
+// exitCode returns the exit code err should cause.
+func exitCode(err error) int {
+	if e, ok := err.(*exitError); ok {
+		return e.code
+	}
+	if apierrors.IsUnauthorized(err) {
+		return exitUnauthorized
+	}
+	return exitFailure
+}
+
+// connectionExitCode returns the exit code a failure to reach the API server with err
+// should cause: the server may be reachable but reject the credentials.
+func connectionExitCode(err error) int {
+	if code := exitCode(err); code == exitUnauthorized {
+		return code
+	}
+	return exitUnreachable
+}
+
+// logReloginHint tells the user to log in again if code is caused by rejected credentials.
+func logReloginHint(code int) {
+	if code == exitUnauthorized {
+		logger.Info("the API server rejected the credentials, which may have expired: log in again to refresh them")
+	}
+}
+
+// errorFields returns the log fields describing err. Errors returned by the API server
+// are expanded into their reason, message and the individual causes (with the field
+// path each cause applies to), rather than the single line returned by Error().
+func errorFields(err error) []zapcore.Field {
+	if e, ok := err.(*exitError); ok {
+		err = e.err
+	}
+	apiStatus, ok := err.(apierrors.APIStatus)
+	if !ok {
+		return []zapcore.Field{zap.Error(err)}
+	}
+
+	status := apiStatus.Status()
+	fields := []zapcore.Field{
+		zap.String("error", status.Message),
+		zap.String("reason", string(status.Reason)),
+		zap.Int32("code", status.Code),
+	}
+	if status.Details != nil && len(status.Details.Causes) > 0 {
+		fields = append(fields, zap.Array("causes", statusCauses(status.Details.Causes)))
+	}
+	return fields
+}
+
+// logRawError logs the unformatted err at debug level, keeping the full error
+// available when errorFields has been used to summarise it.
+func logRawError(err error) {
+	logger.Debug("raw error", zap.Reflect("error", err))
+}
+
+type statusCauses []metav1.StatusCause
+
+func (c statusCauses) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, cause := range c {
+		if err := enc.AppendObject(statusCause(cause)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type statusCause metav1.StatusCause
+
+func (c statusCause) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("type", string(c.Type))
+	if c.Field != "" {
+		enc.AddString("field", c.Field)
+	}
+	enc.AddString("message", c.Message)
+	return nil
+}
@@ -0,0 +1,41 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// TestConnectionExitCode checks that --check-connection tells rejected credentials apart
+// from an unreachable server.
+func TestConnectionExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"unauthorized", apierrors.NewUnauthorized("token expired"), exitUnauthorized},
+		{"unreachable", errors.New("dial tcp 127.0.0.1:6443: connect: connection refused"), exitUnreachable},
+		{"server error", apierrors.NewInternalError(errors.New("etcd is down")), exitUnreachable},
+	}
+	for _, test := range tests {
+		if got := connectionExitCode(test.err); got != test.want {
+			t.Errorf("%s: exit code is %d, want %d", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jimmidyson/kube-client-template/pkg/output"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+// eventSelectableFields are the fields of core events supported in field selectors by
+// the API server.
+var eventSelectableFields = []string{
+	"metadata.name",
+	"metadata.namespace",
+	"involvedObject.kind",
+	"involvedObject.namespace",
+	"involvedObject.name",
+	"involvedObject.uid",
+	"involvedObject.apiVersion",
+	"involvedObject.resourceVersion",
+	"involvedObject.fieldPath",
+	"reason",
+	"source",
+	"type",
+}
+
+// validateEventFieldSelector checks that selector only uses fields of events that the API
+// server can select on, so that a typo fails with the list of valid fields rather than a
+// bare bad request.
+func validateEventFieldSelector(selector string) error {
+	parsed, err := fields.ParseSelector(selector)
+	if err != nil {
+		return fmt.Errorf("invalid field selector %q: %v", selector, err)
+	}
+	for _, req := range parsed.Requirements() {
+		if !containsString(eventSelectableFields, req.Field) {
+			return fmt.Errorf("field %q is not selectable for events, must be one of: %s", req.Field, strings.Join(eventSelectableFields, ", "))
+		}
+	}
+	return nil
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// isEventMapping returns whether mapping is for core events.
+func isEventMapping(mapping *meta.RESTMapping) bool {
+	gvk := mapping.GroupVersionKind
+	return gvk.Group == "" && gvk.Kind == "Event"
+}
+
+// involvedObjectSelector resolves a TYPE/NAME reference in namespace and returns the
+// field selector matching events about that object, along with the object's UID.
+func involvedObjectSelector(ref, namespace string) (fields.Selector, types.UID, error) {
+	mapping, obj, err := getObjectRef(ref, namespace)
+	if err != nil {
+		return nil, "", err
+	}
+
+	set := fields.Set{
+		"involvedObject.uid":  string(obj.GetUID()),
+		"involvedObject.name": obj.GetName(),
+		"involvedObject.kind": mapping.GroupVersionKind.Kind,
+	}
+	if isNamespaced(mapping) {
+		set["involvedObject.namespace"] = obj.GetNamespace()
+	}
+	return fields.SelectorFromSet(set), obj.GetUID(), nil
+}
+
+// listEventsFor lists the events matching opts that are about the object with the given
+// UID. The involved object is selected server-side by forSelector; if the server does
+// not support those field selectors, events are filtered client-side instead, and
+// serverSide is false so that watches can be filtered the same way.
+func listEventsFor(client dynamic.ResourceInterface, opts metav1.ListOptions, forSelector fields.Selector, uid types.UID) (list *unstructured.UnstructuredList, serverSide bool, err error) {
+	serverOpts := opts
+	serverOpts.FieldSelector = andFieldSelectors(forSelector.String(), opts.FieldSelector)
+	list, err = listUnstructured(client, serverOpts)
+	if err == nil || !apierrors.IsBadRequest(err) {
+		return list, true, err
+	}
+
+	logger.Debug("involved object field selectors are not supported, filtering events client-side", zap.Error(err))
+	list, err = listUnstructured(client, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	var items []unstructured.Unstructured
+	for _, item := range list.Items {
+		if involvedObjectUID(&item) == uid {
+			items = append(items, item)
+		}
+	}
+	list.Items = items
+	return list, false, nil
+}
+
+// filterEventsFor filters the watch events of w to those about the object with the given
+// UID, for servers that don't support selecting events by involved object. Errors are
+// always passed on.
+func filterEventsFor(w watch.Interface, uid types.UID) watch.Interface {
+	return watch.Filter(w, func(event watch.Event) (watch.Event, bool) {
+		if event.Type == watch.Error {
+			return event, true
+		}
+		u, ok := event.Object.(*unstructured.Unstructured)
+		return event, ok && involvedObjectUID(u) == uid
+	})
+}
+
+// involvedObjectUID returns the UID of the object event is about.
+func involvedObjectUID(event *unstructured.Unstructured) types.UID {
+	uid, _, _ := unstructured.NestedString(event.Object, "involvedObject", "uid")
+	return types.UID(uid)
+}
+
+// andLabelSelectors joins label selectors so that all of them must match.
+func andLabelSelectors(selectors ...string) string {
+	return andFieldSelectors(selectors...)
+}
+
+// andFieldSelectors joins field selectors so that all of them must match.
+func andFieldSelectors(selectors ...string) string {
+	var nonEmpty []string
+	for _, s := range selectors {
+		if s != "" {
+			nonEmpty = append(nonEmpty, s)
+		}
+	}
+	return strings.Join(nonEmpty, ",")
+}
+
+// eventTable returns a TableFunc listing events, preceded by their namespace if
+// withNamespace is set. The wide format adds where events came from and how often they
+// recurred, to spot noisy conditions.
+func eventTable(withNamespace bool) output.TableFunc {
+	return func(obj runtime.Object, wide bool) (*output.Table, error) {
+		table := &output.Table{Columns: []string{"LAST SEEN", "TYPE", "REASON", "OBJECT", "MESSAGE"}}
+		if wide {
+			table.Columns = append(table.Columns, "SOURCE", "COUNT", "FIRST SEEN", "REPORTING CONTROLLER")
+		}
+		if withNamespace {
+			table.Columns = append([]string{"NAMESPACE"}, table.Columns...)
+		}
+		items := []runtime.Object{obj}
+		if meta.IsListType(obj) {
+			var err error
+			if items, err = meta.ExtractList(obj); err != nil {
+				return nil, err
+			}
+		}
+		for _, item := range items {
+			u, ok := item.(*unstructured.Unstructured)
+			if !ok {
+				return nil, fmt.Errorf("unexpected object type %T", item)
+			}
+			event := &corev1.Event{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, event); err != nil {
+				return nil, err
+			}
+			table.Rows = append(table.Rows, eventRow(event, wide, withNamespace))
+		}
+		return table, nil
+	}
+}
+
+func eventRow(event *corev1.Event, wide, withNamespace bool) []string {
+	lastSeen := event.LastTimestamp
+	if lastSeen.IsZero() {
+		lastSeen = metav1.NewTime(event.EventTime.Time)
+	}
+	involved := strings.ToLower(event.InvolvedObject.Kind) + "/" + event.InvolvedObject.Name
+	row := []string{output.TranslateTimestamp(lastSeen), event.Type, event.Reason, involved, strings.TrimSpace(event.Message)}
+	if wide {
+		count := event.Count
+		if event.Series != nil {
+			count = event.Series.Count
+		}
+		reporting := event.ReportingController
+		if reporting == "" {
+			reporting = "<none>"
+		}
+		row = append(row, eventSource(event.Source), fmt.Sprint(count), output.TranslateTimestamp(event.FirstTimestamp), reporting)
+	}
+	if withNamespace {
+		row = append([]string{event.Namespace}, row...)
+	}
+	return row
+}
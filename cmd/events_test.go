@@ -0,0 +1,116 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+// eventAbout returns an event named name about the object with the given UID.
+func eventAbout(name, uid string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion":     "v1",
+		"kind":           "Event",
+		"metadata":       map[string]interface{}{"name": name, "namespace": "default"},
+		"involvedObject": map[string]interface{}{"kind": "Pod", "name": "web", "uid": uid},
+	}}
+}
+
+// eventsClient lists events, rejecting involved object field selectors unless
+// selectsInvolvedObject is set, as old servers do.
+type eventsClient struct {
+	dynamic.ResourceInterface
+	events                []*unstructured.Unstructured
+	selectsInvolvedObject bool
+}
+
+func (c *eventsClient) List(opts metav1.ListOptions) (runtime.Object, error) {
+	list := &unstructured.UnstructuredList{Object: map[string]interface{}{"apiVersion": "v1", "kind": "EventList"}}
+	if strings.Contains(opts.FieldSelector, "involvedObject") {
+		if !c.selectsInvolvedObject {
+			return nil, apierrors.NewBadRequest("field label not supported: involvedObject.uid")
+		}
+		selector, err := fields.ParseSelector(opts.FieldSelector)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range c.events {
+			if selector.Matches(fields.Set{"involvedObject.uid": string(involvedObjectUID(event))}) {
+				list.Items = append(list.Items, *event)
+			}
+		}
+		return list, nil
+	}
+	for _, event := range c.events {
+		list.Items = append(list.Items, *event)
+	}
+	return list, nil
+}
+
+func TestListEventsFor(t *testing.T) {
+	for _, serverSide := range []bool{true, false} {
+		client := &eventsClient{
+			events:                []*unstructured.Unstructured{eventAbout("web.1", "uid-web"), eventAbout("api.1", "uid-api"), eventAbout("web.2", "uid-web")},
+			selectsInvolvedObject: serverSide,
+		}
+		list, gotServerSide, err := listEventsFor(client, metav1.ListOptions{}, fields.OneTermEqualSelector("involvedObject.uid", "uid-web"), "uid-web")
+		if err != nil {
+			t.Fatalf("listEventsFor() failed: %v", err)
+		}
+		if gotServerSide != serverSide {
+			t.Errorf("listEventsFor() selected server-side = %t, want %t", gotServerSide, serverSide)
+		}
+		var names []string
+		for _, item := range list.Items {
+			names = append(names, item.GetName())
+		}
+		if got := strings.Join(names, ","); got != "web.1,web.2" {
+			t.Errorf("listEventsFor() with server-side selection %t listed %s, want web.1,web.2", serverSide, got)
+		}
+	}
+}
+
+func TestFilterEventsFor(t *testing.T) {
+	source := watch.NewFake()
+	go func() {
+		source.Add(eventAbout("web.1", "uid-web"))
+		source.Add(eventAbout("api.1", "uid-api"))
+		source.Modify(eventAbout("web.1", "uid-web"))
+		source.Error(&metav1.Status{Status: metav1.StatusFailure, Message: "expired"})
+		source.Stop()
+	}()
+
+	w := filterEventsFor(source, "uid-web")
+	var got []string
+	for event := range w.ResultChan() {
+		name := "status"
+		if u, ok := event.Object.(*unstructured.Unstructured); ok {
+			name = u.GetName()
+		}
+		got = append(got, string(event.Type)+" "+name)
+	}
+	if want := "ADDED web.1,MODIFIED web.1,ERROR status"; strings.Join(got, ",") != want {
+		t.Errorf("filterEventsFor() passed on %s, want %s", strings.Join(got, ","), want)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jimmidyson/kube-client-template/pkg/output"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// exitCondition compares the result of a JSONPath template applied to an object with an
+// expected value, which is itself a template so that it can refer to other fields, e.g.
+// jsonpath={.status.availableReplicas}=={.spec.replicas}.
+type exitCondition struct {
+	expr   string
+	actual *output.JSONPathPrinter
+	// expected is nil when the expected value is empty.
+	expected *output.JSONPathPrinter
+}
+
+// parseExitCondition parses an --exit-on expression of the form jsonpath=TEMPLATE==EXPECTED.
+func parseExitCondition(expr string) (*exitCondition, error) {
+	const prefix = "jsonpath="
+	if !strings.HasPrefix(expr, prefix) {
+		return nil, fmt.Errorf("invalid exit condition %q: must be of the form jsonpath=...==expected", expr)
+	}
+	// The template may contain == in a filter, the expected value is less likely to.
+	i := strings.LastIndex(expr, "==")
+	if i < len(prefix) {
+		return nil, fmt.Errorf("invalid exit condition %q: must be of the form jsonpath=...==expected", expr)
+	}
+	// Fields that aren't set are often omitted, e.g. the available replicas of a
+	// deployment with none, so missing fields evaluate as empty.
+	actual, err := output.NewJSONPathPrinter(expr[len(prefix):i])
+	if err != nil {
+		return nil, err
+	}
+	c := &exitCondition{expr: expr, actual: actual.AllowMissingKeys(true)}
+	// An empty expected value is allowed, and matches an empty result.
+	if expected := expr[i+2:]; expected != "" {
+		if c.expected, err = output.NewJSONPathPrinter(expected); err != nil {
+			return nil, err
+		}
+		c.expected.AllowMissingKeys(true)
+	}
+	return c, nil
+}
+
+// check returns nil if the condition holds for every object, or an error causing
+// exitFailure otherwise.
+func (c *exitCondition) check(objs []unstructured.Unstructured) error {
+	if len(objs) == 0 {
+		return withExitCode(errors.New("exit condition not met: no objects found"), exitFailure)
+	}
+	var failed int
+	for i := range objs {
+		obj := &objs[i]
+		actual, expected, err := c.evaluate(obj)
+		if err != nil {
+			failed++
+			logger.Info("exit condition could not be evaluated",
+				zap.String("kind", obj.GetKind()),
+				zap.String("name", obj.GetName()),
+				zap.Error(err),
+			)
+			continue
+		}
+		if actual != expected {
+			failed++
+			logger.Info("exit condition not met",
+				zap.String("kind", obj.GetKind()),
+				zap.String("name", obj.GetName()),
+				zap.String("actual", actual),
+				zap.String("expected", expected),
+			)
+		}
+	}
+	if failed > 0 {
+		return withExitCode(fmt.Errorf("exit condition %s not met by %d of %d objects", c.expr, failed, len(objs)), exitFailure)
+	}
+	return nil
+}
+
+func (c *exitCondition) evaluate(obj *unstructured.Unstructured) (string, string, error) {
+	var actual, expected bytes.Buffer
+	if err := c.actual.PrintObj(obj, &actual); err != nil {
+		return "", "", err
+	}
+	if c.expected != nil {
+		if err := c.expected.PrintObj(obj, &expected); err != nil {
+			return "", "", err
+		}
+	}
+	return actual.String(), expected.String(), nil
+}
+
+// recordingPrinter records the objects it is passed so that they can be checked after
+// printing, and only prints them if it has a delegate.
+type recordingPrinter struct {
+	delegate output.Printer
+	objs     []unstructured.Unstructured
+}
+
+func (p *recordingPrinter) PrintObj(obj runtime.Object, w io.Writer) error {
+	switch t := obj.(type) {
+	case *unstructured.Unstructured:
+		p.objs = append(p.objs, *t)
+	case *unstructured.UnstructuredList:
+		p.objs = append(p.objs, t.Items...)
+	}
+	if p.delegate == nil {
+		return nil
+	}
+	return p.delegate.PrintObj(obj, w)
+}
@@ -0,0 +1,99 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// exitOnDeployment returns a deployment named name with replicas, and available
+// replicas unless available is negative, in which case the field is omitted as the
+// API server does when there are none.
+func exitOnDeployment(name string, replicas, available int64) unstructured.Unstructured {
+	obj := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": name},
+		"spec":       map[string]interface{}{"replicas": replicas},
+		"status":     map[string]interface{}{},
+	}}
+	if available >= 0 {
+		_ = unstructured.SetNestedField(obj.Object, available, "status", "availableReplicas")
+	}
+	return obj
+}
+
+func TestExitCondition(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		objs []unstructured.Unstructured
+		// wantErr is part of the error the check fails with, or empty if it holds.
+		wantErr string
+	}{
+		{
+			name: "met",
+			expr: "jsonpath={.status.availableReplicas}=={.spec.replicas}",
+			objs: []unstructured.Unstructured{exitOnDeployment("web", 2, 2)},
+		},
+		{
+			name:    "missing field isn't met",
+			expr:    "jsonpath={.status.availableReplicas}=={.spec.replicas}",
+			objs:    []unstructured.Unstructured{exitOnDeployment("web", 2, -1)},
+			wantErr: "not met by 1 of 1 objects",
+		},
+		{
+			name:    "every object is checked after a missing field",
+			expr:    "jsonpath={.status.availableReplicas}=={.spec.replicas}",
+			objs:    []unstructured.Unstructured{exitOnDeployment("web", 2, -1), exitOnDeployment("api", 1, 1), exitOnDeployment("db", 3, 1)},
+			wantErr: "not met by 2 of 3 objects",
+		},
+		{
+			name: "missing field matches an empty expected value",
+			expr: "jsonpath={.status.availableReplicas}==",
+			objs: []unstructured.Unstructured{exitOnDeployment("web", 0, -1)},
+		},
+		{
+			name: "missing field in the expected value",
+			expr: "jsonpath={.status.availableReplicas}=={.status.readyReplicas}",
+			objs: []unstructured.Unstructured{exitOnDeployment("web", 0, -1)},
+		},
+		{
+			name:    "no objects",
+			expr:    "jsonpath={.spec.replicas}==1",
+			wantErr: "no objects found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := parseExitCondition(tt.expr)
+			if err != nil {
+				t.Fatalf("parseExitCondition() failed: %v", err)
+			}
+			err = c.check(tt.objs)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("check() failed: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("check() = %v, want an error containing %q", err, tt.wantErr)
+			case tt.wantErr != "" && exitCode(err) != exitFailure:
+				t.Errorf("check() exits with %d, want %d", exitCode(err), exitFailure)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// flagRule rejects a combination of flags that would otherwise produce wrong output.
+type flagRule struct {
+	// flags are the flags that can't all be set at once.
+	flags []string
+	// when, if set, further restricts the rule to when it returns true, e.g. for a
+	// particular flag value.
+	when func() bool
+	// requires, if set, describes what the flags can only be used with, for rules whose
+	// when reports that it's missing.
+	requires string
+	// reason explains why the flags can't be combined.
+	reason string
+}
+
+// check returns an error if all of the rule's flags are set in flags.
+func (r flagRule) check(flags *pflag.FlagSet) error {
+	for _, name := range r.flags {
+		if !flags.Changed(name) {
+			return nil
+		}
+	}
+	if r.when != nil && !r.when() {
+		return nil
+	}
+	if r.requires != "" {
+		return fmt.Errorf("--%s can only be used with %s: %s", strings.Join(r.flags, " and --"), r.requires, r.reason)
+	}
+	return fmt.Errorf("--%s cannot be combined: %s", strings.Join(r.flags, " and --"), r.reason)
+}
+
+// checkFlagRules returns a PreRunE function rejecting the flag combinations in rules, so
+// that invalid combinations fail before any request is made.
+func checkFlagRules(rules []flagRule) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		for _, rule := range rules {
+			if err := rule.check(cmd.Flags()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// The flag rules of each command are kept together here, rather than spread through the
+// commands, so that they can be reviewed and tested as a whole.
+var (
+	getFlagRules = []flagRule{
+		{flags: []string{"namespaces", "all-namespaces"}, when: func() bool { return getAllNamespaces }, reason: "they are mutually exclusive"},
+		{flags: []string{"namespaces", "for"}, reason: "the object is only looked up in the current namespace"},
+		{flags: []string{"watch", "subresource"}, when: func() bool { return getWatch }, reason: "subresources can't be watched"},
+		{flags: []string{"watch-only", "subresource"}, when: func() bool { return getWatchOnly }, reason: "subresources can't be watched"},
+		{flags: []string{"watch", "server-print"}, when: func() bool { return getWatch && getServerPrint }, reason: "watches are always rendered client-side"},
+		{flags: []string{"watch-only", "server-print"}, when: func() bool { return getWatchOnly && getServerPrint }, reason: "watches are always rendered client-side"},
+		{flags: []string{"subresource", "server-print"}, when: func() bool { return getServerPrint }, reason: "subresources are always rendered client-side"},
+		{flags: []string{"sort-by", "server-print"}, when: func() bool { return getServerPrint }, reason: "sorted lists are always rendered client-side"},
+		{flags: []string{"watch-only", "sort-by"}, reason: "there is no list to sort"},
+		{flags: []string{"watch", "sort-by"}, when: func() bool { return getWatch }, reason: "only the initial list would be sorted, changes are printed as they happen"},
+		{flags: []string{"watch", "exit-on"}, reason: "the condition is evaluated once the objects have been read"},
+		{flags: []string{"watch-only", "exit-on"}, reason: "the condition is evaluated once the objects have been read"},
+		{flags: []string{"clean"}, when: func() bool { return getClean && getOutput != "json" && getOutput != "yaml" }, requires: "--output json or yaml", reason: "only whole objects can be applied again"},
+		{flags: []string{"summary"}, when: func() bool { return getSummary && !getWatch && !getWatchOnly }, requires: "--watch or --watch-only", reason: "only watch events are counted"},
+		{flags: []string{"dedup"}, when: func() bool { return getDedup && !getWatch && !getWatchOnly }, requires: "--watch or --watch-only", reason: "only watch events are deduplicated"},
+		{flags: []string{"summary", "dedup"}, when: func() bool { return getSummary && getDedup }, reason: "objects aren't printed with --summary"},
+		// The output format is checked once --template and the configured default have
+		// been applied to it, so the rule doesn't depend on which flag selected it.
+		{flags: []string{"watch"}, when: func() bool { return getWatch && !getWatchOnly && isTemplateOutput(getOutput) }, requires: "output formats other than templates", reason: "the template would be applied to the initial list and then to each changed object, use --watch-only instead"},
+	}
+
+	canIFlagRules = []flagRule{
+		{flags: []string{"list", "all-namespaces"}, when: func() bool { return canIList && canIAllNamespaces }, reason: "rules can only be reviewed in a namespace, and those granted cluster-wide are listed in every namespace"},
+	}
+
+	logsFlagRules = []flagRule{
+		{flags: []string{"container", "all-containers"}, when: func() bool { return logsAllContainers }, reason: "they are mutually exclusive"},
+		{flags: []string{"container", "container-regex"}, reason: "they are mutually exclusive"},
+		{flags: []string{"container-regex", "all-containers"}, when: func() bool { return logsAllContainers }, reason: "they are mutually exclusive"},
+		{flags: []string{"since", "since-time"}, reason: "they are mutually exclusive"},
+	}
+)
+
+// isTemplateOutput returns whether output is a jsonpath, jsonpath-as-json or go-template
+// output format.
+func isTemplateOutput(output string) bool {
+	for _, prefix := range []string{"jsonpath=", "jsonpath-as-json=", "go-template="} {
+		if strings.HasPrefix(output, prefix) {
+			return true
+		}
+	}
+	return false
+}
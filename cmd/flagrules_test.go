@@ -0,0 +1,64 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestGetFlagRules checks that invalid combinations of get flags are rejected before any
+// object is read, and that valid ones aren't.
+func TestGetFlagRules(t *testing.T) {
+	tests := []struct {
+		args []string
+		// wantErr is part of the error the command fails with, or empty if it succeeds.
+		wantErr string
+	}{
+		{args: []string{"--summary"}, wantErr: "--summary can only be used with --watch or --watch-only"},
+		{args: []string{"--summary=false", "-o", "name"}},
+		{args: []string{"--dedup"}, wantErr: "--dedup can only be used with --watch or --watch-only"},
+		{args: []string{"--dedup=false", "-o", "name"}},
+		{args: []string{"--dedup", "--watch-only", "--summary"}, wantErr: "--summary and --dedup cannot be combined"},
+		{args: []string{"--clean"}, wantErr: "--clean can only be used with --output json or yaml"},
+		{args: []string{"--clean", "-o", "name"}, wantErr: "--clean can only be used with --output json or yaml"},
+		{args: []string{"--clean", "-o", "yaml"}},
+		{args: []string{"--clean=false", "-o", "name"}},
+		{args: []string{"-w", "-o", "jsonpath={.metadata.name}"}, wantErr: "--watch can only be used with output formats other than templates"},
+		{args: []string{"-w", "--template", "{{.metadata.name}}"}, wantErr: "--watch can only be used with output formats other than templates"},
+		{args: []string{"-w", "-o", "jsonpath", "--template", "{.metadata.name}"}, wantErr: "--watch can only be used with output formats other than templates"},
+		{args: []string{"-w", "--sort-by", ".metadata.name"}, wantErr: "--watch and --sort-by cannot be combined"},
+		{args: []string{"--watch=false", "--sort-by", ".metadata.name", "-o", "name"}},
+		{args: []string{"-o", "name"}},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+			server.addPod("web-1", nil)
+
+			result := runCommand(t, server, append([]string{"get", "pods"}, tt.args...)...)
+			switch {
+			case tt.wantErr == "" && result.err != nil:
+				t.Errorf("unexpected error: %v", result.err)
+			case tt.wantErr != "" && (result.err == nil || !strings.Contains(result.err.Error(), tt.wantErr)):
+				t.Errorf("error = %v, want it to contain %q", result.err, tt.wantErr)
+			}
+			if tt.wantErr != "" && result.stdout != "" {
+				t.Errorf("stdout = %q, want nothing printed", result.stdout)
+			}
+		})
+	}
+}
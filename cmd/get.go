@@ -0,0 +1,576 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jimmidyson/kube-client-template/pkg/kube"
+	"github.com/jimmidyson/kube-client-template/pkg/output"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+var (
+	getSelector       string
+	getFieldSelector  string
+	getAllNamespaces  bool
+	getOutput         string
+	getNoHeaders      bool
+	getWatch          bool
+	getWatchOnly      bool
+	getFor            string
+	getSubresource    string
+	getNamespaces     []string
+	getServerPrint    bool
+	getExitOn         string
+	getTemplate       string
+	getSummary        bool
+	getSummaryEvery   time.Duration
+	getDedup          bool
+	getSortBy         string
+	getClean          bool
+	getCleanFields    []string
+	getIgnoreNotFound bool
+)
+
+// getCmd represents the get command
+var getCmd = &cobra.Command{
+	Use:   "get TYPE[/NAME] [NAME...]",
+	Short: "Display one or many resources",
+	Long: `Display one or many resources.
+
+Resources can be filtered by label and field selectors, and changes can be
+watched after the initial list is printed. For example:
+
+  kube-client-template get pods -l app=nginx
+  kube-client-template get deployments.apps/nginx -o yaml
+  kube-client-template get pods --template '{{range .items}}{{.metadata.name}}{{"\n"}}{{end}}'
+  kube-client-template get pods -o jsonpath-as-json='{.items[*].metadata.name}'
+  kube-client-template get deployments -o custom-columns='NAME:.metadata.name,READY:ready(.status.readyReplicas,.spec.replicas),AGE:age(.metadata.creationTimestamp)'
+  kube-client-template get pods --watch-only
+  kube-client-template get pods --all-namespaces --watch-only --summary --summary-interval=1m
+  kube-client-template get pods --watch --dedup
+  kube-client-template get pods --namespaces frontend,backend
+  kube-client-template get events --for deployment/nginx
+  kube-client-template get pods --for deployment/nginx
+  kube-client-template get events --field-selector type=Warning
+  kube-client-template get events -o wide --sort-by=.count
+  kube-client-template get deployment/nginx --subresource=status -o yaml
+  kube-client-template get deployments -l app=shop -o yaml --clean
+  kube-client-template get deployment/nginx --exit-on 'jsonpath={.status.availableReplicas}=={.spec.replicas}'
+
+Named objects that can't be got are reported once the others have been
+printed, and with --ignore-not-found those that don't exist are skipped.
+
+With --for, events are those about the object, and pods are those managed by
+a workload, or the endpoints of a service, ready or not, when the command
+starts.
+
+With -o custom-columns=HEADER:FIELD,..., each FIELD is a JSONPath expression
+like .metadata.name, or one of these functions of JSONPath expressions:
+
+  age(TIMESTAMP)     the time since TIMESTAMP, as in AGE columns, e.g. 5m
+  ready(READY,TOTAL) the two counts as in READY columns, e.g. 2/3, with
+                     missing counts printed as 0
+
+With --summary, events are counted by type rather than printed, and the
+counts are printed every --summary-interval. The total is printed when the
+watch ends or the command is interrupted.
+
+With --dedup, an object that is modified without its printed output
+changing, e.g. when only its resource version or a field that isn't a
+column changes, isn't printed again. The resource version, managed fields
+and the probe and heartbeat times of status conditions are ignored in every
+output format. Objects that were listed before the watch started are
+compared with how they were listed. Added and deleted objects are always
+printed.
+
+Field selectors are applied by the server. Events support selecting on
+metadata.name, metadata.namespace, reason, source, type and the
+involvedObject fields kind, namespace, name, uid, apiVersion,
+resourceVersion and fieldPath, e.g. --field-selector type=Warning.
+
+Tables are rendered by the server where it supports it, so that the columns
+match those of kubectl. Use --server-print=false to render them client-side
+from the full objects instead. Watches, lists sorted with --sort-by and
+events are always rendered client-side. The wide format of events adds their
+source, count, first seen time and reporting controller.
+
+With --subresource=status only the status of objects is printed. Named
+objects are read from their status endpoint, lists are read from the
+resource itself as there is no list endpoint for subresources.
+
+With --exit-on the command exits 0 if the JSONPath template evaluates to the
+expected value for every object, and 1 otherwise. The expected value may be
+literal text or another template. Objects are only printed if --output is
+passed.`,
+	Args: cobra.MinimumNArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if !cmd.Flags().Changed("output") {
+			getOutput = viper.GetString("output")
+		}
+		if cmd.Flags().Changed("template") {
+			switch format := getOutput; {
+			case !cmd.Flags().Changed("output"), format == "go-template":
+				getOutput = "go-template=" + getTemplate
+			case format == "jsonpath", format == "jsonpath-as-json":
+				getOutput = format + "=" + getTemplate
+			default:
+				return fmt.Errorf("--template can only be combined with --output go-template, jsonpath or jsonpath-as-json, not %q", format)
+			}
+		}
+		return checkFlagRules(getFlagRules)(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		resourceArg, names, err := splitResourceArgs(args)
+		if err != nil {
+			return err
+		}
+
+		mapping, err := resourceMapping(resourceArg)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			err = aggregatedAPIError(mapping.GroupVersionKind.GroupVersion(), err)
+		}()
+		if len(getNamespaces) > 0 {
+			if !isNamespaced(mapping) {
+				return fmt.Errorf("%s is not namespaced and cannot be combined with --namespaces", mapping.Resource)
+			}
+			if len(names) > 1 {
+				return errors.New("at most one name can be combined with --namespaces")
+			}
+		}
+		ns := namespace
+		if getAllNamespaces {
+			ns = metav1.NamespaceAll
+		}
+		client, err := resourceClient(mapping, ns)
+		if err != nil {
+			return err
+		}
+		get := func(name string) (*unstructured.Unstructured, error) {
+			return client.Get(name, metav1.GetOptions{})
+		}
+		transforms := outputTransforms()
+		if getSubresource != "" {
+			if getSubresource != "status" {
+				return fmt.Errorf("unsupported subresource %q, only status is supported", getSubresource)
+			}
+			found, err := hasSubresource(mapping, getSubresource)
+			if err != nil {
+				return err
+			}
+			if !found {
+				return fmt.Errorf("%s does not have a %s subresource", mapping.Resource, getSubresource)
+			}
+			get = func(name string) (*unstructured.Unstructured, error) {
+				return readSubresource(mapping, ns, name, getSubresource)
+			}
+			transforms = append(transforms, output.StatusOnly)
+		}
+		if getClean {
+			for _, path := range getCleanFields {
+				if path == "" || strings.HasPrefix(path, ".") || strings.HasSuffix(path, ".") || strings.Contains(path, "..") {
+					return fmt.Errorf("invalid --clean-fields path %q: must be dot separated field names, e.g. metadata.uid", path)
+				}
+			}
+			transforms = append(transforms, output.RemoveFields(getCleanFields))
+		}
+		var exitOn *exitCondition
+		if getExitOn != "" {
+			if exitOn, err = parseExitCondition(getExitOn); err != nil {
+				return err
+			}
+		}
+		// Exit conditions are evaluated against the full objects, so they are only
+		// printed when an output format is asked for.
+		printing := exitOn == nil || cmd.Flags().Changed("output") || cmd.Flags().Changed("template")
+		watching := getWatch || getWatchOnly
+		var (
+			forSelector fields.Selector
+			forUID      types.UID
+		)
+		forPods := getSelector
+		// forPodNames, if not nil, are the only pods to print, for services.
+		var forPodNames sets.String
+		if getFor != "" {
+			if len(names) > 0 {
+				return errors.New("--for cannot be combined with object names")
+			}
+			switch {
+			case isEventMapping(mapping):
+				if forSelector, forUID, err = involvedObjectSelector(getFor, namespace); err != nil {
+					return err
+				}
+			case isPodMapping(mapping):
+				podSelector, podNames, err := podsFor(getFor, namespace)
+				if err != nil {
+					return err
+				}
+				if podSelector != nil {
+					forPods = andLabelSelectors(getSelector, podSelector.String())
+				}
+				forPodNames = podNames
+			default:
+				return errors.New("--for is only supported when getting events or pods")
+			}
+		}
+
+		// Pods selected by name are filtered client-side, so they can't be rendered by the
+		// server.
+		serverPrinting := getServerPrint && (getOutput == "" || getOutput == "wide") && exitOn == nil &&
+			!watching && !isEventMapping(mapping) && getSubresource == "" && len(getNamespaces) == 0 && getSortBy == "" &&
+			forPodNames == nil
+		if serverPrinting {
+			get = func(name string) (*unstructured.Unstructured, error) {
+				obj, err := getServerTable(mapping, ns, name, metav1.ListOptions{})
+				if err != nil {
+					return nil, err
+				}
+				u, ok := obj.(*unstructured.Unstructured)
+				if !ok {
+					return nil, fmt.Errorf("unexpected object type %T", obj)
+				}
+				return u, nil
+			}
+		}
+		withNamespace := (getAllNamespaces || len(getNamespaces) > 0) && isNamespaced(mapping)
+		var table output.TableFunc
+		if isEventMapping(mapping) {
+			table = eventTable(withNamespace)
+		}
+		printerFlags := withTableStyle(output.Flags{
+			Output:        getOutput,
+			NoHeaders:     getNoHeaders,
+			WithNamespace: withNamespace,
+			Table:         table,
+			ServerTables:  serverPrinting,
+			Transforms:    transforms,
+		})
+		printer, err := output.PrinterFor(printerFlags)
+		if err != nil {
+			return err
+		}
+		var dedup *deduper
+		if getDedup {
+			dedup = newDeduper(printerFlags)
+		}
+		if exitOn != nil {
+			recorder := &recordingPrinter{}
+			if printing {
+				recorder.delegate = printer
+			}
+			printer = recorder
+			defer func() {
+				if err == nil {
+					err = exitOn.check(recorder.objs)
+				}
+			}()
+		}
+
+		if getFieldSelector != "" && isEventMapping(mapping) {
+			if err := validateEventFieldSelector(getFieldSelector); err != nil {
+				return err
+			}
+		}
+
+		if !watching {
+			defer pageOutput(printing && (getOutput == "" || getOutput == "wide" || strings.HasPrefix(getOutput, "custom-columns=")))()
+		}
+
+		if !watching && len(names) > 0 && len(getNamespaces) == 0 {
+			return getNamed(get, printer, names)
+		}
+
+		opts := metav1.ListOptions{
+			LabelSelector: forPods,
+			FieldSelector: getFieldSelector,
+		}
+		if len(names) > 1 {
+			return errors.New("watch is only supported on individual resources and resource collections, but multiple names were specified")
+		}
+		if len(names) == 1 {
+			opts.FieldSelector = andFieldSelectors(fields.OneTermEqualSelector("metadata.name", names[0]).String(), opts.FieldSelector)
+		}
+		if len(getNamespaces) > 0 {
+			return getInNamespaces(mapping, opts, printer, watching, dedup)
+		}
+		if serverPrinting {
+			return listServerTable(mapping, ns, opts, printer)
+		}
+
+		var (
+			list          *unstructured.UnstructuredList
+			forServerSide bool
+		)
+		if forSelector != nil {
+			list, forServerSide, err = listEventsFor(client, opts, forSelector, forUID)
+			// Watches are scoped to the object the same way as the list was.
+			if forServerSide {
+				opts.FieldSelector = andFieldSelectors(forSelector.String(), opts.FieldSelector)
+			}
+		} else {
+			list, err = listUnstructured(client, opts)
+		}
+		if err != nil {
+			return err
+		}
+		if forPodNames != nil {
+			list.Items = filterByName(list.Items, forPodNames)
+		}
+
+		if !getWatchOnly && !getSummary {
+			if len(list.Items) == 0 && !watching {
+				fmt.Fprintln(os.Stderr, "No resources found.")
+				return nil
+			}
+			if getSortBy != "" {
+				if err := sortObjects(list.Items, getSortBy); err != nil {
+					return err
+				}
+			}
+			if err := printer.PrintObj(list, os.Stdout); err != nil {
+				return err
+			}
+			if err := dedup.seed(list.Items); err != nil {
+				return err
+			}
+		}
+		if !watching {
+			return nil
+		}
+
+		// Start watching from the version of the list so that no changes between the
+		// list and the watch are missed, and none of the listed objects are repeated.
+		opts.ResourceVersion = list.GetResourceVersion()
+		logger.Debug("starting watch", zap.String("resourceVersion", opts.ResourceVersion))
+		w, err := client.Watch(opts)
+		if err != nil {
+			return err
+		}
+		if forSelector != nil && !forServerSide {
+			w = filterEventsFor(w, forUID)
+		}
+		if forPodNames != nil {
+			w = filterWatchByName(w, forPodNames)
+		}
+		defer w.Stop()
+		return outputEvents(w, mapping, printer, dedup)
+	},
+}
+
+// listServerTable lists the objects matching opts as a Table rendered by the server.
+func listServerTable(mapping *meta.RESTMapping, namespace string, opts metav1.ListOptions, printer output.Printer) error {
+	obj, err := getServerTable(mapping, namespace, "", opts)
+	if err != nil {
+		return err
+	}
+	var empty bool
+	switch t := obj.(type) {
+	case *unstructured.Unstructured:
+		rows, _, _ := unstructured.NestedSlice(t.Object, "rows")
+		empty = output.IsServerTable(t) && len(rows) == 0
+	case *unstructured.UnstructuredList:
+		empty = len(t.Items) == 0
+	}
+	if empty {
+		fmt.Fprintln(os.Stderr, "No resources found.")
+		return nil
+	}
+	return printer.PrintObj(obj, os.Stdout)
+}
+
+// getInNamespaces lists, and optionally watches, the objects matching opts in each of
+// the namespaces given by --namespaces, merging the results.
+func getInNamespaces(mapping *meta.RESTMapping, opts metav1.ListOptions, printer output.Printer, watching bool, dedup *deduper) error {
+	lists, err := listInNamespaces(mapping, getNamespaces, opts)
+	if err != nil {
+		return err
+	}
+
+	if !getWatchOnly && !getSummary {
+		var items []unstructured.Unstructured
+		for _, list := range lists {
+			items = append(items, list.Items...)
+		}
+		if len(items) == 0 && !watching {
+			fmt.Fprintln(os.Stderr, "No resources found.")
+			return nil
+		}
+		if getSortBy != "" {
+			if err := sortObjects(items, getSortBy); err != nil {
+				return err
+			}
+		}
+		if err := printer.PrintObj(kube.NewList(items), os.Stdout); err != nil {
+			return err
+		}
+		if err := dedup.seed(items); err != nil {
+			return err
+		}
+	}
+	if !watching {
+		return nil
+	}
+
+	w, err := watchInNamespaces(mapping, getNamespaces, opts, lists)
+	if err != nil {
+		return err
+	}
+	defer w.Stop()
+	return outputEvents(w, mapping, printer, dedup)
+}
+
+func getNamed(get func(name string) (*unstructured.Unstructured, error), printer output.Printer, names []string) error {
+	result := kube.GetWith(get, names)
+	err := namedFailuresError(result, names, getIgnoreNotFound)
+	if len(result.List.Items) == 0 {
+		if err == nil {
+			fmt.Fprintln(os.Stderr, "No resources found.")
+		}
+		return err
+	}
+	if printErr := printer.PrintObj(result.Object(), os.Stdout); printErr != nil {
+		return printErr
+	}
+	return err
+}
+
+// namedFailuresError logs the named objects that couldn't be got into result, and returns
+// the error to fail with after the others are printed: the error itself if one object
+// failed, or how many failed. With ignoreNotFound, objects that don't exist aren't
+// failures.
+func namedFailuresError(result *kube.Result, names []string, ignoreNotFound bool) error {
+	var failures []kube.Failure
+	for _, failure := range result.Failures {
+		if ignoreNotFound && apierrors.IsNotFound(failure.Err) {
+			logger.Debug("ignoring object that was not found", zap.String("name", failure.Name))
+			continue
+		}
+		logRawError(failure.Err)
+		logger.Error("failed to get resource", append(errorFields(failure.Err), zap.String("name", failure.Name))...)
+		failures = append(failures, failure)
+	}
+	switch len(failures) {
+	case 0:
+		return nil
+	case 1:
+		return failures[0].Err
+	default:
+		return fmt.Errorf("failed to get %d of %d resources", len(failures), len(names))
+	}
+}
+
+// outputEvents prints the objects of watch events, or a summary of them with --summary.
+// With dedup, modified objects are only printed if they print differently.
+func outputEvents(w watch.Interface, mapping *meta.RESTMapping, printer output.Printer, dedup *deduper) error {
+	if getSummary {
+		summaryPrinter, err := output.PrinterFor(withTableStyle(output.Flags{NoHeaders: getNoHeaders}))
+		if err != nil {
+			return err
+		}
+		return summarizeEvents(w, mapping.Resource, getSummaryEvery, summaryPrinter)
+	}
+	return printEvents(w, printer, dedup)
+}
+
+// printEvents prints the objects of watch events until the watch is closed, skipping
+// those that dedup, if not nil, reports as unchanged.
+func printEvents(w watch.Interface, printer output.Printer, dedup *deduper) error {
+	for event := range w.ResultChan() {
+		if event.Type == watch.Error {
+			return watchError(event.Object)
+		}
+		if dedup != nil {
+			changed, err := dedup.changed(event)
+			if err != nil {
+				return err
+			}
+			if !changed {
+				if obj, err := meta.Accessor(event.Object); err == nil {
+					logger.Debug("skipping unchanged object", zap.String("name", obj.GetName()), zap.String("namespace", obj.GetNamespace()))
+				}
+				continue
+			}
+		}
+		if err := printer.PrintObj(event.Object, os.Stdout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listUnstructured lists the objects matching opts.
+func listUnstructured(client dynamic.ResourceInterface, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	result, err := kube.List(client, opts)
+	if err != nil {
+		return nil, err
+	}
+	return result.List, nil
+}
+
+// watchError converts the object of a watch error event to an error.
+func watchError(obj runtime.Object) error {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		status := &metav1.Status{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, status); err == nil {
+			return apierrors.FromObject(status)
+		}
+	}
+	return apierrors.FromObject(obj)
+}
+
+func init() {
+	rootCmd.AddCommand(getCmd)
+
+	getCmd.Flags().StringVarP(&getSelector, "selector", "l", "", "label selector to filter on, supports '=', '==', and '!=' (e.g. -l key1=value1,key2=value2)")
+	getCmd.Flags().StringVar(&getFieldSelector, "field-selector", "", "field selector to filter on, supports '=', '==', and '!=' (e.g. --field-selector key1=value1,key2=value2)")
+	getCmd.Flags().BoolVar(&getAllNamespaces, "all-namespaces", false, "list the requested objects across all namespaces")
+	getCmd.Flags().StringSliceVar(&getNamespaces, "namespaces", nil, "list the requested objects in each of these namespaces (e.g. --namespaces ns1,ns2)")
+	getCmd.Flags().StringVarP(&getOutput, "output", "o", "", "output format, one of: json|yaml|name|wide|custom-columns=...|jsonpath=...|jsonpath-as-json=...|go-template=...")
+	getCmd.Flags().BoolVar(&getNoHeaders, "no-headers", false, "don't print the headers of tables, including the counts of --summary")
+	getCmd.Flags().StringVar(&getTemplate, "template", "", "template to print with, a go-template unless --output is jsonpath or jsonpath-as-json")
+	getCmd.Flags().BoolVar(&getIgnoreNotFound, "ignore-not-found", false, "don't fail when named objects don't exist")
+	getCmd.Flags().BoolVarP(&getWatch, "watch", "w", false, "after listing/getting the requested objects, watch for changes")
+	getCmd.Flags().BoolVar(&getWatchOnly, "watch-only", false, "watch for changes to the requested objects, without listing/getting first")
+	getCmd.Flags().BoolVar(&getSummary, "summary", false, "when watching, periodically print the number of objects added, modified and deleted rather than the objects")
+	getCmd.Flags().BoolVar(&getDedup, "dedup", false, "when watching, don't print modified objects whose printed output hasn't changed")
+	getCmd.Flags().DurationVar(&getSummaryEvery, "summary-interval", defaultSummaryInterval, "how often to print the counts of --summary")
+	getCmd.Flags().BoolVar(&getServerPrint, "server-print", true, "render tables on the server where supported, rather than client-side from the full objects")
+	getCmd.Flags().StringVar(&getSubresource, "subresource", "", "only print the given subresource of objects, currently only status is supported")
+	getCmd.Flags().StringVar(&getExitOn, "exit-on", "", "exit 0 if every object matches the condition and 1 otherwise, in jsonpath=TEMPLATE==EXPECTED form")
+	getCmd.Flags().StringVar(&getSortBy, "sort-by", "", "sort listed objects by the field at this JSONPath expression, in ascending order (e.g. --sort-by=.count)")
+	getCmd.Flags().BoolVar(&getClean, "clean", false, "remove the fields set by the API server, listed by --clean-fields, so that printed objects can be applied again")
+	getCmd.Flags().StringSliceVar(&getCleanFields, "clean-fields", output.DefaultCleanFields, "the dot separated paths of the fields removed by --clean")
+	getCmd.Flags().StringVar(&getFor, "for", "", "only show events about, or pods managed by or backing, the object in TYPE/NAME form (e.g. --for deployment/nginx or --for service/nginx)")
+}
@@ -0,0 +1,415 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestMain(m *testing.M) {
+	// Functions tested without running a command log before logging is set up.
+	logger = zap.NewNop()
+	recordSliceFlags(rootCmd)
+	os.Exit(m.Run())
+}
+
+// fakeResources are the resources served by fakeServer, by group version and name.
+var fakeResources = map[string]map[string]string{
+	"v1": {
+		"pods":      "Pod",
+		"events":    "Event",
+		"services":  "Service",
+		"endpoints": "Endpoints",
+	},
+	"apps/v1": {
+		"deployments": "Deployment",
+	},
+}
+
+// fakeServer is an API server serving the fakeResources from memory, enough to run
+// commands against.
+type fakeServer struct {
+	*httptest.Server
+
+	mu sync.Mutex
+	// objects holds the objects of each resource, by namespace/name.
+	objects map[string]map[string]map[string]interface{}
+	// forbidden are the names of objects that every request for is rejected with
+	// Forbidden, to fail some objects of a bulk operation.
+	forbidden map[string]bool
+	// rulesReviews are the namespaces of the SelfSubjectRulesReviews received.
+	rulesReviews []string
+}
+
+// newFakeServer starts a fakeServer, which must be closed by the caller.
+func newFakeServer() *fakeServer {
+	s := &fakeServer{
+		objects:   map[string]map[string]map[string]interface{}{},
+		forbidden: map[string]bool{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// addPod adds a pod named name in the default namespace with labels.
+func (s *fakeServer) addPod(name string, labels map[string]string) {
+	s.add("pods", map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata": map[string]interface{}{
+			"name":              name,
+			"namespace":         metav1.NamespaceDefault,
+			"uid":               "uid-" + name,
+			"resourceVersion":   "1",
+			"creationTimestamp": "2018-01-01T00:00:00Z",
+			"labels":            labels,
+		},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{map[string]interface{}{"name": "app", "image": "nginx"}},
+		},
+	})
+}
+
+func (s *fakeServer) add(resource string, obj map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	metadata := obj["metadata"].(map[string]interface{})
+	if s.objects[resource] == nil {
+		s.objects[resource] = map[string]map[string]interface{}{}
+	}
+	s.objects[resource][fmt.Sprintf("%s/%s", metadata["namespace"], metadata["name"])] = obj
+}
+
+func (s *fakeServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/api":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"kind": "APIVersions", "versions": []string{"v1"}})
+		return
+	case "/apis":
+		groups := &metav1.APIGroupList{}
+		for groupVersion := range fakeResources {
+			if gv, _ := schema.ParseGroupVersion(groupVersion); gv.Group != "" {
+				version := metav1.GroupVersionForDiscovery{GroupVersion: groupVersion, Version: gv.Version}
+				groups.Groups = append(groups.Groups, metav1.APIGroup{Name: gv.Group, Versions: []metav1.GroupVersionForDiscovery{version}, PreferredVersion: version})
+			}
+		}
+		groups.Kind, groups.APIVersion = "APIGroupList", "v1"
+		writeJSON(w, http.StatusOK, groups)
+		return
+	case "/api/v1":
+		writeResourceList(w, "v1")
+		return
+	}
+	if groupVersion := strings.TrimPrefix(r.URL.Path, "/apis/"); fakeResources[groupVersion] != nil {
+		writeResourceList(w, groupVersion)
+		return
+	}
+
+	if r.URL.Path == "/apis/authorization.k8s.io/v1/selfsubjectrulesreviews" {
+		s.reviewRules(w, r)
+		return
+	}
+
+	// /api/v1/namespaces/NAMESPACE/RESOURCE[/NAME] or
+	// /apis/GROUP/VERSION/namespaces/NAMESPACE/RESOURCE[/NAME]
+	groupVersion, path := "v1", strings.TrimPrefix(r.URL.Path, "/api/v1")
+	if strings.HasPrefix(r.URL.Path, "/apis/") {
+		parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/apis/"), "/", 3)
+		if len(parts) == 3 {
+			groupVersion, path = parts[0]+"/"+parts[1], "/"+parts[2]
+		}
+	}
+	parts := strings.Split(strings.TrimPrefix(path, "/namespaces/"), "/")
+	kind, known := "", false
+	if len(parts) >= 2 {
+		kind, known = fakeResources[groupVersion][parts[1]]
+	}
+	if !strings.HasPrefix(path, "/namespaces/") || len(parts) > 3 || !known {
+		writeStatus(w, apierrors.NewNotFound(schema.GroupResource{}, r.URL.Path))
+		return
+	}
+	namespace, resource := parts[0], parts[1]
+	if len(parts) == 2 {
+		if r.Method != http.MethodGet {
+			writeStatus(w, apierrors.NewMethodNotSupported(schema.GroupResource{Resource: resource}, r.Method))
+			return
+		}
+		s.list(w, r, namespace, resource, groupVersion, kind)
+		return
+	}
+
+	name := parts[2]
+	groupResource := schema.GroupResource{Resource: resource}
+	if s.forbidden[name] {
+		writeStatus(w, apierrors.NewForbidden(groupResource, name, fmt.Errorf("denied by the fake server")))
+		return
+	}
+	key := namespace + "/" + name
+	obj, found := s.objects[resource][key]
+	if !found {
+		writeStatus(w, apierrors.NewNotFound(groupResource, name))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, obj)
+	case http.MethodDelete:
+		delete(s.objects[resource], key)
+		writeJSON(w, http.StatusOK, obj)
+	default:
+		writeStatus(w, apierrors.NewMethodNotSupported(groupResource, r.Method))
+	}
+}
+
+func (s *fakeServer) list(w http.ResponseWriter, r *http.Request, namespace, resource, groupVersion, kind string) {
+	selector, err := labels.Parse(r.URL.Query().Get("labelSelector"))
+	if err != nil {
+		writeStatus(w, apierrors.NewBadRequest(err.Error()))
+		return
+	}
+	items := []interface{}{}
+	for key, obj := range s.objects[resource] {
+		if !strings.HasPrefix(key, namespace+"/") {
+			continue
+		}
+		metadata := obj["metadata"].(map[string]interface{})
+		set := labels.Set{}
+		if objLabels, ok := metadata["labels"].(map[string]string); ok {
+			set = objLabels
+		}
+		if selector.Matches(set) {
+			items = append(items, obj)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"apiVersion": groupVersion,
+		"kind":       kind + "List",
+		"metadata":   map[string]interface{}{"resourceVersion": "1"},
+		"items":      items,
+	})
+}
+
+// reviewRules answers a SelfSubjectRulesReview with a rule granting get on pods,
+// rejecting reviews without a namespace as the API server does.
+func (s *fakeServer) reviewRules(w http.ResponseWriter, r *http.Request) {
+	review := &authorizationv1.SelfSubjectRulesReview{}
+	if err := json.NewDecoder(r.Body).Decode(review); err != nil {
+		writeStatus(w, apierrors.NewBadRequest(err.Error()))
+		return
+	}
+	s.rulesReviews = append(s.rulesReviews, review.Spec.Namespace)
+	if review.Spec.Namespace == "" {
+		writeStatus(w, apierrors.NewInvalid(schema.GroupKind{Group: "authorization.k8s.io", Kind: "SelfSubjectRulesReview"}, "",
+			field.ErrorList{field.Required(field.NewPath("spec", "namespace"), "")}))
+		return
+	}
+	review.Kind, review.APIVersion = "SelfSubjectRulesReview", "authorization.k8s.io/v1"
+	review.Status.ResourceRules = []authorizationv1.ResourceRule{{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods"}}}
+	writeJSON(w, http.StatusCreated, review)
+}
+
+// writeResourceList writes the discovery document of the fakeResources of groupVersion.
+func writeResourceList(w http.ResponseWriter, groupVersion string) {
+	list := &metav1.APIResourceList{GroupVersion: groupVersion}
+	for name, kind := range fakeResources[groupVersion] {
+		list.APIResources = append(list.APIResources, metav1.APIResource{
+			Name:       name,
+			Namespaced: true,
+			Kind:       kind,
+			Verbs:      metav1.Verbs{"get", "list", "watch", "delete"},
+		})
+	}
+	list.Kind, list.APIVersion = "APIResourceList", "v1"
+	writeJSON(w, http.StatusOK, list)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeStatus(w http.ResponseWriter, err *apierrors.StatusError) {
+	status := err.ErrStatus
+	status.Kind, status.APIVersion = "Status", "v1"
+	writeJSON(w, int(status.Code), status)
+}
+
+// commandResult is the outcome of running a command with runCommand.
+type commandResult struct {
+	stdout string
+	stderr string
+	err    error
+	code   int
+}
+
+// runCommand runs the root command with args against server, capturing what it writes
+// to stdout and stderr and the exit code it would exit with.
+func runCommand(t *testing.T, server *fakeServer, args ...string) commandResult {
+	dir, err := ioutil.TempDir("", "kube-client-template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	kubeconfig := filepath.Join(dir, "kubeconfig")
+	config := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: fake
+  cluster:
+    server: %s
+contexts:
+- name: fake
+  context:
+    cluster: fake
+    namespace: default
+current-context: fake
+users: []
+`, server.URL)
+	if err := ioutil.WriteFile(kubeconfig, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	resetFlags(rootCmd)
+	rootCmd.SetArgs(append(args, "--kubernetes-config", kubeconfig))
+
+	stdout, stderr := captureOutput(t, &os.Stdout), captureOutput(t, &os.Stderr)
+	_, err = rootCmd.ExecuteC()
+	result := commandResult{stdout: stdout(), stderr: stderr(), err: err}
+	if err != nil {
+		result.code = exitCode(err)
+	}
+	return result
+}
+
+// TestResetFlagsSlices checks that slice flags are reset to their defaults, so that
+// values set by one test don't leak into the next.
+func TestResetFlagsSlices(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	for _, ns := range []string{"shop", "web"} {
+		result := runCommand(t, server, "get", "pods", "--namespaces", ns, "-o", "name")
+		if result.err != nil {
+			t.Fatalf("get failed: %v", result.err)
+		}
+		if want := []string{ns}; !reflect.DeepEqual(getNamespaces, want) {
+			t.Errorf("--namespaces is %v, want %v", getNamespaces, want)
+		}
+	}
+	resetFlags(rootCmd)
+	if len(getNamespaces) != 0 {
+		t.Errorf("--namespaces is %v after resetting flags, want it empty", getNamespaces)
+	}
+}
+
+// captureOutput replaces *f with a pipe, returning a function that restores *f and
+// returns what was written to the pipe.
+func captureOutput(t *testing.T, f **os.File) func() string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := *f
+	*f = w
+	var buf bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(&buf, r)
+		close(done)
+	}()
+	return func() string {
+		*f = original
+		w.Close()
+		<-done
+		r.Close()
+		return buf.String()
+	}
+}
+
+// unsetSliceValues holds a copy of the value of each slice flag before it is first set.
+// Once set, slice values append to what they hold rather than replacing it, and don't
+// expose a way to be reset, so they are reset by restoring the copy.
+var unsetSliceValues = map[*pflag.Flag]reflect.Value{}
+
+// recordSliceFlags records the values of the slice flags of cmd and its subcommands in
+// unsetSliceValues, before any of them is set.
+func recordSliceFlags(cmd *cobra.Command) {
+	record := func(f *pflag.Flag) {
+		if !strings.HasSuffix(f.Value.Type(), "Slice") {
+			return
+		}
+		value := reflect.ValueOf(f.Value).Elem()
+		unset := reflect.New(value.Type()).Elem()
+		unset.Set(value)
+		unsetSliceValues[f] = unset
+	}
+	cmd.Flags().VisitAll(record)
+	cmd.PersistentFlags().VisitAll(record)
+	for _, c := range cmd.Commands() {
+		recordSliceFlags(c)
+	}
+}
+
+// resetFlags sets the flags of cmd and its subcommands that have been set back to their
+// defaults, as the commands are shared by all tests.
+func resetFlags(cmd *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		if !f.Changed {
+			return
+		}
+		if unset, ok := unsetSliceValues[f]; ok {
+			// An unset slice value replaces what it holds when set, so the default
+			// is set on the unset value, which is then restored again.
+			value := reflect.ValueOf(f.Value).Elem()
+			value.Set(unset)
+			_ = f.Value.Set(strings.TrimSuffix(strings.TrimPrefix(f.DefValue, "["), "]"))
+			value.Set(unset)
+		} else {
+			_ = f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+	for _, c := range cmd.Commands() {
+		resetFlags(c)
+	}
+}
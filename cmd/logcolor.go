@@ -0,0 +1,123 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var (
+	logColor      string
+	logColorTheme string
+)
+
+// colorTheme is the ANSI escape sequence each level and the logger name are colored with.
+// Levels without a color are printed plainly.
+type colorTheme struct {
+	levels map[zapcore.Level]string
+	name   string
+}
+
+// colorThemes are the themes selectable with --log-color-theme.
+var colorThemes = map[string]colorTheme{
+	"default": {
+		levels: map[zapcore.Level]string{
+			zapcore.DebugLevel:  "\x1b[35m",
+			zapcore.InfoLevel:   "\x1b[34m",
+			zapcore.WarnLevel:   "\x1b[33m",
+			zapcore.ErrorLevel:  "\x1b[31m",
+			zapcore.DPanicLevel: "\x1b[31m",
+			zapcore.PanicLevel:  "\x1b[31m",
+			zapcore.FatalLevel:  "\x1b[31m",
+		},
+		name: "\x1b[36m",
+	},
+	// solarized uses the accent colors of the Solarized palette from the 256 color set.
+	"solarized": {
+		levels: map[zapcore.Level]string{
+			zapcore.DebugLevel:  "\x1b[38;5;125m",
+			zapcore.InfoLevel:   "\x1b[38;5;33m",
+			zapcore.WarnLevel:   "\x1b[38;5;136m",
+			zapcore.ErrorLevel:  "\x1b[38;5;166m",
+			zapcore.DPanicLevel: "\x1b[38;5;160m",
+			zapcore.PanicLevel:  "\x1b[38;5;160m",
+			zapcore.FatalLevel:  "\x1b[38;5;160m",
+		},
+		name: "\x1b[38;5;37m",
+	},
+	// mono only highlights the levels that need attention, in bold.
+	"mono": {
+		levels: map[zapcore.Level]string{
+			zapcore.WarnLevel:   "\x1b[1m",
+			zapcore.ErrorLevel:  "\x1b[1m",
+			zapcore.DPanicLevel: "\x1b[1m",
+			zapcore.PanicLevel:  "\x1b[1m",
+			zapcore.FatalLevel:  "\x1b[1m",
+		},
+	},
+}
+
+const colorReset = "\x1b[0m"
+
+// colorEnabled returns whether console logs, written to stderr, are colored. NO_COLOR
+// disables color unless --color=always is passed.
+func colorEnabled() (bool, error) {
+	switch logColor {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		_, noColor := os.LookupEnv("NO_COLOR")
+		return !noColor && terminal.IsTerminal(int(os.Stderr.Fd())), nil
+	default:
+		return false, fmt.Errorf("invalid --color %q: must be one of auto|always|never", logColor)
+	}
+}
+
+// colorEncoders returns the level and name encoders of the theme named by
+// --log-color-theme. The colored strings are built once, rather than for each entry.
+func colorEncoders() (zapcore.LevelEncoder, zapcore.NameEncoder, error) {
+	theme, found := colorThemes[logColorTheme]
+	if !found {
+		return nil, nil, fmt.Errorf("invalid --log-color-theme %q: must be one of default|solarized|mono", logColorTheme)
+	}
+
+	levels := map[zapcore.Level]string{}
+	for l := zapcore.DebugLevel; l <= zapcore.FatalLevel; l++ {
+		levels[l] = l.CapitalString()
+		if color, ok := theme.levels[l]; ok {
+			levels[l] = color + levels[l] + colorReset
+		}
+	}
+	levelEncoder := func(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+		if s, ok := levels[l]; ok {
+			enc.AppendString(s)
+			return
+		}
+		enc.AppendString(l.CapitalString())
+	}
+	nameEncoder := zapcore.FullNameEncoder
+	if theme.name != "" {
+		nameEncoder = func(name string, enc zapcore.PrimitiveArrayEncoder) {
+			enc.AppendString(theme.name + name + colorReset)
+		}
+	}
+	return levelEncoder, nameEncoder, nil
+}
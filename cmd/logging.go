@@ -0,0 +1,102 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// loggersConfigKey is the config key mapping the names of loggers to their levels.
+const loggersConfigKey = "loggers"
+
+var (
+	// baseLogger logs everything, the root and named loggers filter it by their levels.
+	baseLogger *zap.Logger
+	// loggerLevels are the levels of named loggers, overriding --log-level.
+	loggerLevels = map[string]zap.AtomicLevel{}
+)
+
+// namedLogger returns a child of the root logger for a subsystem, e.g. "client", logging
+// at the level configured for it under loggers, or at --log-level otherwise.
+func namedLogger(name string) *zap.Logger {
+	level, ok := loggerLevels[name]
+	if !ok {
+		return logger.Named(name)
+	}
+	return baseLogger.Named(name).WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return &leveledCore{Core: c, level: level}
+	}))
+}
+
+// parseLoggerLevels reads the levels of named loggers from the loggers config key. In a
+// config file this is a map, e.g. "loggers: {client: debug}", while from the
+// environment it is a comma-separated list, e.g.
+// KUBE_CLIENT_TEMPLATE_LOGGERS=client=debug,discovery=warn.
+func parseLoggerLevels() (map[string]zap.AtomicLevel, error) {
+	levels := map[string]string{}
+	switch raw := viper.Get(loggersConfigKey).(type) {
+	case nil:
+	case string:
+		for _, pair := range strings.Split(raw, ",") {
+			if pair = strings.TrimSpace(pair); pair == "" {
+				continue
+			}
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("invalid logger level %q: must be in name=level form", pair)
+			}
+			levels[kv[0]] = kv[1]
+		}
+	default:
+		levels = viper.GetStringMapString(loggersConfigKey)
+	}
+
+	parsed := map[string]zap.AtomicLevel{}
+	for name, text := range levels {
+		level := zap.NewAtomicLevel()
+		if err := level.UnmarshalText([]byte(text)); err != nil {
+			return nil, fmt.Errorf("invalid level %q for logger %q: %v", text, name, err)
+		}
+		parsed[name] = level
+	}
+	return parsed, nil
+}
+
+// leveledCore filters the entries of a core by its own level, which may be more or less
+// verbose than the level of the core it wraps.
+type leveledCore struct {
+	zapcore.Core
+	level zap.AtomicLevel
+}
+
+func (c *leveledCore) Enabled(l zapcore.Level) bool {
+	return c.level.Enabled(l)
+}
+
+func (c *leveledCore) With(fields []zapcore.Field) zapcore.Core {
+	return &leveledCore{Core: c.Core.With(fields), level: c.level}
+}
+
+func (c *leveledCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.level.Enabled(entry.Level) {
+		return checked
+	}
+	return c.Core.Check(entry, checked)
+}
@@ -0,0 +1,306 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	logsSelector       string
+	logsContainer      string
+	logsContainerRegex string
+	logsAllContainers  bool
+	logsFollow         bool
+	logsTimestamps     bool
+	logsSince          time.Duration
+	logsSinceTime      string
+	logsOutput         string
+)
+
+// logsCmd represents the logs command
+var logsCmd = &cobra.Command{
+	Use:   "logs [POD] [-c CONTAINER]",
+	Short: "Print the logs of containers in pods",
+	Long: `Print the logs of containers in pods.
+
+Pods can be named, or selected by label. When the logs of more than one
+container are printed, each line is prefixed with the pod and container it
+came from. --since and --since-time apply to every selected container, so
+that the logs of many pods start from the same point.
+
+With --container-regex, the containers of every pod whose names match a
+regular expression are printed, e.g. the sidecars of pods that name them
+consistently. Pods without a matching container are skipped.
+
+With --output json each line is printed as a JSON object with the pod,
+container and message, and the time when --timestamps is set, ready to be
+ingested by structured logging systems. For example:
+
+  kube-client-template logs nginx-7c87f569d-5k2xq
+  kube-client-template logs -l app=nginx -f --timestamps -o json
+  kube-client-template logs -l app=nginx --all-containers --since-time=2018-03-01T10:00:00Z
+  kube-client-template logs -l app=shop --container-regex='^istio-' -f`,
+	Args:    cobra.MaximumNArgs(1),
+	PreRunE: checkFlagRules(logsFlagRules),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if (len(args) == 0) == (logsSelector == "") {
+			return errors.New("must specify either a pod name or a selector with -l")
+		}
+		if logsOutput != "" && logsOutput != "json" {
+			return fmt.Errorf("unsupported output format %q: must be json", logsOutput)
+		}
+		var containerRegex *regexp.Regexp
+		if logsContainerRegex != "" {
+			var err error
+			if containerRegex, err = regexp.Compile(logsContainerRegex); err != nil {
+				return fmt.Errorf("invalid --container-regex %q: %v", logsContainerRegex, err)
+			}
+		}
+
+		opts := &corev1.PodLogOptions{Follow: logsFollow, Timestamps: logsTimestamps}
+		if logsSinceTime != "" {
+			sinceTime, err := time.Parse(time.RFC3339, logsSinceTime)
+			if err != nil {
+				return fmt.Errorf("invalid --since-time %q: must be an RFC3339 timestamp, e.g. 2018-03-01T10:00:00Z", logsSinceTime)
+			}
+			opts.SinceTime = &metav1.Time{Time: sinceTime}
+		}
+		if logsSince != 0 {
+			seconds := int64(logsSince.Round(time.Second).Seconds())
+			opts.SinceSeconds = &seconds
+		}
+
+		pods, err := logsPods(args)
+		if err != nil {
+			return err
+		}
+		streams, err := logStreams(pods, containerRegex)
+		if err != nil {
+			return err
+		}
+		if len(streams) == 0 {
+			fmt.Fprintln(os.Stderr, "No resources found.")
+			return nil
+		}
+		return printLogs(streams, opts)
+	},
+}
+
+// logStream identifies the log of one container.
+type logStream struct {
+	pod       string
+	container string
+}
+
+func logsPods(args []string) ([]corev1.Pod, error) {
+	if len(args) == 1 {
+		pod, err := kubeClient.CoreV1().Pods(namespace).Get(args[0], metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return []corev1.Pod{*pod}, nil
+	}
+	list, err := kubeClient.CoreV1().Pods(namespace).List(metav1.ListOptions{LabelSelector: logsSelector})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// logStreams returns the container logs to print for pods. Pods with several containers
+// need either --container, --container-regex, matched by containerRegex, or
+// --all-containers.
+func logStreams(pods []corev1.Pod, containerRegex *regexp.Regexp) ([]logStream, error) {
+	var streams []logStream
+	for _, pod := range pods {
+		switch {
+		case logsContainer != "":
+			streams = append(streams, logStream{pod: pod.Name, container: logsContainer})
+		case containerRegex != nil:
+			for _, c := range pod.Spec.Containers {
+				if containerRegex.MatchString(c.Name) {
+					streams = append(streams, logStream{pod: pod.Name, container: c.Name})
+				}
+			}
+		case logsAllContainers || len(pod.Spec.Containers) == 1:
+			for _, c := range pod.Spec.Containers {
+				streams = append(streams, logStream{pod: pod.Name, container: c.Name})
+			}
+		default:
+			var names []string
+			for _, c := range pod.Spec.Containers {
+				names = append(names, c.Name)
+			}
+			return nil, fmt.Errorf("a container name must be specified for pod %s, choose one of %v or use --container-regex or --all-containers", pod.Name, names)
+		}
+	}
+	return streams, nil
+}
+
+// printLogs prints the logs of streams, prefixing each line with its source when there is
+// more than one. Logs are printed one after another, unless following, when all streams
+// are printed concurrently.
+func printLogs(streams []logStream, opts *corev1.PodLogOptions) error {
+	out := &lineWriter{w: os.Stdout}
+	format := textLine(len(streams) > 1)
+	if logsOutput == "json" {
+		format = jsonLine(opts.Timestamps)
+	}
+
+	errs := make([]error, len(streams))
+	if opts.Follow {
+		var wg sync.WaitGroup
+		for i, s := range streams {
+			wg.Add(1)
+			go func(i int, s logStream) {
+				defer wg.Done()
+				errs[i] = printLog(s, *opts, format, out)
+			}(i, s)
+		}
+		wg.Wait()
+	} else {
+		for i, s := range streams {
+			errs[i] = printLog(s, *opts, format, out)
+		}
+	}
+
+	var failed int
+	for i, err := range errs {
+		if err != nil {
+			failed++
+			logRawError(err)
+			logger.Error("failed to get logs", append(errorFields(err),
+				zap.String("pod", streams[i].pod),
+				zap.String("container", streams[i].container),
+			)...)
+		}
+	}
+	if failed == 1 && len(streams) == 1 {
+		return errs[0]
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to get logs of %d of %d containers", failed, len(streams))
+	}
+	return nil
+}
+
+func printLog(s logStream, opts corev1.PodLogOptions, format lineFormat, out *lineWriter) error {
+	opts.Container = s.container
+	rc, err := kubeClient.CoreV1().Pods(namespace).GetLogs(s.pod, &opts).Stream()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	r := bufio.NewReader(rc)
+	for {
+		line, err := r.ReadString('\n')
+		if len(line) > 0 {
+			formatted, ferr := format(s, strings.TrimSuffix(line, "\n"))
+			if ferr != nil {
+				return ferr
+			}
+			if werr := out.writeLine(formatted + "\n"); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// lineFormat formats a line of the log of s, without its trailing newline, for printing.
+type lineFormat func(s logStream, line string) (string, error)
+
+// textLine prints lines as they are, prefixed with their source if prefix is set.
+func textLine(prefix bool) lineFormat {
+	return func(s logStream, line string) (string, error) {
+		if !prefix {
+			return line, nil
+		}
+		return fmt.Sprintf("[pod/%s/%s] %s", s.pod, s.container, line), nil
+	}
+}
+
+// jsonLogLine is a log line printed with --output json.
+type jsonLogLine struct {
+	Pod       string     `json:"pod"`
+	Container string     `json:"container"`
+	Time      *time.Time `json:"time,omitempty"`
+	Message   string     `json:"message"`
+}
+
+// jsonLine prints each line as a JSON object. If timestamps is set, lines start with the
+// RFC3339 timestamp added by the kubelet, which is parsed into the time field.
+func jsonLine(timestamps bool) lineFormat {
+	return func(s logStream, line string) (string, error) {
+		l := jsonLogLine{Pod: s.pod, Container: s.container, Message: line}
+		if timestamps {
+			if i := strings.IndexByte(line, ' '); i > 0 {
+				if t, err := time.Parse(time.RFC3339Nano, line[:i]); err == nil {
+					l.Time, l.Message = &t, line[i+1:]
+				}
+			}
+		}
+		data, err := json.Marshal(l)
+		return string(data), err
+	}
+}
+
+// lineWriter writes whole lines, so that lines from concurrent streams don't interleave.
+type lineWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *lineWriter) writeLine(line string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := io.WriteString(w.w, line)
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+
+	logsCmd.Flags().StringVarP(&logsSelector, "selector", "l", "", "label selector of the pods to print the logs of, supports '=', '==', and '!=' (e.g. -l key1=value1,key2=value2)")
+	logsCmd.Flags().StringVarP(&logsContainer, "container", "c", "", "the container to print the logs of")
+	logsCmd.Flags().StringVar(&logsContainerRegex, "container-regex", "", "print the logs of the containers whose names match a regular expression")
+	logsCmd.Flags().BoolVar(&logsAllContainers, "all-containers", false, "print the logs of all containers in the pods")
+	logsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "stream the logs as they are written")
+	logsCmd.Flags().BoolVar(&logsTimestamps, "timestamps", false, "include the timestamp of each line")
+	logsCmd.Flags().DurationVar(&logsSince, "since", 0, "only print logs newer than a relative duration, e.g. 5s, 2m or 3h")
+	logsCmd.Flags().StringVar(&logsSinceTime, "since-time", "", "only print logs after an RFC3339 timestamp, e.g. 2018-03-01T10:00:00Z")
+	logsCmd.Flags().StringVarP(&logsOutput, "output", "o", "", "output format, json prints each line as a JSON object")
+}
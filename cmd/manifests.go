@@ -0,0 +1,132 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// manifestExtensions are the file extensions read when a directory is passed as a filename.
+var manifestExtensions = []string{".json", ".yaml", ".yml"}
+
+// manifestObject is an object read from a manifest, along with where it was read from.
+type manifestObject struct {
+	*unstructured.Unstructured
+	source string
+}
+
+// readManifests reads all objects from the given files, directories or "-" for stdin.
+// Files may contain multiple YAML documents, and List objects are expanded to their items.
+func readManifests(filenames []string) ([]manifestObject, error) {
+	var objs []manifestObject
+	for _, filename := range filenames {
+		paths, err := expandManifestPath(filename)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			pathObjs, err := readManifest(path)
+			if err != nil {
+				return nil, err
+			}
+			objs = append(objs, pathObjs...)
+		}
+	}
+	return objs, nil
+}
+
+func expandManifestPath(filename string) ([]string, error) {
+	if filename == "-" {
+		return []string{filename}, nil
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{filename}, nil
+	}
+
+	files, err := ioutil.ReadDir(filename)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		for _, ext := range manifestExtensions {
+			if strings.HasSuffix(f.Name(), ext) {
+				paths = append(paths, filepath.Join(filename, f.Name()))
+				break
+			}
+		}
+	}
+	return paths, nil
+}
+
+func readManifest(path string) ([]manifestObject, error) {
+	var r io.Reader = os.Stdin
+	source := "stdin"
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r, source = f, path
+	}
+
+	var objs []manifestObject
+	decoder := yaml.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if err == io.EOF {
+				return objs, nil
+			}
+			return nil, fmt.Errorf("error parsing %s: %v", source, err)
+		}
+		// Empty documents, e.g. a trailing "---", decode to nothing.
+		if len(obj.Object) == 0 {
+			continue
+		}
+		if obj.GetKind() == "" {
+			return nil, fmt.Errorf("error parsing %s: object has no kind", source)
+		}
+
+		if !obj.IsList() {
+			objs = append(objs, manifestObject{Unstructured: obj, source: source})
+			continue
+		}
+		err := obj.EachListItem(func(item runtime.Object) error {
+			objs = append(objs, manifestObject{Unstructured: item.(*unstructured.Unstructured), source: source})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error parsing list in %s: %v", source, err)
+		}
+	}
+}
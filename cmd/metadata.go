@@ -0,0 +1,370 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jimmidyson/kube-client-template/pkg/output"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+var (
+	metadataSelector  string
+	metadataOverwrite bool
+	metadataDryRun    bool
+)
+
+// labelCmd represents the label command
+var labelCmd = &cobra.Command{
+	Use:   "label TYPE[/NAME] [NAME...] KEY=VALUE... KEY-...",
+	Short: "Set or remove the labels of objects",
+	Long: `Set or remove the labels of objects, by name or label selector.
+
+KEY=VALUE sets a label and KEY- removes it. Existing labels are only changed
+with --overwrite. With --dry-run, the labels of each object are printed
+before and after the change, without changing anything.
+
+Labeling objects selected with -l asks for confirmation. Pass --yes to skip
+it, which is required when stdin is not a terminal. For example:
+
+  kube-client-template label pod/nginx tier=frontend
+  kube-client-template label deployments -l app=shop team=payments release- --dry-run`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runMetadataChange(args, metadataLabels)
+	},
+}
+
+// annotateCmd represents the annotate command
+var annotateCmd = &cobra.Command{
+	Use:   "annotate TYPE[/NAME] [NAME...] KEY=VALUE... KEY-...",
+	Short: "Set or remove the annotations of objects",
+	Long: `Set or remove the annotations of objects, by name or label selector.
+
+KEY=VALUE sets an annotation and KEY- removes it. Existing annotations are
+only changed with --overwrite. With --dry-run, the annotations of each object
+are printed before and after the change, without changing anything.
+
+Annotating objects selected with -l asks for confirmation. Pass --yes to skip
+it, which is required when stdin is not a terminal. For example:
+
+  kube-client-template annotate service/web owner=team-a
+  kube-client-template annotate pods -l app=shop example.com/audit=2018-q2 --overwrite --yes`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runMetadataChange(args, metadataAnnotations)
+	},
+}
+
+// metadataField is the metadata field that label or annotate changes.
+type metadataField struct {
+	// name is the field under metadata, e.g. "labels".
+	name string
+	// verb and action describe the change, e.g. "label" and "labeled".
+	verb, action string
+	get          func(obj *unstructured.Unstructured) map[string]string
+	// validateValue returns why value isn't valid for the field, if it isn't.
+	validateValue func(value string) []string
+}
+
+var (
+	metadataLabels = metadataField{
+		name:          "labels",
+		verb:          "label",
+		action:        "labeled",
+		get:           (*unstructured.Unstructured).GetLabels,
+		validateValue: validation.IsValidLabelValue,
+	}
+	metadataAnnotations = metadataField{
+		name:          "annotations",
+		verb:          "annotate",
+		action:        "annotated",
+		get:           (*unstructured.Unstructured).GetAnnotations,
+		validateValue: func(string) []string { return nil },
+	}
+)
+
+// metadataChanges are the keys to set and remove.
+type metadataChanges struct {
+	set    map[string]string
+	remove []string
+}
+
+// runMetadataChange applies the KEY=VALUE and KEY- changes at the end of args to the
+// field of the objects they name, or that match --selector.
+func runMetadataChange(args []string, field metadataField) error {
+	var changeArgs []string
+	for len(args) > 0 && isMetadataChange(args[len(args)-1]) {
+		changeArgs = append([]string{args[len(args)-1]}, changeArgs...)
+		args = args[:len(args)-1]
+	}
+	if len(changeArgs) == 0 {
+		return fmt.Errorf("must specify at least one %s to set with KEY=VALUE or remove with KEY-", strings.TrimSuffix(field.name, "s"))
+	}
+	if len(args) == 0 {
+		return errors.New("must specify the type of the objects")
+	}
+	changes, err := parseMetadataChanges(changeArgs, field)
+	if err != nil {
+		return err
+	}
+
+	resourceArg, names, err := splitResourceArgs(args)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 && metadataSelector == "" {
+		return fmt.Errorf("must specify the names of the objects to %s, or a selector with -l", field.verb)
+	}
+	if len(names) > 0 && metadataSelector != "" {
+		return errors.New("names and a selector cannot both be specified")
+	}
+
+	mapping, err := resourceMapping(resourceArg)
+	if err != nil {
+		return err
+	}
+	objs, err := selectObjects(mapping, namespace, names, metadataSelector)
+	if err != nil {
+		return err
+	}
+	if len(objs) == 0 {
+		fmt.Fprintln(os.Stderr, "No resources found.")
+		return nil
+	}
+	if metadataDryRun {
+		return previewMetadataChange(mapping, objs, field, changes)
+	}
+	if metadataSelector != "" {
+		if err := confirm(field.verb + " " + objectsSummary(mapping.Resource, objs)); err != nil {
+			return err
+		}
+	}
+	return changeMetadataAll(mapping, objs, field, changes)
+}
+
+// isMetadataChange returns whether arg is a KEY=VALUE or KEY- argument rather than a
+// resource type or name, which can contain neither.
+func isMetadataChange(arg string) bool {
+	return strings.Contains(arg, "=") || strings.HasSuffix(arg, "-")
+}
+
+func parseMetadataChanges(args []string, field metadataField) (metadataChanges, error) {
+	changes := metadataChanges{set: map[string]string{}}
+	for _, arg := range args {
+		key, value := arg, ""
+		remove := !strings.Contains(arg, "=")
+		if remove {
+			key = strings.TrimSuffix(arg, "-")
+		} else {
+			i := strings.Index(arg, "=")
+			key, value = arg[:i], arg[i+1:]
+		}
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return changes, fmt.Errorf("invalid %s key %q: %s", strings.TrimSuffix(field.name, "s"), key, strings.Join(errs, "; "))
+		}
+		if errs := field.validateValue(value); len(errs) > 0 {
+			return changes, fmt.Errorf("invalid value %q of %s: %s", value, key, strings.Join(errs, "; "))
+		}
+		if _, found := changes.set[key]; found {
+			return changes, fmt.Errorf("%s is specified more than once", key)
+		}
+		for _, removed := range changes.remove {
+			if removed == key {
+				return changes, fmt.Errorf("%s is specified more than once", key)
+			}
+		}
+		if remove {
+			changes.remove = append(changes.remove, key)
+		} else {
+			changes.set[key] = value
+		}
+	}
+	return changes, nil
+}
+
+// apply returns the result of the changes to current, which isn't modified. Without
+// --overwrite, changing the value of an existing key is an error.
+func (c metadataChanges) apply(current map[string]string) (map[string]string, error) {
+	updated := map[string]string{}
+	for k, v := range current {
+		updated[k] = v
+	}
+	for k, v := range c.set {
+		if old, found := current[k]; found && old != v && !metadataOverwrite {
+			return nil, fmt.Errorf("%s is already set to %q, pass --overwrite to change it", k, old)
+		}
+		updated[k] = v
+	}
+	for _, k := range c.remove {
+		delete(updated, k)
+	}
+	return updated, nil
+}
+
+// fetchObject returns obj as stored by the API server, as named objects are selected
+// without being fetched.
+func fetchObject(mapping *meta.RESTMapping, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	if obj.GetResourceVersion() != "" {
+		return obj, nil
+	}
+	client, err := resourceClient(mapping, obj.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+	return client.Get(obj.GetName(), metav1.GetOptions{})
+}
+
+// previewMetadataChange prints the field of each of objs before and after the changes,
+// one key per line, with keys that are added, removed or changed marked by +, - and ~.
+func previewMetadataChange(mapping *meta.RESTMapping, objs []*unstructured.Unstructured, field metadataField, changes metadataChanges) error {
+	var failed int
+	for _, obj := range objs {
+		name, err := output.QualifiedName(obj)
+		if err != nil {
+			return err
+		}
+		current, err := fetchObject(mapping, obj)
+		if err == nil {
+			var updated map[string]string
+			if updated, err = changes.apply(field.get(current)); err == nil {
+				fmt.Fprintf(os.Stdout, "%s (dry run)\n", name)
+				printMetadataDiff(field.get(current), updated)
+				continue
+			}
+		}
+		failed++
+		logRawError(err)
+		logger.Error("failed to "+field.verb+" object", append(errorFields(err), zap.String("name", name))...)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to %s %d of %d objects", field.verb, failed, len(objs))
+	}
+	return nil
+}
+
+func printMetadataDiff(before, after map[string]string) {
+	keys := map[string]bool{}
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+	var sorted []string
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	if len(sorted) == 0 {
+		fmt.Fprintln(os.Stdout, "    <none>")
+	}
+	for _, k := range sorted {
+		old, hadKey := before[k]
+		updated, hasKey := after[k]
+		switch {
+		case !hadKey:
+			fmt.Fprintf(os.Stdout, "  + %s=%s\n", k, updated)
+		case !hasKey:
+			fmt.Fprintf(os.Stdout, "  - %s=%s\n", k, old)
+		case old != updated:
+			fmt.Fprintf(os.Stdout, "  ~ %s=%s -> %s\n", k, old, updated)
+		default:
+			fmt.Fprintf(os.Stdout, "    %s=%s\n", k, old)
+		}
+	}
+}
+
+// changeMetadataAll applies the changes to each of objs, then reports the result of each.
+func changeMetadataAll(mapping *meta.RESTMapping, objs []*unstructured.Unstructured, field metadataField, changes metadataChanges) error {
+	var failed int
+	for _, obj := range objs {
+		name, err := output.QualifiedName(obj)
+		if err != nil {
+			return err
+		}
+		if err := changeMetadata(mapping, obj, field, changes); err != nil {
+			failed++
+			logRawError(err)
+			logger.Error("failed to "+field.verb+" object", append(errorFields(err), zap.String("name", name))...)
+			continue
+		}
+		fmt.Fprintf(os.Stdout, "%s %s\n", name, field.action)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to %s %d of %d objects", field.verb, failed, len(objs))
+	}
+	return nil
+}
+
+// changeMetadata patches the field of obj. The patch carries the resource version the
+// changes were checked against, so a concurrent change fails rather than being
+// overwritten without --overwrite.
+func changeMetadata(mapping *meta.RESTMapping, obj *unstructured.Unstructured, field metadataField, changes metadataChanges) error {
+	current, err := fetchObject(mapping, obj)
+	if err != nil {
+		return err
+	}
+	if _, err := changes.apply(field.get(current)); err != nil {
+		return err
+	}
+	values := map[string]interface{}{}
+	for k, v := range changes.set {
+		values[k] = v
+	}
+	for _, k := range changes.remove {
+		values[k] = nil
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			field.name:        values,
+			"resourceVersion": current.GetResourceVersion(),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	client, err := resourceClient(mapping, obj.GetNamespace())
+	if err != nil {
+		return err
+	}
+	_, err = client.Patch(obj.GetName(), types.MergePatchType, patch)
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(labelCmd)
+	rootCmd.AddCommand(annotateCmd)
+
+	for _, cmd := range []*cobra.Command{labelCmd, annotateCmd} {
+		cmd.Flags().StringVarP(&metadataSelector, "selector", "l", "", "label selector of the objects to change, supports '=', '==', and '!=' (e.g. -l key1=value1,key2=value2)")
+		cmd.Flags().BoolVar(&metadataOverwrite, "overwrite", false, "allow existing keys to be changed")
+		cmd.Flags().BoolVar(&metadataDryRun, "dry-run", false, "only print each object's keys before and after the change, without changing them")
+		addConfirmFlag(cmd)
+	}
+}
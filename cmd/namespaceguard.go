@@ -0,0 +1,48 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// namespaceFlag is the flag setting the namespace commands run in.
+const namespaceFlag = "kubernetes-namespace"
+
+// requireNamespace stops mutating commands from running in the default namespace unless
+// it is passed explicitly.
+var requireNamespace bool
+
+// mutatingCommands are the commands that change objects in a namespace, which
+// --require-namespace applies to.
+var mutatingCommands = map[*cobra.Command]bool{}
+
+// checkNamespaceRequired returns an error if cmd changes objects and would run in the
+// default namespace only because no namespace was passed, while --require-namespace is set.
+func checkNamespaceRequired(cmd *cobra.Command) error {
+	if !requireNamespace || !mutatingCommands[cmd] || namespace != metav1.NamespaceDefault || cmd.Flags().Changed(namespaceFlag) {
+		return nil
+	}
+	return fmt.Errorf("refusing to run %s in the default namespace as --require-namespace is set, pass --%s=%s to run it there", cmd.CommandPath(), namespaceFlag, metav1.NamespaceDefault)
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{applyCmd, createCmd, deleteCmd, scaleCmd, rolloutRestartCmd, labelCmd, annotateCmd} {
+		mutatingCommands[cmd] = true
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// listInNamespaces lists the objects matching opts in each of namespaces with the worker
+// pool, returning one list per namespace in the same order.
+func listInNamespaces(mapping *meta.RESTMapping, namespaces []string, opts metav1.ListOptions) ([]*unstructured.UnstructuredList, error) {
+	lists := make([]*unstructured.UnstructuredList, len(namespaces))
+	errs := workerPool.Run(len(namespaces), func(i int) error {
+		client, err := resourceClient(mapping, namespaces[i])
+		if err != nil {
+			return err
+		}
+		lists[i], err = listUnstructured(client, opts)
+		return err
+	})
+
+	var failed int
+	for i, err := range errs {
+		if err != nil {
+			failed++
+			logRawError(err)
+			logger.Error("failed to list resources", append(errorFields(err), zap.String("namespace", namespaces[i]))...)
+		}
+	}
+	if failed > 0 {
+		return nil, fmt.Errorf("failed to list %s in %d of %d namespaces", mapping.Resource, failed, len(namespaces))
+	}
+	return lists, nil
+}
+
+// watchInNamespaces watches the objects matching opts in each of namespaces, starting
+// each watch from the resource version of the corresponding list.
+func watchInNamespaces(mapping *meta.RESTMapping, namespaces []string, opts metav1.ListOptions, lists []*unstructured.UnstructuredList) (watch.Interface, error) {
+	var watches []watch.Interface
+	for i, ns := range namespaces {
+		client, err := resourceClient(mapping, ns)
+		if err == nil {
+			nsOpts := opts
+			nsOpts.ResourceVersion = lists[i].GetResourceVersion()
+			var w watch.Interface
+			if w, err = client.Watch(nsOpts); err == nil {
+				watches = append(watches, w)
+				continue
+			}
+		}
+		for _, w := range watches {
+			w.Stop()
+		}
+		return nil, err
+	}
+	return newMergedWatch(watches), nil
+}
+
+// mergedWatch merges the events of several watches into one. Its result channel is
+// closed once all of the merged watches have closed.
+type mergedWatch struct {
+	watches  []watch.Interface
+	result   chan watch.Event
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func newMergedWatch(watches []watch.Interface) *mergedWatch {
+	m := &mergedWatch{
+		watches: watches,
+		result:  make(chan watch.Event),
+		done:    make(chan struct{}),
+	}
+	var wg sync.WaitGroup
+	for _, w := range watches {
+		wg.Add(1)
+		go func(w watch.Interface) {
+			defer wg.Done()
+			for event := range w.ResultChan() {
+				select {
+				case m.result <- event:
+				case <-m.done:
+					return
+				}
+			}
+		}(w)
+	}
+	go func() {
+		wg.Wait()
+		close(m.result)
+	}()
+	return m
+}
+
+func (m *mergedWatch) ResultChan() <-chan watch.Event {
+	return m.result
+}
+
+func (m *mergedWatch) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.done)
+		for _, w := range m.watches {
+			w.Stop()
+		}
+	})
+}
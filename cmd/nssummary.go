@@ -0,0 +1,384 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jimmidyson/kube-client-template/pkg/output"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+var nsSummaryAllNamespaces bool
+
+// nsSummaryCmd represents the ns-summary command
+var nsSummaryCmd = &cobra.Command{
+	Use:   "ns-summary [NAMESPACE]",
+	Short: "Summarize the quotas, limits and objects of namespaces",
+	Long: `Summarize the resource quotas, limit ranges and object counts of a namespace,
+defaulting to the current namespace.
+
+With --all-namespaces, a row is printed for each namespace instead, with its
+object counts and the quota that is closest to being used up.
+
+Objects are counted a page at a time. Resources that you aren't allowed to
+list are shown as "-" rather than counted. For example:
+
+  kube-client-template ns-summary shop
+  kube-client-template ns-summary --all-namespaces`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if nsSummaryAllNamespaces {
+			if len(args) > 0 {
+				return fmt.Errorf("a namespace and --all-namespaces cannot both be specified")
+			}
+			return printNamespacesSummary()
+		}
+		ns := namespace
+		if len(args) > 0 {
+			ns = args[0]
+		}
+		return printNamespaceSummary(ns)
+	},
+}
+
+// nsSummaryPageSize is how many objects are listed per request when counting objects, so
+// that large namespaces aren't read in a single response.
+const nsSummaryPageSize = 500
+
+// countedResources are the resources counted in each namespace, with a function listing
+// them.
+var countedResources = []struct {
+	name string
+	list func(ns string, opts metav1.ListOptions) (runtime.Object, error)
+}{
+	{"pods", func(ns string, opts metav1.ListOptions) (runtime.Object, error) {
+		return kubeClient.CoreV1().Pods(ns).List(opts)
+	}},
+	{"deployments", func(ns string, opts metav1.ListOptions) (runtime.Object, error) {
+		return kubeClient.AppsV1().Deployments(ns).List(opts)
+	}},
+	{"services", func(ns string, opts metav1.ListOptions) (runtime.Object, error) {
+		return kubeClient.CoreV1().Services(ns).List(opts)
+	}},
+	{"configmaps", func(ns string, opts metav1.ListOptions) (runtime.Object, error) {
+		return kubeClient.CoreV1().ConfigMaps(ns).List(opts)
+	}},
+	{"secrets", func(ns string, opts metav1.ListOptions) (runtime.Object, error) {
+		return kubeClient.CoreV1().Secrets(ns).List(opts)
+	}},
+	{"persistentvolumeclaims", func(ns string, opts metav1.ListOptions) (runtime.Object, error) {
+		return kubeClient.CoreV1().PersistentVolumeClaims(ns).List(opts)
+	}},
+}
+
+// itemNamespaces returns the namespace of each item of list.
+func itemNamespaces(list runtime.Object) ([]string, error) {
+	var namespaces []string
+	err := meta.EachListItem(list, func(obj runtime.Object) error {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return err
+		}
+		namespaces = append(namespaces, accessor.GetNamespace())
+		return nil
+	})
+	return namespaces, err
+}
+
+// listNamespaces lists objects in ns with list a page at a time, returning the namespace
+// of each object.
+func listNamespaces(list func(ns string, opts metav1.ListOptions) (runtime.Object, error), ns string) ([]string, error) {
+	var namespaces []string
+	opts := metav1.ListOptions{Limit: nsSummaryPageSize}
+	for {
+		page, err := list(ns, opts)
+		if err != nil {
+			return nil, err
+		}
+		pageNamespaces, err := itemNamespaces(page)
+		if err != nil {
+			return nil, err
+		}
+		namespaces = append(namespaces, pageNamespaces...)
+		listMeta, err := meta.ListAccessor(page)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Continue = listMeta.GetContinue(); opts.Continue == "" {
+			return namespaces, nil
+		}
+	}
+}
+
+// countObjects counts the objects of countedResources in ns, by namespace, in the order of
+// countedResources. Each resource is listed once, so counting across all namespaces
+// takes as many requests as counting in one. Resources that the user isn't allowed to
+// list, or that the server doesn't serve, aren't counted and are false in listed.
+func countObjects(ns string) (counts map[string][]int, listed []bool, err error) {
+	counts = map[string][]int{}
+	listed = make([]bool, len(countedResources))
+	for i, r := range countedResources {
+		namespaces, err := listNamespaces(r.list, ns)
+		if apierrors.IsForbidden(err) || apierrors.IsNotFound(err) {
+			logger.Debug("not counting resource that can't be listed", zap.String("resource", r.name), zap.Error(err))
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to count %s: %v", r.name, err)
+		}
+		listed[i] = true
+		for _, itemNS := range namespaces {
+			if counts[itemNS] == nil {
+				counts[itemNS] = make([]int, len(countedResources))
+			}
+			counts[itemNS][i]++
+		}
+	}
+	return counts, listed, nil
+}
+
+// objectCount returns the count of the resource at index i of countedResources in ns, or
+// "-" if it wasn't listed.
+func objectCount(counts map[string][]int, listed []bool, ns string, i int) string {
+	if !listed[i] {
+		return "-"
+	}
+	var count int
+	if nsCounts := counts[ns]; nsCounts != nil {
+		count = nsCounts[i]
+	}
+	return fmt.Sprint(count)
+}
+
+// quotaUsage is how much of a quota's hard limit on a resource is used.
+type quotaUsage struct {
+	quota      string
+	resource   corev1.ResourceName
+	used, hard resource.Quantity
+}
+
+// fraction returns used as a fraction of hard. A zero hard limit that is used counts as
+// exhausted.
+func (u quotaUsage) fraction() float64 {
+	hard := u.hard.MilliValue()
+	if hard == 0 {
+		if u.used.IsZero() {
+			return 0
+		}
+		return 1
+	}
+	return float64(u.used.MilliValue()) / float64(hard)
+}
+
+// quotaUsages returns the usage of every resource limited by quotas, sorted by quota and
+// resource.
+func quotaUsages(quotas []corev1.ResourceQuota) []quotaUsage {
+	var usages []quotaUsage
+	for _, q := range quotas {
+		for name, hard := range q.Status.Hard {
+			usages = append(usages, quotaUsage{quota: q.Name, resource: name, used: q.Status.Used[name], hard: hard})
+		}
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].quota != usages[j].quota {
+			return usages[i].quota < usages[j].quota
+		}
+		return usages[i].resource < usages[j].resource
+	})
+	return usages
+}
+
+func printNamespaceSummary(ns string) error {
+	nsObj, err := kubeClient.CoreV1().Namespaces().Get(ns, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	quotas, err := kubeClient.CoreV1().ResourceQuotas(ns).List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	limitRanges, err := kubeClient.CoreV1().LimitRanges(ns).List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	counts, listed, err := countObjects(ns)
+	if err != nil {
+		return err
+	}
+
+	defer pageOutput(true)()
+	out := os.Stdout
+	tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Name:\t%s\n", nsObj.Name)
+	fmt.Fprintf(tw, "Status:\t%s\n", nsObj.Status.Phase)
+	printMap(tw, "Labels", nsObj.Labels)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, "Resource Quotas:")
+	if err := printQuotaUsages(out, quotaUsages(quotas.Items)); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "Limit Ranges:")
+	if err := printLimitRanges(out, limitRanges.Items); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, "Objects:")
+	tw = tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	for i, r := range countedResources {
+		fmt.Fprintf(tw, "  %s:\t%s\n", r.name, objectCount(counts, listed, ns, i))
+	}
+	return tw.Flush()
+}
+
+func printQuotaUsages(out io.Writer, usages []quotaUsage) error {
+	if len(usages) == 0 {
+		fmt.Fprintln(out, "  <none>")
+		return nil
+	}
+	tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "  QUOTA\tRESOURCE\tUSED\tHARD\tUSAGE")
+	for _, u := range usages {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%.0f%%\n", u.quota, u.resource, u.used.String(), u.hard.String(), 100*u.fraction())
+	}
+	return tw.Flush()
+}
+
+func printLimitRanges(out io.Writer, limitRanges []corev1.LimitRange) error {
+	if len(limitRanges) == 0 {
+		fmt.Fprintln(out, "  <none>")
+		return nil
+	}
+	quantity := func(list corev1.ResourceList, name corev1.ResourceName) string {
+		if q, found := list[name]; found {
+			return q.String()
+		}
+		return "-"
+	}
+	tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "  LIMIT RANGE\tTYPE\tRESOURCE\tMIN\tMAX\tDEFAULT REQUEST\tDEFAULT LIMIT")
+	for _, lr := range limitRanges {
+		for _, item := range lr.Spec.Limits {
+			names := map[corev1.ResourceName]bool{}
+			for _, list := range []corev1.ResourceList{item.Min, item.Max, item.DefaultRequest, item.Default} {
+				for name := range list {
+					names[name] = true
+				}
+			}
+			var sorted []string
+			for name := range names {
+				sorted = append(sorted, string(name))
+			}
+			sort.Strings(sorted)
+			for _, name := range sorted {
+				n := corev1.ResourceName(name)
+				fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\t%s\t%s\n", lr.Name, item.Type, name,
+					quantity(item.Min, n), quantity(item.Max, n), quantity(item.DefaultRequest, n), quantity(item.Default, n))
+			}
+		}
+	}
+	return tw.Flush()
+}
+
+// printNamespacesSummary prints a row for each namespace with its object counts and its
+// most used quota.
+func printNamespacesSummary() error {
+	namespaces, err := kubeClient.CoreV1().Namespaces().List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	if len(namespaces.Items) == 0 {
+		fmt.Fprintln(os.Stderr, "No resources found.")
+		return nil
+	}
+	quotas, err := kubeClient.CoreV1().ResourceQuotas(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	quotasByNamespace := map[string][]corev1.ResourceQuota{}
+	for _, q := range quotas.Items {
+		quotasByNamespace[q.Namespace] = append(quotasByNamespace[q.Namespace], q)
+	}
+	counts, listed, err := countObjects(metav1.NamespaceAll)
+	if err != nil {
+		return err
+	}
+
+	table := &output.Table{Columns: []string{"NAMESPACE", "STATUS"}}
+	for _, r := range countedResources {
+		table.Columns = append(table.Columns, columnHeader(r.name))
+	}
+	table.Columns = append(table.Columns, "QUOTA USAGE")
+	for _, ns := range namespaces.Items {
+		row := []string{ns.Name, string(ns.Status.Phase)}
+		for i := range countedResources {
+			row = append(row, objectCount(counts, listed, ns.Name, i))
+		}
+		row = append(row, mostUsedQuota(quotaUsages(quotasByNamespace[ns.Name])))
+		table.Rows = append(table.Rows, row)
+	}
+
+	defer pageOutput(true)()
+	printer, err := output.PrinterFor(withTableStyle(output.Flags{}))
+	if err != nil {
+		return err
+	}
+	return printer.PrintObj(table, os.Stdout)
+}
+
+// columnHeader returns the table column header of a resource, e.g. "PVCS" for
+// persistentvolumeclaims.
+func columnHeader(resource string) string {
+	if resource == "persistentvolumeclaims" {
+		return "PVCS"
+	}
+	return strings.ToUpper(resource)
+}
+
+// mostUsedQuota describes the quota resource closest to its hard limit, e.g.
+// "85% (compute/requests.cpu)".
+func mostUsedQuota(usages []quotaUsage) string {
+	if len(usages) == 0 {
+		return "<none>"
+	}
+	most := usages[0]
+	for _, u := range usages[1:] {
+		if u.fraction() > most.fraction() {
+			most = u
+		}
+	}
+	return fmt.Sprintf("%.0f%% (%s/%s)", 100*most.fraction(), most.quota, most.resource)
+}
+
+func init() {
+	rootCmd.AddCommand(nsSummaryCmd)
+
+	nsSummaryCmd.Flags().BoolVar(&nsSummaryAllNamespaces, "all-namespaces", false, "print a row for each namespace, rather than summarizing one")
+}
@@ -0,0 +1,97 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// pagedPods returns a list function serving pods in the given namespaces in pages of
+// pageSize, recording the options of each request.
+func pagedPods(namespaces []string, pageSize int, requests *[]metav1.ListOptions) func(string, metav1.ListOptions) (runtime.Object, error) {
+	return func(ns string, opts metav1.ListOptions) (runtime.Object, error) {
+		*requests = append(*requests, opts)
+		start := 0
+		if opts.Continue != "" {
+			start, _ = strconv.Atoi(opts.Continue)
+		}
+		end := start + pageSize
+		list := &corev1.PodList{}
+		if end < len(namespaces) {
+			list.Continue = strconv.Itoa(end)
+		} else {
+			end = len(namespaces)
+		}
+		for i, podNS := range namespaces[start:end] {
+			list.Items = append(list.Items, corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprint("pod-", start+i), Namespace: podNS}})
+		}
+		return list, nil
+	}
+}
+
+// TestCountObjects checks that objects are counted across all pages, and that resources
+// that can't be listed are shown as "-" rather than failing the summary.
+func TestCountObjects(t *testing.T) {
+	original := countedResources
+	defer func() { countedResources = original }()
+
+	var requests []metav1.ListOptions
+	forbidden := func(string, metav1.ListOptions) (runtime.Object, error) {
+		return nil, apierrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "", errors.New("not allowed"))
+	}
+	// Pods and secrets, replacing the functions listing them.
+	countedResources = append(original[:0:0], original[0], original[4])
+	countedResources[0].list = pagedPods([]string{"shop", "shop", "web", "shop", "web"}, 2, &requests)
+	countedResources[1].list = forbidden
+
+	counts, listed, err := countObjects(metav1.NamespaceAll)
+	if err != nil {
+		t.Fatalf("countObjects() failed: %v", err)
+	}
+	if len(requests) != 3 {
+		t.Errorf("listed pods in %d pages, want 3", len(requests))
+	}
+	for _, opts := range requests {
+		if opts.Limit != nsSummaryPageSize {
+			t.Errorf("listed pods with limit %d, want %d", opts.Limit, nsSummaryPageSize)
+		}
+	}
+	var got []string
+	for _, ns := range []string{"shop", "web", "empty"} {
+		for i := range countedResources {
+			got = append(got, objectCount(counts, listed, ns, i))
+		}
+	}
+	if want := []string{"3", "-", "2", "-", "0", "-"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("counts are %v, want %v", got, want)
+	}
+
+	countedResources[1].list = func(string, metav1.ListOptions) (runtime.Object, error) {
+		return nil, apierrors.NewInternalError(errors.New("etcd is down"))
+	}
+	if _, _, err := countObjects(metav1.NamespaceAll); err == nil {
+		t.Error("countObjects() succeeded with a server error, want it to fail")
+	}
+}
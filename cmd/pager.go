@@ -0,0 +1,70 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"os/exec"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// defaultPager is used when neither KUBE_CLIENT_TEMPLATE_PAGER nor PAGER is set. It exits
+// straight away when the output fits on one screen and keeps colors.
+const defaultPager = "less -FRX"
+
+var noPager bool
+
+// pageOutput sends everything written to os.Stdout through a pager, until the returned
+// function is called. Output is only paged when it is meant for humans, e.g. tables
+// rather than JSON, and stdout is a terminal.
+func pageOutput(humanOutput bool) func() {
+	if noPager || !humanOutput || !terminal.IsTerminal(int(os.Stdout.Fd())) {
+		return func() {}
+	}
+	pager := os.Getenv("KUBE_CLIENT_TEMPLATE_PAGER")
+	if pager == "" {
+		pager = os.Getenv("PAGER")
+	}
+	if pager == "" {
+		pager = defaultPager
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		logger.Debug("failed to create pager pipe", zap.Error(err))
+		return func() {}
+	}
+	cmd := exec.Command("sh", "-c", pager)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = r, os.Stdout, os.Stderr
+	if err := cmd.Start(); err != nil {
+		logger.Debug("failed to start pager, writing output directly", zap.String("pager", pager), zap.Error(err))
+		r.Close()
+		w.Close()
+		return func() {}
+	}
+	r.Close()
+
+	stdout := os.Stdout
+	os.Stdout = w
+	return func() {
+		os.Stdout = stdout
+		w.Close()
+		if err := cmd.Wait(); err != nil {
+			logger.Debug("pager failed", zap.String("pager", pager), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/jimmidyson/kube-client-template/pkg/kube"
+	"go.uber.org/zap"
+)
+
+// defaultParallelism is the default of --parallelism.
+const defaultParallelism = 5
+
+// parallelism is the maximum number of requests that commands fanning out over many
+// objects or namespaces make at once.
+var parallelism int
+
+// workerPool is the pool that commands fan out with. It is shared by the whole command,
+// so that fan-outs running at the same time, or within each other, share its slots
+// rather than each running --parallelism tasks at once. Tasks of the pool fanning out
+// again must use RunNested rather than Run.
+var workerPool *kube.Pool
+
+// newWorkerPool returns a pool running at most --parallelism tasks at once. With
+// client-side throttling, no more than --kube-burst run at once either, as the rest would
+// only wait for the rate limiter.
+func newWorkerPool() *kube.Pool {
+	size := parallelism
+	if !disableClientThrottling && kubeBurst > 0 && size > kubeBurst {
+		logger.Debug("limiting parallelism to the client burst", zap.Int("parallelism", size), zap.Int("burst", kubeBurst))
+		size = kubeBurst
+	}
+	return kube.NewPool(size)
+}
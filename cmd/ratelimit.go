@@ -0,0 +1,180 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+const (
+	// rateLimitWindow is the window over which the request rate is measured.
+	rateLimitWindow = 10 * time.Second
+	// rateLimitWarnRatio is the fraction of the configured QPS above which a sustained
+	// request rate is warned about.
+	rateLimitWarnRatio = 0.8
+	// throttledWait is how long a request must wait for the rate limiter to count as
+	// throttled.
+	throttledWait = 10 * time.Millisecond
+	// minThrottledRequests is how many requests must be throttled for new limits to be
+	// suggested at exit.
+	minThrottledRequests = 10
+)
+
+// clientRateLimiter is the rate limiter of the clients, if client-side throttling is
+// enabled, so that new limits can be suggested when the command exits.
+var clientRateLimiter *monitoredRateLimiter
+
+// monitoredRateLimiter wraps a rate limiter, counting accepted requests and warning when
+// the request rate over a window approaches the configured QPS. Counting is a single
+// locked increment per request, the rate is only evaluated when a window ends. Requests
+// that wait for the limiter are also counted, to suggest new limits at exit.
+type monitoredRateLimiter struct {
+	flowcontrol.RateLimiter
+
+	burst int
+	log   *zap.Logger
+
+	mu          sync.Mutex
+	windowStart time.Time
+	accepted    int
+
+	// The totals over the whole run. throttledTime is the wall time during which at
+	// least one request was waiting for the limiter.
+	start          time.Time
+	total          int
+	throttled      int
+	waiting        int
+	throttledSince time.Time
+	throttledTime  time.Duration
+}
+
+func newMonitoredRateLimiter(qps float32, burst int, log *zap.Logger) *monitoredRateLimiter {
+	now := time.Now()
+	return &monitoredRateLimiter{
+		RateLimiter: flowcontrol.NewTokenBucketRateLimiter(qps, burst),
+		burst:       burst,
+		log:         log,
+		windowStart: now,
+		start:       now,
+	}
+}
+
+func (l *monitoredRateLimiter) TryAccept() bool {
+	if !l.RateLimiter.TryAccept() {
+		return false
+	}
+	l.record()
+	return true
+}
+
+func (l *monitoredRateLimiter) Accept() {
+	if l.RateLimiter.TryAccept() {
+		l.record()
+		return
+	}
+	l.startWaiting()
+	start := time.Now()
+	l.RateLimiter.Accept()
+	l.stopWaiting(time.Since(start))
+	l.record()
+}
+
+func (l *monitoredRateLimiter) startWaiting() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.waiting == 0 {
+		l.throttledSince = time.Now()
+	}
+	l.waiting++
+}
+
+func (l *monitoredRateLimiter) stopWaiting(waited time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.waiting--
+	if l.waiting == 0 {
+		l.throttledTime += time.Since(l.throttledSince)
+	}
+	if waited >= throttledWait {
+		l.throttled++
+	}
+}
+
+// suggestLimits logs a one line suggestion of the QPS and burst that would have served
+// the requests of the run without throttling, if many of them were throttled. Demand is
+// estimated as the rate the requests would have been sent at had they only been sent
+// while nothing was waiting for the limiter.
+func (l *monitoredRateLimiter) suggestLimits() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.throttled < minThrottledRequests {
+		return
+	}
+	unthrottled := time.Since(l.start) - l.throttledTime
+	if unthrottled < time.Second {
+		unthrottled = time.Second
+	}
+	qps := float64(l.QPS())
+	demand := float64(l.total) / unthrottled.Seconds()
+	// Suggest at least half as much again as the current limit, rounded up to a multiple
+	// of 5, with the same ratio of burst to QPS as the client-go defaults.
+	suggestedQPS := 5 * math.Ceil(math.Max(demand, 1.5*qps)/5)
+	suggestedBurst := int(2 * suggestedQPS)
+	if suggestedBurst < l.burst {
+		suggestedBurst = l.burst
+	}
+	// The worker pool is limited to the burst, so a smaller burst would still hold back
+	// fan-outs below --parallelism.
+	if suggestedBurst < parallelism {
+		suggestedBurst = parallelism
+	}
+	l.log.Info("requests were throttled by the client rate limit, consider raising it",
+		zap.Int("throttledRequests", l.throttled),
+		zap.Int("requests", l.total),
+		zap.Duration("throttledFor", l.throttledTime),
+		zap.String("suggestion", "--kube-qps="+formatFloat(suggestedQPS)+" --kube-burst="+formatFloat(float64(suggestedBurst))),
+	)
+}
+
+func (l *monitoredRateLimiter) record() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if elapsed := now.Sub(l.windowStart); elapsed >= rateLimitWindow {
+		rate := float64(l.accepted) / elapsed.Seconds()
+		if qps := float64(l.QPS()); rate >= rateLimitWarnRatio*qps {
+			l.log.Warn("sustained API request rate is approaching the client rate limit, consider raising --kube-qps and --kube-burst",
+				zap.Float64("requestsPerSecond", rate),
+				zap.Float64("qps", qps),
+				zap.Int("burst", l.burst),
+				zap.Duration("window", elapsed),
+			)
+		}
+		l.windowStart, l.accepted = now, 0
+	}
+	l.accepted++
+	l.total++
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
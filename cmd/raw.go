@@ -0,0 +1,108 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// defaultProxyPath is the path proxied to a pod when --pod is used without a path.
+const defaultProxyPath = "metrics"
+
+var rawPod string
+
+// rawCmd represents the raw command
+var rawCmd = &cobra.Command{
+	Use:   "raw [PATH]",
+	Short: "Stream the response of a GET request to an API server path",
+	Long: `Stream the response of a GET request to an API server path to stdout.
+
+With --pod, the path is proxied through the API server to a port of the pod,
+which is handy for scraping metrics. The path defaults to /metrics, and the
+port can be a number or the name of a container port. The request is bounded
+by --kubernetes-request-timeout. For example:
+
+  kube-client-template raw /apis/metrics.k8s.io/v1beta1/nodes
+  kube-client-template raw --pod nginx-7c87f569d-5k2xq:9113
+  kube-client-template raw --pod nginx-7c87f569d-5k2xq:9113 /stub_status`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var rawPath string
+		if len(args) == 1 {
+			rawPath = args[0]
+		}
+		if rawPod == "" {
+			if rawPath == "" {
+				return errors.New("must specify a path, or a pod with --pod")
+			}
+			if !strings.HasPrefix(rawPath, "/") {
+				return fmt.Errorf("invalid path %q: must be absolute", rawPath)
+			}
+			return streamRaw(rawPath, os.Stdout)
+		}
+
+		pod, port := rawPod, ""
+		if i := strings.LastIndex(pod, ":"); i >= 0 {
+			pod, port = pod[:i], pod[i+1:]
+		}
+		if pod == "" {
+			return fmt.Errorf("invalid pod %q: must be in NAME[:PORT] form", rawPod)
+		}
+		if rawPath == "" {
+			rawPath = defaultProxyPath
+		}
+		proxyPath := podProxyPath(namespace, pod, port, rawPath)
+		if err := streamRaw(proxyPath, os.Stdout); err != nil {
+			return fmt.Errorf("failed to proxy to pod %s/%s: %v", namespace, rawPod, err)
+		}
+		return nil
+	},
+}
+
+// podProxyPath returns the API server path proxying path to port of the named pod. An
+// empty port proxies to the pod's default port.
+func podProxyPath(namespace, pod, port, proxiedPath string) string {
+	target := pod
+	if port != "" {
+		target += ":" + port
+	}
+	return path.Join("/api/v1/namespaces", namespace, "pods", target, "proxy", proxiedPath)
+}
+
+// streamRaw copies the response body of a GET request to absPath to w.
+func streamRaw(absPath string, w io.Writer) error {
+	logger.Debug("requesting raw path", zap.String("path", absPath))
+	rc, err := kubeClient.CoreV1().RESTClient().Get().AbsPath(absPath).Stream()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	_, err = io.Copy(w, rc)
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(rawCmd)
+
+	rawCmd.Flags().StringVar(&rawPod, "pod", "", "proxy the request to a pod in NAME[:PORT] form, in the current namespace")
+}
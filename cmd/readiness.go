@@ -0,0 +1,89 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// readinessTarget returns a target that is done once the named object is ready: rolled
+// out for workloads, complete for jobs and ready for pods. It returns nil for kinds
+// without a notion of readiness.
+func readinessTarget(mapping *meta.RESTMapping, namespace, name, ref string) *rolloutTarget {
+	if target := workloadTarget(mapping, namespace, name, ref); target != nil {
+		return target
+	}
+
+	target := &rolloutTarget{ref: ref}
+	switch gvk := mapping.GroupVersionKind; {
+	case gvk.Group == "batch" && gvk.Kind == "Job":
+		client := kubeClient.BatchV1().Jobs(namespace)
+		target.get = func() (runtime.Object, error) { return client.Get(name, metav1.GetOptions{}) }
+		target.watch = client.Watch
+		target.status = jobReadyStatus
+	case gvk.Group == "" && gvk.Kind == "Pod":
+		client := kubeClient.CoreV1().Pods(namespace)
+		target.get = func() (runtime.Object, error) { return client.Get(name, metav1.GetOptions{}) }
+		target.watch = client.Watch
+		target.status = podReadyStatus
+	default:
+		return nil
+	}
+	return target
+}
+
+func jobReadyStatus(obj runtime.Object) (string, bool, error) {
+	job, ok := obj.(*batchv1.Job)
+	if !ok {
+		return "", false, fmt.Errorf("unexpected object type %T", obj)
+	}
+	for _, c := range job.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batchv1.JobComplete:
+			return fmt.Sprintf("job %q completed", job.Name), true, nil
+		case batchv1.JobFailed:
+			return "", false, fmt.Errorf("job %q failed: %s", job.Name, c.Message)
+		}
+	}
+	return fmt.Sprintf("Waiting for job %q to complete: %d active, %d succeeded...", job.Name, job.Status.Active, job.Status.Succeeded), false, nil
+}
+
+func podReadyStatus(obj runtime.Object) (string, bool, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return "", false, fmt.Errorf("unexpected object type %T", obj)
+	}
+	switch pod.Status.Phase {
+	case corev1.PodSucceeded:
+		return fmt.Sprintf("pod %q completed", pod.Name), true, nil
+	case corev1.PodFailed:
+		return "", false, fmt.Errorf("pod %q failed: %s", pod.Name, pod.Status.Message)
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady && c.Status == corev1.ConditionTrue {
+			return fmt.Sprintf("pod %q is ready", pod.Name), true, nil
+		}
+	}
+	return fmt.Sprintf("Waiting for pod %q to be ready: phase %s...", pod.Name, pod.Status.Phase), false, nil
+}
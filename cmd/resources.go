@@ -0,0 +1,266 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jimmidyson/kube-client-template/pkg/output"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// splitResourceArgs splits the TYPE[/NAME] [NAME...] arguments of a command into the
+// resource type and the object names.
+func splitResourceArgs(args []string) (string, []string, error) {
+	resourceArg, names := args[0], args[1:]
+	if i := strings.Index(resourceArg, "/"); i >= 0 {
+		if len(names) > 0 {
+			return "", nil, errors.New("there is no need to specify a resource type as a separate argument when passing arguments in resource/name form")
+		}
+		resourceArg, names = resourceArg[:i], []string{resourceArg[i+1:]}
+	}
+	return resourceArg, names, nil
+}
+
+// resourceMapping resolves a resource argument as typed by a user, e.g. "pods", "po",
+// "deployments.apps" or "deployments.v1.apps", to its REST mapping.
+func resourceMapping(arg string) (*meta.RESTMapping, error) {
+	fullySpecified, gr := schema.ParseResourceArg(strings.ToLower(arg))
+	gvr := gr.WithVersion("")
+	if fullySpecified != nil {
+		if _, err := restMapper.KindFor(*fullySpecified); err == nil {
+			gvr = *fullySpecified
+		}
+	}
+
+	gvk, err := restMapper.KindFor(expandShortName(gvr))
+	if err != nil {
+		if unavailable := unavailableGroupError(gvr.Group); unavailable != nil {
+			return nil, unavailable
+		}
+		return nil, fmt.Errorf("unknown resource type %q: %v", arg, err)
+	}
+	return restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+}
+
+// expandShortName replaces a short name such as "po" or "deploy" with the full
+// resource name advertised by discovery. Unknown names are returned unchanged.
+func expandShortName(gvr schema.GroupVersionResource) schema.GroupVersionResource {
+	lists, err := discoveryClient.ServerPreferredResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		namedLogger("discovery").Debug("failed to discover short names", zap.Error(err))
+		return gvr
+	}
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil || (gvr.Group != "" && gvr.Group != gv.Group) {
+			continue
+		}
+		for _, r := range list.APIResources {
+			for _, shortName := range r.ShortNames {
+				if shortName == gvr.Resource {
+					gvr.Resource = r.Name
+					return gvr
+				}
+			}
+		}
+	}
+	return gvr
+}
+
+// unavailableGroupError returns an error explaining that group could not be discovered
+// because its aggregated API is unavailable, or nil if discovery of group succeeded.
+func unavailableGroupError(group string) error {
+	if group == "" {
+		return nil
+	}
+	_, err := discoveryClient.ServerPreferredResources()
+	failed, ok := err.(*discovery.ErrGroupDiscoveryFailed)
+	if !ok {
+		return nil
+	}
+	for gv, gvErr := range failed.Groups {
+		if gv.Group == group {
+			return discoveryFailedError(gv, gvErr)
+		}
+	}
+	return nil
+}
+
+// isNamespaced returns whether the resource described by mapping is namespace scoped.
+func isNamespaced(mapping *meta.RESTMapping) bool {
+	return mapping.Scope.Name() == meta.RESTScopeNameNamespace
+}
+
+// resourceClient returns a dynamic client for the resource described by mapping. The
+// namespace is ignored for cluster scoped resources. Reads through the client are cached
+// for --cache-ttl.
+func resourceClient(mapping *meta.RESTMapping, namespace string) (dynamic.ResourceInterface, error) {
+	resource, err := kubeFactory.ResourceClient(mapping, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if !isNamespaced(mapping) {
+		namespace = ""
+	}
+	gvr := mapping.GroupVersionKind.GroupVersion().WithResource(mapping.Resource)
+	return withCache(resource, gvr.String(), namespace), nil
+}
+
+// objectClient returns the REST mapping of obj and a dynamic client for it. Namespaced
+// objects that don't specify a namespace are defaulted to the current namespace.
+func objectClient(obj *unstructured.Unstructured) (*meta.RESTMapping, dynamic.ResourceInterface, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, nil, err
+	}
+	if isNamespaced(mapping) && obj.GetNamespace() == "" {
+		obj.SetNamespace(namespace)
+	}
+	client, err := resourceClient(mapping, obj.GetNamespace())
+	if err != nil {
+		return nil, nil, err
+	}
+	return mapping, client, nil
+}
+
+// hasSubresource returns whether discovery advertises subresource for the resource
+// described by mapping, e.g. "status" for deployments.
+func hasSubresource(mapping *meta.RESTMapping, subresource string) (bool, error) {
+	list, err := discoveryClient.ServerResourcesForGroupVersion(mapping.GroupVersionKind.GroupVersion().String())
+	if err != nil {
+		return false, err
+	}
+	for _, r := range list.APIResources {
+		if r.Name == mapping.Resource+"/"+subresource {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// readSubresource reads subresource of the named object directly from its endpoint. The
+// dynamic client can only address top level resources, so the path is built here.
+func readSubresource(mapping *meta.RESTMapping, namespace, name, subresource string) (*unstructured.Unstructured, error) {
+	raw, err := kubeClient.CoreV1().RESTClient().Get().AbsPath(subresourcePath(mapping, namespace, name, subresource)...).DoRaw()
+	if err != nil {
+		return nil, err
+	}
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(raw); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// subresourcePath returns the path segments of subresource of the named object.
+func subresourcePath(mapping *meta.RESTMapping, namespace, name, subresource string) []string {
+	return append(resourcePath(mapping, namespace, name), subresource)
+}
+
+// resourcePath returns the path segments of the named object, or of the collection if
+// name is empty. An empty namespace addresses the collection across all namespaces.
+func resourcePath(mapping *meta.RESTMapping, namespace, name string) []string {
+	gv := mapping.GroupVersionKind.GroupVersion()
+	segments := []string{"/apis", gv.Group, gv.Version}
+	if gv.Group == "" {
+		segments = []string{"/api", gv.Version}
+	}
+	if isNamespaced(mapping) && namespace != "" {
+		segments = append(segments, "namespaces", namespace)
+	}
+	segments = append(segments, mapping.Resource)
+	if name != "" {
+		segments = append(segments, name)
+	}
+	return segments
+}
+
+// writeSubresource replaces subresource of the named object with obj, returning the result.
+func writeSubresource(mapping *meta.RESTMapping, namespace, name, subresource string, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	body, err := obj.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := kubeClient.CoreV1().RESTClient().Put().AbsPath(subresourcePath(mapping, namespace, name, subresource)...).Body(body).DoRaw()
+	if err != nil {
+		return nil, err
+	}
+	updated := &unstructured.Unstructured{}
+	if err := updated.UnmarshalJSON(raw); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// getServerTable gets the named object, or lists the objects matching opts if name is
+// empty, asking the server to render them as a Table. Servers that can't render tables
+// return the objects themselves.
+func getServerTable(mapping *meta.RESTMapping, namespace, name string, opts metav1.ListOptions) (runtime.Object, error) {
+	req := kubeClient.CoreV1().RESTClient().Get().
+		AbsPath(resourcePath(mapping, namespace, name)...).
+		SetHeader("Accept", output.ServerTableAccept).
+		Param("includeObject", "Metadata")
+	if name == "" {
+		req = req.VersionedParams(&opts, scheme.ParameterCodec)
+	}
+	raw, err := req.DoRaw()
+	if err != nil {
+		return nil, err
+	}
+	return runtime.Decode(unstructured.UnstructuredJSONScheme, raw)
+}
+
+// selectObjects returns the objects of mapping in namespace that are either named, or
+// match selector. Named objects are not fetched, only their type and name are set.
+func selectObjects(mapping *meta.RESTMapping, namespace string, names []string, selector string) ([]*unstructured.Unstructured, error) {
+	var objs []*unstructured.Unstructured
+	if len(names) > 0 {
+		for _, name := range names {
+			obj := &unstructured.Unstructured{}
+			obj.SetGroupVersionKind(mapping.GroupVersionKind)
+			if isNamespaced(mapping) {
+				obj.SetNamespace(namespace)
+			}
+			obj.SetName(name)
+			objs = append(objs, obj)
+		}
+		return objs, nil
+	}
+
+	client, err := resourceClient(mapping, namespace)
+	if err != nil {
+		return nil, err
+	}
+	list, err := listUnstructured(client, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	for i := range list.Items {
+		objs = append(objs, &list.Items[i])
+	}
+	return objs, nil
+}
@@ -0,0 +1,106 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestResultSemantics checks that get, describe, delete and wait agree on what empty
+// results, objects that don't exist and partial failures print and exit with.
+func TestResultSemantics(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		// wantCode is the exit code, 0 for success.
+		wantCode int
+		// wantErr is part of the error the command fails with.
+		wantErr string
+		// wantStdout and wantStderr are parts of what the command prints.
+		wantStdout []string
+		wantStderr string
+		// notStdout are parts of stdout that must not be printed.
+		notStdout []string
+	}{
+		// Empty results succeed, except for wait whose condition can never be met.
+		{name: "get empty", args: []string{"get", "pods", "-l", "app=none", "-o", "name"}, wantStderr: "No resources found."},
+		{name: "describe empty", args: []string{"describe", "pods", "-l", "app=none"}, wantStderr: "No resources found."},
+		{name: "delete empty", args: []string{"delete", "pods", "-l", "app=none", "--yes"}, wantStderr: "No resources found."},
+		{name: "wait empty", args: []string{"wait", "pods", "-l", "app=none", "--for", "jsonpath={.metadata.name}"}, wantCode: exitFailure, wantErr: "no matching resources found"},
+		{name: "wait empty ignored", args: []string{"wait", "pods", "-l", "app=none", "--for", "jsonpath={.metadata.name}", "--ignore-not-found"}, wantStderr: "No resources found."},
+
+		// A single object that doesn't exist fails with NotFound, unless ignored.
+		{name: "get not found", args: []string{"get", "pods", "missing", "-o", "name"}, wantCode: exitFailure, wantErr: `pods "missing" not found`},
+		{name: "get not found ignored", args: []string{"get", "pods", "missing", "-o", "name", "--ignore-not-found"}, wantStderr: "No resources found."},
+		{name: "describe not found", args: []string{"describe", "pods", "missing"}, wantCode: exitFailure, wantErr: `pods "missing" not found`},
+		{name: "describe not found ignored", args: []string{"describe", "pods", "missing", "--ignore-not-found"}, wantStderr: "No resources found."},
+		{name: "delete not found", args: []string{"delete", "pods", "missing", "--yes"}, wantCode: exitFailure, wantErr: "failed to delete 1 of 1 objects"},
+		{name: "delete not found ignored", args: []string{"delete", "pods", "missing", "--yes", "--ignore-not-found"}},
+		{name: "wait not found", args: []string{"wait", "pods", "missing", "--for", "jsonpath={.metadata.name}"}, wantCode: exitFailure, wantErr: `pods "missing" not found`},
+		{name: "wait not found ignored", args: []string{"wait", "pods", "missing", "--for", "jsonpath={.metadata.name}", "--ignore-not-found"}, wantStderr: "No resources found."},
+
+		// The objects that exist are still acted on when others don't.
+		{name: "get partly not found", args: []string{"get", "pods", "web-1", "missing", "-o", "name"}, wantCode: exitFailure, wantErr: `pods "missing" not found`, wantStdout: []string{"pod/web-1"}},
+		{name: "get partly not found ignored", args: []string{"get", "pods", "web-1", "missing", "-o", "name", "--ignore-not-found"}, wantStdout: []string{"pod/web-1"}},
+		{name: "describe partly not found", args: []string{"describe", "pods", "web-1", "missing", "web-2"}, wantCode: exitFailure, wantErr: `pods "missing" not found`, wantStdout: []string{"web-1", "web-2"}},
+		{name: "delete partly not found", args: []string{"delete", "pods", "web-1", "missing", "--yes"}, wantCode: exitFailure, wantErr: "failed to delete 1 of 2 objects", wantStdout: []string{"pod/web-1 deleted"}},
+		{name: "delete partly not found ignored", args: []string{"delete", "pods", "web-1", "missing", "--yes", "--ignore-not-found"}, wantStdout: []string{"pod/web-1 deleted"}},
+		{name: "wait partly not found ignored", args: []string{"wait", "pods", "web-1", "missing", "--for", "jsonpath={.metadata.name}", "--ignore-not-found"}, wantStdout: []string{"pod/web-1 condition met"}},
+
+		// Other failures are counted once the rest have been acted on, and aren't ignored.
+		{name: "get partial failure", args: []string{"get", "pods", "web-1", "locked", "missing", "-o", "name"}, wantCode: exitFailure, wantErr: "failed to get 2 of 3 resources", wantStdout: []string{"pod/web-1"}},
+		{name: "get partial failure ignored", args: []string{"get", "pods", "web-1", "locked", "missing", "-o", "name", "--ignore-not-found"}, wantCode: exitFailure, wantErr: "forbidden", wantStdout: []string{"pod/web-1"}},
+		{name: "describe partial failure", args: []string{"describe", "pods", "web-1", "locked", "missing"}, wantCode: exitFailure, wantErr: "failed to get 2 of 3 resources", wantStdout: []string{"web-1"}},
+		{name: "delete partial failure", args: []string{"delete", "pods", "web-1", "locked", "--yes", "--ignore-not-found"}, wantCode: exitFailure, wantErr: "failed to delete 1 of 2 objects", wantStdout: []string{"pod/web-1 deleted"}, notStdout: []string{"pod/locked"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+			server.addPod("web-1", map[string]string{"app": "web"})
+			server.addPod("web-2", map[string]string{"app": "web"})
+			server.forbidden["locked"] = true
+
+			result := runCommand(t, server, tt.args...)
+			if result.code != tt.wantCode {
+				t.Errorf("exit code = %d, want %d (error: %v, stderr: %s)", result.code, tt.wantCode, result.err, result.stderr)
+			}
+			switch {
+			case tt.wantErr == "" && result.err != nil:
+				t.Errorf("unexpected error: %v", result.err)
+			case tt.wantErr != "" && (result.err == nil || !strings.Contains(result.err.Error(), tt.wantErr)):
+				t.Errorf("error = %v, want it to contain %q", result.err, tt.wantErr)
+			}
+			for _, want := range tt.wantStdout {
+				if !strings.Contains(result.stdout, want) {
+					t.Errorf("stdout = %q, want it to contain %q", result.stdout, want)
+				}
+			}
+			for _, unwanted := range tt.notStdout {
+				if strings.Contains(result.stdout, unwanted) {
+					t.Errorf("stdout = %q, want it not to contain %q", result.stdout, unwanted)
+				}
+			}
+			if !strings.Contains(result.stderr, tt.wantStderr) {
+				t.Errorf("stderr = %q, want it to contain %q", result.stderr, tt.wantStderr)
+			}
+			if tt.wantStderr == "" && strings.Contains(result.stderr, "No resources found.") {
+				t.Errorf("stderr = %q, want no empty result message", result.stderr)
+			}
+		})
+	}
+}
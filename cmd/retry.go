@@ -0,0 +1,190 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// retryAttempts is how many times a request failing with a retryable error is retried.
+	retryAttempts = 3
+	// retryBaseDelay is the delay before the first retry, doubling with each retry.
+	retryBaseDelay = 500 * time.Millisecond
+)
+
+// defaultRetryOn are the errors that are usually transient: throttling, the API server
+// or a proxy in front of it being briefly unavailable, and dropped connections.
+var defaultRetryOn = []string{"429", "502", "503", "504", "connection-reset", "timeout"}
+
+var (
+	retryOn         []string
+	retryClassifier *errorClassifier
+)
+
+// errorClassifier decides which failed requests are retried.
+type errorClassifier struct {
+	codes             map[int]bool
+	codeClasses       map[int]bool
+	connectionReset   bool
+	connectionRefused bool
+	timeout           bool
+}
+
+// parseRetryOn parses the tokens of --retry-on: HTTP status codes such as 429, classes of
+// status codes such as 5xx, and connection-reset, connection-refused and timeout.
+func parseRetryOn(tokens []string) (*errorClassifier, error) {
+	c := &errorClassifier{codes: map[int]bool{}, codeClasses: map[int]bool{}}
+	for _, token := range tokens {
+		switch token = strings.TrimSpace(strings.ToLower(token)); {
+		case token == "":
+			// --retry-on= disables retries.
+		case token == "connection-reset":
+			c.connectionReset = true
+		case token == "connection-refused":
+			c.connectionRefused = true
+		case token == "timeout":
+			c.timeout = true
+		case len(token) == 3 && strings.HasSuffix(token, "xx") && (token[0] == '4' || token[0] == '5'):
+			c.codeClasses[int(token[0]-'0')] = true
+		default:
+			code, err := strconv.Atoi(token)
+			if err != nil || code < 400 || code > 599 {
+				return nil, fmt.Errorf("invalid --retry-on value %q: must be a 4xx or 5xx status code or class (e.g. 429 or 5xx), connection-reset, connection-refused or timeout", token)
+			}
+			c.codes[code] = true
+		}
+	}
+	return c, nil
+}
+
+// retryable returns whether a request that failed with resp or err should be retried,
+// and why.
+func (c *errorClassifier) retryable(resp *http.Response, err error) (bool, string) {
+	if err != nil {
+		switch {
+		case c.connectionReset && isErrno(err, syscall.ECONNRESET):
+			return true, "connection-reset"
+		case c.connectionRefused && isErrno(err, syscall.ECONNREFUSED):
+			return true, "connection-refused"
+		case c.timeout && isTimeout(err):
+			return true, "timeout"
+		}
+		return false, ""
+	}
+	if c.codes[resp.StatusCode] || c.codeClasses[resp.StatusCode/100] {
+		return true, strconv.Itoa(resp.StatusCode)
+	}
+	return false, ""
+}
+
+// isErrno returns whether err was caused by the system call error errno.
+func isErrno(err error, errno syscall.Errno) bool {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	if opErr, ok := err.(*net.OpError); ok {
+		err = opErr.Err
+	}
+	if osErr, ok := err.(*os.SyscallError); ok {
+		err = osErr.Err
+	}
+	e, ok := err.(syscall.Errno)
+	return ok && e == errno
+}
+
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
+
+// retryingRoundTripper retries requests failing with errors its classifier considers
+// transient, with exponential backoff. Requests that change state are only retried when
+// they were throttled before being processed, or couldn't be sent.
+type retryingRoundTripper struct {
+	delegate http.RoundTripper
+	classify *errorClassifier
+	log      *zap.Logger
+}
+
+func (rt *retryingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	delay := retryBaseDelay
+	for attempt := 0; ; attempt++ {
+		resp, err := rt.delegate.RoundTrip(req)
+		if attempt == retryAttempts || !rt.canRetry(req, resp, err) {
+			return resp, err
+		}
+		retry, reason := rt.classify.retryable(resp, err)
+		if !retry {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+		rt.log.Debug("retrying request", zap.String("method", req.Method), zap.String("url", req.URL.String()), zap.String("reason", reason), zap.Int("attempt", attempt+1), zap.Duration("delay", delay))
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// canRetry returns whether req can safely be sent again after failing with resp or err.
+func (rt *retryingRoundTripper) canRetry(req *http.Request, resp *http.Response, err error) bool {
+	if req.Body != nil && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	// Other requests may have been applied if they reached the server, unless it
+	// throttled them or the connection was never made. Throttled requests carry a
+	// Retry-After header, unlike e.g. evictions blocked by a disruption budget.
+	throttled := resp != nil && resp.StatusCode == http.StatusTooManyRequests && resp.Header.Get("Retry-After") != ""
+	return throttled || (err != nil && isErrno(err, syscall.ECONNREFUSED))
+}
+
+// configureRetries retries the requests made with config that fail with the errors
+// selected by --retry-on.
+func configureRetries(config *rest.Config) {
+	if retryClassifier == nil {
+		return
+	}
+	log := namedLogger("client")
+	wrap := config.WrapTransport
+	config.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		if wrap != nil {
+			rt = wrap(rt)
+		}
+		return &retryingRoundTripper{delegate: rt, classify: retryClassifier, log: log}
+	}
+}
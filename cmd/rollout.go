@@ -0,0 +1,276 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// deploymentProgressDeadlineExceeded is the reason of the Progressing condition of a
+// deployment that has failed to make progress within its progress deadline.
+const deploymentProgressDeadlineExceeded = "ProgressDeadlineExceeded"
+
+var rolloutStatusTimeout time.Duration
+
+// rolloutCmd represents the rollout command
+var rolloutCmd = &cobra.Command{
+	Use:   "rollout",
+	Short: "Manage the rollout of deployments, statefulsets and daemonsets",
+	Long:  `Manage the rollout of deployments, statefulsets and daemonsets.`,
+}
+
+// rolloutStatusCmd represents the rollout status command
+var rolloutStatusCmd = &cobra.Command{
+	Use:   "status TYPE/NAME",
+	Short: "Wait for a rollout to finish",
+	Long: `Wait for a rollout to finish, printing its progress.
+
+A deployment whose Progressing condition reports that its progress deadline
+was exceeded fails immediately, rather than waiting for the timeout. For
+example:
+
+  kube-client-template rollout status deployment/nginx --timeout=5m`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target, err := rolloutTargetFor(args[0], namespace)
+		if err != nil {
+			return err
+		}
+		return waitForRollout(target, rolloutStatusTimeout)
+	},
+}
+
+// rolloutTarget is a workload whose rollout can be followed.
+type rolloutTarget struct {
+	ref    string
+	get    func() (runtime.Object, error)
+	watch  func(opts metav1.ListOptions) (watch.Interface, error)
+	status func(obj runtime.Object) (message string, done bool, err error)
+}
+
+// rolloutTargetFor resolves a TYPE/NAME reference to a deployment, statefulset or daemonset.
+func rolloutTargetFor(ref, namespace string) (*rolloutTarget, error) {
+	i := strings.Index(ref, "/")
+	if i <= 0 || i == len(ref)-1 {
+		return nil, fmt.Errorf("invalid resource %q: must be in TYPE/NAME form", ref)
+	}
+	mapping, err := resourceMapping(ref[:i])
+	if err != nil {
+		return nil, err
+	}
+	target := workloadTarget(mapping, namespace, ref[i+1:], ref)
+	if target == nil {
+		return nil, fmt.Errorf("rollout status is not supported for %s", mapping.GroupVersionKind.Kind)
+	}
+	return target, nil
+}
+
+// workloadTarget returns the rollout target for the named deployment, statefulset or
+// daemonset, or nil if mapping is not one of those kinds.
+func workloadTarget(mapping *meta.RESTMapping, namespace, name, ref string) *rolloutTarget {
+	target := &rolloutTarget{ref: ref}
+	switch mapping.GroupVersionKind.Kind {
+	case "Deployment":
+		client := kubeClient.AppsV1().Deployments(namespace)
+		target.get = func() (runtime.Object, error) { return client.Get(name, metav1.GetOptions{}) }
+		target.watch = client.Watch
+		target.status = deploymentRolloutStatus
+	case "StatefulSet":
+		client := kubeClient.AppsV1().StatefulSets(namespace)
+		target.get = func() (runtime.Object, error) { return client.Get(name, metav1.GetOptions{}) }
+		target.watch = client.Watch
+		target.status = statefulSetRolloutStatus
+	case "DaemonSet":
+		client := kubeClient.AppsV1().DaemonSets(namespace)
+		target.get = func() (runtime.Object, error) { return client.Get(name, metav1.GetOptions{}) }
+		target.watch = client.Watch
+		target.status = daemonSetRolloutStatus
+	default:
+		return nil
+	}
+	return target
+}
+
+// waitForRollout waits for the rollout of target to finish, printing progress as it
+// changes. A timeout of zero waits forever.
+func waitForRollout(target *rolloutTarget, timeout time.Duration) error {
+	var timeoutCh <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+
+	var lastMessage string
+	report := func(obj runtime.Object) (bool, error) {
+		message, done, err := target.status(obj)
+		if err != nil {
+			return false, err
+		}
+		if message != lastMessage {
+			fmt.Fprintln(os.Stdout, message)
+			lastMessage = message
+		}
+		return done, nil
+	}
+
+	for {
+		obj, err := target.get()
+		if err != nil {
+			return err
+		}
+		if done, err := report(obj); done || err != nil {
+			return err
+		}
+
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return err
+		}
+		w, err := target.watch(metav1.ListOptions{
+			FieldSelector:   fields.OneTermEqualSelector("metadata.name", accessor.GetName()).String(),
+			ResourceVersion: accessor.GetResourceVersion(),
+		})
+		if err != nil {
+			return err
+		}
+
+		done, err := func() (bool, error) {
+			defer w.Stop()
+			for {
+				select {
+				case event, ok := <-w.ResultChan():
+					if !ok {
+						return false, nil
+					}
+					switch event.Type {
+					case watch.Error:
+						return false, apierrors.FromObject(event.Object)
+					case watch.Deleted:
+						return false, fmt.Errorf("%s was deleted while waiting for its rollout to finish", target.ref)
+					}
+					if done, err := report(event.Object); done || err != nil {
+						return done, err
+					}
+				case <-timeoutCh:
+					return false, fmt.Errorf("timed out waiting for the rollout of %s to finish", target.ref)
+				}
+			}
+		}()
+		if done || err != nil {
+			return err
+		}
+		logger.Debug("watch closed, restarting", zap.String("resource", target.ref))
+	}
+}
+
+func deploymentRolloutStatus(obj runtime.Object) (string, bool, error) {
+	deployment, ok := obj.(*appsv1.Deployment)
+	if !ok {
+		return "", false, fmt.Errorf("unexpected object type %T", obj)
+	}
+	if deployment.Generation > deployment.Status.ObservedGeneration {
+		return "Waiting for deployment spec update to be observed...", false, nil
+	}
+
+	for _, c := range deployment.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Reason == deploymentProgressDeadlineExceeded {
+			return "", false, fmt.Errorf("deployment %q exceeded its progress deadline: %s", deployment.Name, c.Message)
+		}
+	}
+
+	status := deployment.Status
+	if deployment.Spec.Replicas != nil && status.UpdatedReplicas < *deployment.Spec.Replicas {
+		return fmt.Sprintf("Waiting for deployment %q rollout to finish: %d out of %d new replicas have been updated...", deployment.Name, status.UpdatedReplicas, *deployment.Spec.Replicas), false, nil
+	}
+	if status.Replicas > status.UpdatedReplicas {
+		return fmt.Sprintf("Waiting for deployment %q rollout to finish: %d old replicas are pending termination...", deployment.Name, status.Replicas-status.UpdatedReplicas), false, nil
+	}
+	if status.AvailableReplicas < status.UpdatedReplicas {
+		return fmt.Sprintf("Waiting for deployment %q rollout to finish: %d of %d updated replicas are available...", deployment.Name, status.AvailableReplicas, status.UpdatedReplicas), false, nil
+	}
+	return fmt.Sprintf("deployment %q successfully rolled out", deployment.Name), true, nil
+}
+
+func statefulSetRolloutStatus(obj runtime.Object) (string, bool, error) {
+	sts, ok := obj.(*appsv1.StatefulSet)
+	if !ok {
+		return "", false, fmt.Errorf("unexpected object type %T", obj)
+	}
+	if sts.Spec.UpdateStrategy.Type != appsv1.RollingUpdateStatefulSetStrategyType {
+		return "", false, errors.New("rollout status is only available for the RollingUpdate strategy type")
+	}
+	if sts.Status.ObservedGeneration == 0 || sts.Generation > sts.Status.ObservedGeneration {
+		return "Waiting for statefulset spec update to be observed...", false, nil
+	}
+
+	status := sts.Status
+	if sts.Spec.Replicas != nil && status.ReadyReplicas < *sts.Spec.Replicas {
+		return fmt.Sprintf("Waiting for %d pods to be ready...", *sts.Spec.Replicas-status.ReadyReplicas), false, nil
+	}
+	if rollingUpdate := sts.Spec.UpdateStrategy.RollingUpdate; rollingUpdate != nil && rollingUpdate.Partition != nil && *rollingUpdate.Partition > 0 && sts.Spec.Replicas != nil {
+		if status.UpdatedReplicas < *sts.Spec.Replicas-*rollingUpdate.Partition {
+			return fmt.Sprintf("Waiting for partitioned roll out to finish: %d out of %d new pods have been updated...", status.UpdatedReplicas, *sts.Spec.Replicas-*rollingUpdate.Partition), false, nil
+		}
+		return fmt.Sprintf("partitioned roll out complete: %d new pods have been updated...", status.UpdatedReplicas), true, nil
+	}
+	if status.UpdateRevision != status.CurrentRevision {
+		return fmt.Sprintf("Waiting for statefulset rolling update to complete %d pods at revision %s...", status.UpdatedReplicas, status.UpdateRevision), false, nil
+	}
+	return fmt.Sprintf("statefulset rolling update complete %d pods at revision %s...", status.CurrentReplicas, status.CurrentRevision), true, nil
+}
+
+func daemonSetRolloutStatus(obj runtime.Object) (string, bool, error) {
+	ds, ok := obj.(*appsv1.DaemonSet)
+	if !ok {
+		return "", false, fmt.Errorf("unexpected object type %T", obj)
+	}
+	if ds.Spec.UpdateStrategy.Type != appsv1.RollingUpdateDaemonSetStrategyType {
+		return "", false, errors.New("rollout status is only available for the RollingUpdate strategy type")
+	}
+	if ds.Generation > ds.Status.ObservedGeneration {
+		return "Waiting for daemon set spec update to be observed...", false, nil
+	}
+
+	status := ds.Status
+	if status.UpdatedNumberScheduled < status.DesiredNumberScheduled {
+		return fmt.Sprintf("Waiting for daemon set %q rollout to finish: %d out of %d new pods have been updated...", ds.Name, status.UpdatedNumberScheduled, status.DesiredNumberScheduled), false, nil
+	}
+	if status.NumberAvailable < status.DesiredNumberScheduled {
+		return fmt.Sprintf("Waiting for daemon set %q rollout to finish: %d of %d updated pods are available...", ds.Name, status.NumberAvailable, status.DesiredNumberScheduled), false, nil
+	}
+	return fmt.Sprintf("daemon set %q successfully rolled out", ds.Name), true, nil
+}
+
+func init() {
+	rootCmd.AddCommand(rolloutCmd)
+	rolloutCmd.AddCommand(rolloutStatusCmd)
+
+	rolloutStatusCmd.Flags().DurationVar(&rolloutStatusTimeout, "timeout", 0, "how long to wait for the rollout to finish before failing, zero means wait forever")
+}
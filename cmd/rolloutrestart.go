@@ -0,0 +1,181 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jimmidyson/kube-client-template/pkg/output"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// restartedAtAnnotation is set on the pod template of a workload to restart it, as the
+// change to the template rolls out new pods. It is the annotation kubectl uses.
+const restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+// restartableResources are the resources restarted when no TYPE is given.
+var restartableResources = []string{"deployments.apps", "statefulsets.apps", "daemonsets.apps"}
+
+var (
+	rolloutRestartSelector string
+	rolloutRestartDryRun   bool
+)
+
+// rolloutRestartCmd represents the rollout restart command
+var rolloutRestartCmd = &cobra.Command{
+	Use:   "restart [TYPE[/NAME] [NAME...]] [-l SELECTOR]",
+	Short: "Restart the pods of workloads",
+	Long: `Restart the pods of deployments, statefulsets and daemonsets, by rolling out
+a change to their pod templates.
+
+Workloads can be named, or selected by label. With a selector and no TYPE,
+the deployments, statefulsets and daemonsets matching it are all restarted.
+Workloads are restarted concurrently, at most --parallelism at once, and the
+result is reported for each one. For example:
+
+  kube-client-template rollout restart deployment/nginx
+  kube-client-template rollout restart -l app.kubernetes.io/part-of=shop --dry-run`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resources, names := restartableResources, []string(nil)
+		if len(args) > 0 {
+			resourceArg, argNames, err := splitResourceArgs(args)
+			if err != nil {
+				return err
+			}
+			resources, names = []string{resourceArg}, argNames
+		}
+		if len(names) == 0 && rolloutRestartSelector == "" {
+			return errors.New("must specify the names of the workloads to restart, or a selector with -l")
+		}
+		if len(names) > 0 && rolloutRestartSelector != "" {
+			return errors.New("names and a selector cannot both be specified")
+		}
+
+		var targets []restartTarget
+		for _, resource := range resources {
+			mapping, err := resourceMapping(resource)
+			if err != nil {
+				return err
+			}
+			if !isRestartable(mapping) {
+				return fmt.Errorf("rollout restart is not supported for %s", mapping.GroupVersionKind.Kind)
+			}
+			objs, err := selectObjects(mapping, namespace, names, rolloutRestartSelector)
+			if err != nil {
+				return err
+			}
+			for _, obj := range objs {
+				targets = append(targets, restartTarget{mapping: mapping, obj: obj, named: len(names) > 0})
+			}
+		}
+		if len(targets) == 0 {
+			fmt.Fprintln(os.Stderr, "No resources found.")
+			return nil
+		}
+		return restartAll(targets)
+	},
+}
+
+// isRestartable returns whether mapping is a deployment, statefulset or daemonset.
+func isRestartable(mapping *meta.RESTMapping) bool {
+	switch mapping.GroupVersionKind.Kind {
+	case "Deployment", "StatefulSet", "DaemonSet":
+		return true
+	}
+	return false
+}
+
+// restartTarget is a workload to restart.
+type restartTarget struct {
+	mapping *meta.RESTMapping
+	obj     *unstructured.Unstructured
+	// named is set for workloads given by name, which haven't been read yet.
+	named bool
+}
+
+// restartAll restarts targets with at most --parallelism requests in flight, then reports
+// the result of each in order.
+func restartAll(targets []restartTarget) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]string{restartedAtAnnotation: time.Now().Format(time.RFC3339)},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	errs := workerPool.Run(len(targets), func(i int) error {
+		target := targets[i]
+		if rolloutRestartDryRun && !target.named {
+			return nil
+		}
+		client, err := resourceClient(target.mapping, target.obj.GetNamespace())
+		if err != nil {
+			return err
+		}
+		if rolloutRestartDryRun {
+			// Named workloads are read, so that a dry run fails for those that don't
+			// exist as the restart would.
+			_, err = client.Get(target.obj.GetName(), metav1.GetOptions{})
+			return err
+		}
+		_, err = client.Patch(target.obj.GetName(), types.StrategicMergePatchType, patch)
+		return err
+	})
+
+	var failed int
+	for i, target := range targets {
+		name, err := output.QualifiedName(target.obj)
+		if err != nil {
+			return err
+		}
+		if err := errs[i]; err != nil {
+			failed++
+			logRawError(err)
+			logger.Error("failed to restart workload", append(errorFields(err), zap.String("name", name))...)
+			continue
+		}
+		if rolloutRestartDryRun {
+			fmt.Fprintf(os.Stdout, "%s restarted (dry run)\n", name)
+			continue
+		}
+		fmt.Fprintf(os.Stdout, "%s restarted\n", name)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to restart %d of %d workloads", failed, len(targets))
+	}
+	return nil
+}
+
+func init() {
+	rolloutCmd.AddCommand(rolloutRestartCmd)
+
+	rolloutRestartCmd.Flags().StringVarP(&rolloutRestartSelector, "selector", "l", "", "label selector of the workloads to restart, supports '=', '==', and '!=' (e.g. -l key1=value1,key2=value2)")
+	rolloutRestartCmd.Flags().BoolVar(&rolloutRestartDryRun, "dry-run", false, "only print the workloads that would be restarted, without restarting them")
+}
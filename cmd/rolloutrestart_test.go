@@ -0,0 +1,57 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// addDeployment adds a deployment named name in the default namespace.
+func (s *fakeServer) addDeployment(name string) {
+	s.add("deployments", map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":            name,
+			"namespace":       metav1.NamespaceDefault,
+			"uid":             "uid-" + name,
+			"resourceVersion": "1",
+		},
+	})
+}
+
+// TestRolloutRestartDryRunNamed checks that a dry run reports named workloads that don't
+// exist as failures, as restarting them would.
+func TestRolloutRestartDryRunNamed(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+	server.addDeployment("web")
+
+	result := runCommand(t, server, "rollout", "restart", "deployments", "web", "missing", "--dry-run")
+	if result.err == nil || !strings.Contains(result.err.Error(), "failed to restart 1 of 2 workloads") {
+		t.Errorf("error = %v, want the missing deployment to fail", result.err)
+	}
+	if want := "deployment.apps/web restarted (dry run)\n"; result.stdout != want {
+		t.Errorf("stdout = %q, want %q", result.stdout, want)
+	}
+
+	result = runCommand(t, server, "rollout", "restart", "deployments", "web", "--dry-run")
+	if result.err != nil {
+		t.Errorf("dry run of an existing deployment failed: %v", result.err)
+	}
+}
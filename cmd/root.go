@@ -16,7 +16,11 @@ package cmd
 
 import (
 	"flag"
+	"os"
+	"strings"
 
+	"github.com/jimmidyson/kube-client-template/pkg/kube"
+	"github.com/jimmidyson/kube-client-template/pkg/output"
 	"github.com/spf13/pflag"
 
 	homedir "github.com/mitchellh/go-homedir"
@@ -24,9 +28,12 @@ import (
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/flowcontrol"
 )
 
 var (
@@ -34,9 +41,20 @@ var (
 	logLevel                  = zapcore.InfoLevel
 	kubeConfigFile            string
 	kubeClientConfigOverrides = &clientcmd.ConfigOverrides{}
+	redactSecrets             bool
+	disableClientThrottling   bool
+	checkConnection           bool
+	quiet                     bool
+	kubeQPS                   float32
+	kubeBurst                 int
 
-	kubeClient *kubernetes.Clientset
-	logger     *zap.Logger
+	kubeFactory     kube.Factory
+	restConfig      *rest.Config
+	kubeClient      kubernetes.Interface
+	discoveryClient discovery.CachedDiscoveryInterface
+	restMapper      meta.RESTMapper
+	namespace       string
+	logger          *zap.Logger
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -51,42 +69,168 @@ This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
 	SilenceUsage: true,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		logConfig := zap.NewProductionConfig()
-		logConfig.Level.SetLevel(logLevel)
-		logConfig.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-		logConfig.EncoderConfig.EncodeDuration = zapcore.StringDurationEncoder
-		logger, _ = logConfig.Build()
-		_ = zap.ReplaceGlobals(logger)
-		_ = zap.RedirectStdLog(logger)
+		timer := newStartupTimer()
+		setupLogging(cmd)
+		detectStdoutWidth()
 		defer logger.Sync()
+		timer.phase("logging")
 
+		var err error
+		if retryClassifier, err = parseRetryOn(retryOn); err != nil {
+			logger.Fatal("invalid retry settings", zap.Error(err))
+		}
+		if parallelism < 1 {
+			logger.Fatal("--parallelism must be at least 1", zap.Int("parallelism", parallelism))
+		}
+		workerPool = newWorkerPool()
 		kubeConfigLoader := clientcmd.NewDefaultClientConfigLoadingRules()
 		if kubeConfigFile != "" {
 			logger.Info("using specified kube config file", zap.String("file", kubeConfigFile))
 			kubeConfigLoader.ExplicitPath = kubeConfigFile
 		}
-		kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(kubeConfigLoader, kubeClientConfigOverrides)
-		restConfig, err := kubeConfig.ClientConfig()
+		if disableClientThrottling {
+			logger.Warn("client-side throttling is disabled: request rate is now governed solely by the API server (API Priority and Fairness where enabled), " +
+				"so bulk operations may be rejected by the server instead of waiting")
+		}
+		kubeFactory = kube.NewFactory(kubeConfigLoader, kubeClientConfigOverrides, configureRateLimiting, configureRetries, configureCacheInvalidation)
+		if _, err = kubeFactory.RawConfig(); err != nil {
+			logRawError(err)
+			logger.Fatal("failed to load kubeconfig", errorFields(err)...)
+		}
+		timer.phase("kubeconfig")
+		restConfig, err = kubeFactory.RESTConfig()
 		if err != nil {
-			logger.Fatal("failed to get REST config", zap.Error(err))
+			logRawError(err)
+			logger.Fatal("failed to get REST config", errorFields(err)...)
+		}
+		timer.phase("restConfig")
+		kubeClient, err = kubeFactory.ClientSet()
+		if err != nil {
+			logRawError(err)
+			logger.Fatal("failed to create Kubernetes client", errorFields(err)...)
+		}
+		if discoveryClient, err = kubeFactory.DiscoveryClient(); err != nil {
+			logRawError(err)
+			logger.Fatal("failed to create discovery client", errorFields(err)...)
+		}
+		if restMapper, err = kubeFactory.RESTMapper(); err != nil {
+			logRawError(err)
+			logger.Fatal("failed to create REST mapper", errorFields(err)...)
 		}
-		kubeClient = kubernetes.NewForConfigOrDie(restConfig)
+		timer.phase("clients")
 
-		namespace, _, _ := kubeConfig.Namespace()
+		if checkConnection {
+			if _, err := kubeClient.Discovery().ServerVersion(); err != nil {
+				logRawError(err)
+				logger.Error("failed to connect to the API server", errorFields(err)...)
+				code := connectionExitCode(err)
+				logReloginHint(code)
+				_ = logger.Sync()
+				os.Exit(code)
+			}
+			timer.phase("connectivity")
+			// Reading through the shared discovery client fills its cache for the
+			// command.
+			if _, err := discoveryClient.ServerResources(); err != nil {
+				logDiscoveryFailure(err)
+			}
+			timer.phase("discovery")
+		}
+
+		namespace, _ = kubeFactory.Namespace()
 		logger.Debug("running against namespace", zap.String("namespace", namespace))
-		pods, err := kubeClient.Core().Pods(namespace).List(metav1.ListOptions{})
-		if err != nil {
-			logger.Fatal("failed to list pods", zap.Error(err))
+		if err := checkNamespaceRequired(cmd); err != nil {
+			logger.Fatal("refusing to run command", zap.Error(err))
 		}
-		logger.Info("returned pods", zap.Stringer("pods", pods))
+		timer.done()
 	},
 }
 
+// setupLogging builds the loggers, and applies the settings of the config file that
+// the flags of cmd don't override.
+func setupLogging(cmd *cobra.Command) {
+	var invalidLogLevel error
+	if !cmd.Flags().Changed("log-level") && viper.IsSet("log-level") {
+		invalidLogLevel = logLevel.UnmarshalText([]byte(viper.GetString("log-level")))
+	}
+
+	logConfig := zap.NewProductionConfig()
+	// The base logger logs everything, so that named loggers can be more verbose
+	// than the root logger.
+	logConfig.Level.SetLevel(zapcore.DebugLevel)
+	logConfig.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	logConfig.EncoderConfig.EncodeDuration = zapcore.StringDurationEncoder
+	var invalidColor error
+	if viper.GetString("log-format") == "console" {
+		logConfig.Encoding = "console"
+		logConfig.EncoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+		var color bool
+		if color, invalidColor = colorEnabled(); color {
+			var levelEncoder zapcore.LevelEncoder
+			var nameEncoder zapcore.NameEncoder
+			if levelEncoder, nameEncoder, invalidColor = colorEncoders(); invalidColor == nil {
+				logConfig.EncoderConfig.EncodeLevel = levelEncoder
+				logConfig.EncoderConfig.EncodeName = nameEncoder
+			}
+		}
+	}
+	baseLogger, _ = logConfig.Build()
+	rootLevel := zap.NewAtomicLevelAt(logLevel)
+	logger = baseLogger.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return &leveledCore{Core: c, level: rootLevel}
+	}))
+	_ = zap.ReplaceGlobals(logger)
+	_ = zap.RedirectStdLog(logger)
+
+	if file := viper.ConfigFileUsed(); file != "" {
+		logger.Debug("loaded config from file", zap.String("file", file))
+	}
+	if invalidColor != nil {
+		logger.Warn("ignoring invalid log color settings", zap.Error(invalidColor))
+	}
+	if invalidLogLevel != nil {
+		logger.Warn("ignoring invalid log-level in config", zap.Error(invalidLogLevel))
+	}
+	var err error
+	if loggerLevels, err = parseLoggerLevels(); err != nil {
+		logger.Fatal("invalid logger levels in config", zap.Error(err))
+	}
+	redactSecrets = viper.GetBool("redact-secrets")
+	noPager = viper.GetBool("no-pager")
+	tableStyle = viper.GetString("table-style")
+	requireNamespace = viper.GetBool("require-namespace")
+}
+
+// configureRateLimiting sets the client-side rate limiter of config from the
+// throttling flags.
+func configureRateLimiting(config *rest.Config) {
+	if disableClientThrottling {
+		config.RateLimiter = flowcontrol.NewFakeAlwaysRateLimiter()
+		return
+	}
+	config.QPS, config.Burst = kubeQPS, kubeBurst
+	clientRateLimiter = newMonitoredRateLimiter(kubeQPS, kubeBurst, namedLogger("client"))
+	config.RateLimiter = clientRateLimiter
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		logger.Fatal("root command failed", zap.Error(err))
+	err := rootCmd.Execute()
+	if clientRateLimiter != nil && !quiet {
+		clientRateLimiter.suggestLimits()
+	}
+	if err != nil {
+		if logger == nil {
+			// Invalid flags and arguments are rejected before logging is set up, and
+			// have already been printed by cobra.
+			os.Exit(exitCode(err))
+		}
+		logRawError(err)
+		logger.Error("root command failed", errorFields(err)...)
+		logReloginHint(exitCode(err))
+		_ = logger.Sync()
+		os.Exit(exitCode(err))
 	}
 }
 
@@ -96,10 +240,25 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.kube-client-template.yaml)")
 	rootCmd.PersistentFlags().AddGoFlag(&flag.Flag{
 		Name:     "log-level",
-		Usage:    "log level",
+		Usage:    "log level, overridden for the client and discovery loggers by the loggers config key",
 		Value:    &logLevel,
 		DefValue: zapcore.InfoLevel.String(),
 	})
+	rootCmd.PersistentFlags().StringVar(&logColor, "color", "auto", "color console logs, one of: auto|always|never, auto colors them when stderr is a terminal and NO_COLOR isn't set")
+	rootCmd.PersistentFlags().StringVar(&logColorTheme, "log-color-theme", "default", "color theme of console logs, one of: default|solarized|mono")
+	rootCmd.PersistentFlags().BoolVar(&redactSecrets, "redact-secrets", false, "replace the values of Secret data with "+output.RedactedValue+" in all output")
+	rootCmd.PersistentFlags().BoolVar(&checkConnection, "check-connection", false, "check the API server is reachable and warm the discovery cache before running the command")
+	rootCmd.PersistentFlags().BoolVar(&showDiscoveryErrors, "show-discovery-errors", false, "log each API group version that fails discovery along with its error, e.g. to debug unavailable aggregated APIs")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "suppress progress and summary output, leaving only results and errors")
+	rootCmd.PersistentFlags().DurationVar(&cacheTTL, "cache-ttl", 0, "how long objects read by a command are reused by its later reads, e.g. 2s, off by default")
+	rootCmd.PersistentFlags().BoolVar(&noPager, "no-pager", false, "never page table output, which is otherwise paged with $KUBE_CLIENT_TEMPLATE_PAGER, $PAGER or \""+defaultPager+"\" when stdout is a terminal")
+	rootCmd.PersistentFlags().StringVar(&tableStyle, "table-style", output.TableStyleCompact, "style of tables, one of: compact|bordered, bordered tables are fitted to the width of the terminal")
+	rootCmd.PersistentFlags().BoolVar(&requireNamespace, "require-namespace", false, "refuse to run commands that change objects in the default namespace, unless it is passed explicitly with --"+namespaceFlag)
+	rootCmd.PersistentFlags().BoolVar(&disableClientThrottling, "disable-client-side-throttling", false, "disable client-side rate limiting of API requests, leaving it to the API server")
+	rootCmd.PersistentFlags().Float32Var(&kubeQPS, "kube-qps", rest.DefaultQPS, "maximum sustained queries per second to the API server")
+	rootCmd.PersistentFlags().StringSliceVar(&retryOn, "retry-on", defaultRetryOn, "errors to retry requests on, any of: a status code (e.g. 429), a class of status codes (e.g. 5xx), connection-reset, connection-refused or timeout")
+	rootCmd.PersistentFlags().IntVar(&kubeBurst, "kube-burst", rest.DefaultBurst, "maximum burst of queries to the API server")
+	rootCmd.PersistentFlags().IntVar(&parallelism, "parallelism", defaultParallelism, "maximum number of requests made at once by commands acting on many objects or namespaces, at most --kube-burst unless client-side throttling is disabled")
 
 	kubernetesFlagSet := pflag.NewFlagSet("Kubernetes configuration", pflag.ContinueOnError)
 	clientcmd.BindOverrideFlags(kubeClientConfigOverrides, kubernetesFlagSet, clientcmd.RecommendedConfigOverrideFlags("kubernetes-"))
@@ -109,8 +268,16 @@ func init() {
 	})
 
 	rootCmd.PersistentFlags().AddFlagSet(kubernetesFlagSet)
+
+	// Settings that can also be persisted with config set.
+	for _, name := range []string{"redact-secrets", "no-pager", "table-style", "require-namespace"} {
+		_ = viper.BindPFlag(name, rootCmd.PersistentFlags().Lookup(name))
+	}
 }
 
+// envPrefix is the prefix of the environment variables that settings are read from.
+const envPrefix = "KUBE_CLIENT_TEMPLATE"
+
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
 	if cfgFile != "" {
@@ -126,10 +293,12 @@ func initConfig() {
 		viper.SetConfigName(".kube-client-template")
 	}
 
-	viper.AutomaticEnv() // read in environment variables that match
+	// Read in environment variables that match, e.g. KUBE_CLIENT_TEMPLATE_LOG_LEVEL for
+	// log-level. The prefix keeps unrelated variables such as OUTPUT from applying.
+	viper.SetEnvPrefix(envPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_", ".", "_"))
+	viper.AutomaticEnv()
 
-	// If a config file is found, read it in.
-	if err := viper.ReadInConfig(); err == nil {
-		logger.Info("loaded config from file", zap.String("file", viper.ConfigFileUsed()))
-	}
+	// If a config file is found, read it in. It is logged once the logger is set up.
+	_ = viper.ReadInConfig()
 }
@@ -0,0 +1,146 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jimmidyson/kube-client-template/pkg/output"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	scaleReplicas        int
+	scaleCurrentReplicas int
+	scaleSelector        string
+)
+
+// scaleCmd represents the scale command
+var scaleCmd = &cobra.Command{
+	Use:   "scale TYPE[/NAME] [NAME...] --replicas=COUNT",
+	Short: "Set the number of replicas of scalable resources",
+	Long: `Set the number of replicas of scalable resources.
+
+Objects can be named, or selected by label to scale many objects at once.
+Objects are scaled concurrently, at most --parallelism at once, and the
+result is reported for each one.
+With --current-replicas, each object is only scaled if it currently has
+that many replicas. For example:
+
+  kube-client-template scale deployment/nginx --replicas=3
+  kube-client-template scale deployments -l tier=frontend --replicas=0 --current-replicas=2`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if scaleReplicas < 0 {
+			return errors.New("--replicas=COUNT is required, and COUNT must be greater than or equal to 0")
+		}
+
+		resourceArg, names, err := splitResourceArgs(args)
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 && scaleSelector == "" {
+			return errors.New("must specify the names of the objects to scale, or a selector with -l")
+		}
+		if len(names) > 0 && scaleSelector != "" {
+			return errors.New("names and a selector cannot both be specified")
+		}
+
+		mapping, err := resourceMapping(resourceArg)
+		if err != nil {
+			return err
+		}
+		scalable, err := hasSubresource(mapping, "scale")
+		if err != nil {
+			return err
+		}
+		if !scalable {
+			return fmt.Errorf("%s cannot be scaled", mapping.Resource)
+		}
+
+		targets, err := selectObjects(mapping, namespace, names, scaleSelector)
+		if err != nil {
+			return err
+		}
+		if len(targets) == 0 {
+			fmt.Fprintln(os.Stderr, "No resources found.")
+			return nil
+		}
+		return scaleAll(mapping, targets)
+	},
+}
+
+// scaleAll scales targets with the worker pool, then reports the result of each in order.
+func scaleAll(mapping *meta.RESTMapping, targets []*unstructured.Unstructured) error {
+	errs := workerPool.Run(len(targets), func(i int) error {
+		return scaleObject(mapping, targets[i])
+	})
+
+	var failed int
+	for i, obj := range targets {
+		name, err := output.QualifiedName(obj)
+		if err != nil {
+			return err
+		}
+		if err := errs[i]; err != nil {
+			failed++
+			logRawError(err)
+			logger.Error("failed to scale object", append(errorFields(err), zap.String("name", name))...)
+			continue
+		}
+		fmt.Fprintf(os.Stdout, "%s scaled\n", name)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to scale %d of %d objects", failed, len(targets))
+	}
+	return nil
+}
+
+// scaleObject sets the replicas of obj through its scale subresource. The scale is written
+// back with the resource version it was read at, so a concurrent change fails rather
+// than invalidating the --current-replicas precondition.
+func scaleObject(mapping *meta.RESTMapping, obj *unstructured.Unstructured) error {
+	scale, err := readSubresource(mapping, obj.GetNamespace(), obj.GetName(), "scale")
+	if err != nil {
+		return err
+	}
+	// Zero replicas are omitted from the spec.
+	current, _, err := unstructured.NestedInt64(scale.Object, "spec", "replicas")
+	if err != nil {
+		return err
+	}
+	if scaleCurrentReplicas >= 0 && current != int64(scaleCurrentReplicas) {
+		return fmt.Errorf("expected replicas to be %d, was %d", scaleCurrentReplicas, current)
+	}
+
+	if err := unstructured.SetNestedField(scale.Object, int64(scaleReplicas), "spec", "replicas"); err != nil {
+		return err
+	}
+	_, err = writeSubresource(mapping, obj.GetNamespace(), obj.GetName(), "scale", scale)
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(scaleCmd)
+
+	scaleCmd.Flags().IntVar(&scaleReplicas, "replicas", -1, "the new number of replicas")
+	scaleCmd.Flags().IntVar(&scaleCurrentReplicas, "current-replicas", -1, "only scale objects that currently have this many replicas, -1 for no precondition")
+	scaleCmd.Flags().StringVarP(&scaleSelector, "selector", "l", "", "label selector of the objects to scale, supports '=', '==', and '!=' (e.g. -l key1=value1,key2=value2)")
+}
@@ -0,0 +1,152 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// getObjectRef gets the object referenced in TYPE/NAME form in namespace.
+func getObjectRef(ref, namespace string) (*meta.RESTMapping, *unstructured.Unstructured, error) {
+	i := strings.Index(ref, "/")
+	if i <= 0 || i == len(ref)-1 {
+		return nil, nil, fmt.Errorf("invalid object reference %q: must be in TYPE/NAME form", ref)
+	}
+	mapping, err := resourceMapping(ref[:i])
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := resourceClient(mapping, namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+	obj, err := client.Get(ref[i+1:], metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	return mapping, obj, nil
+}
+
+// isPodMapping returns whether mapping is for core pods.
+func isPodMapping(mapping *meta.RESTMapping) bool {
+	gvk := mapping.GroupVersionKind
+	return gvk.Group == "" && gvk.Kind == "Pod"
+}
+
+// podsFor returns the pods belonging to the object referenced in TYPE/NAME form: either
+// a label selector of the pods managed by a workload, or the names of the pods that are
+// endpoints of a service.
+func podsFor(ref, namespace string) (labels.Selector, sets.String, error) {
+	mapping, obj, err := getObjectRef(ref, namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var selector labels.Selector
+	switch gvk := mapping.GroupVersionKind; {
+	case gvk.Group == "" && gvk.Kind == "Service":
+		// A service's selector isn't the whole story: services without one have their
+		// endpoints managed by hand, and only the endpoints show which pods it targets.
+		names, err := endpointPods(obj.GetNamespace(), obj.GetName())
+		return nil, names, err
+	case gvk.Group == "" && gvk.Kind == "ReplicationController":
+		// Replication controllers predate label selectors, and select on a plain map of
+		// labels.
+		set, _, err := unstructured.NestedStringMap(obj.Object, "spec", "selector")
+		if err != nil {
+			return nil, nil, err
+		}
+		selector = labels.SelectorFromSet(set)
+	default:
+		raw, found, err := unstructured.NestedMap(obj.Object, "spec", "selector")
+		if err != nil {
+			return nil, nil, err
+		}
+		if !found {
+			return nil, nil, fmt.Errorf("%s has no pod selector", ref)
+		}
+		labelSelector := &metav1.LabelSelector{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(raw, labelSelector); err != nil {
+			return nil, nil, fmt.Errorf("invalid pod selector of %s: %v", ref, err)
+		}
+		if selector, err = metav1.LabelSelectorAsSelector(labelSelector); err != nil {
+			return nil, nil, fmt.Errorf("invalid pod selector of %s: %v", ref, err)
+		}
+	}
+
+	// An empty selector would select every pod in the namespace.
+	if selector.Empty() {
+		return nil, nil, fmt.Errorf("%s has an empty pod selector", ref)
+	}
+	return selector, nil, nil
+}
+
+// endpointPods returns the names of the pods that are endpoints of the named service,
+// ready or not. A service without an Endpoints object has none.
+func endpointPods(namespace, service string) (sets.String, error) {
+	names := sets.NewString()
+	endpoints, err := kubeClient.CoreV1().Endpoints(namespace).Get(service, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return names, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, subset := range endpoints.Subsets {
+		for _, addresses := range [][]corev1.EndpointAddress{subset.Addresses, subset.NotReadyAddresses} {
+			for _, address := range addresses {
+				ref := address.TargetRef
+				if ref != nil && ref.Kind == "Pod" && (ref.Namespace == "" || ref.Namespace == namespace) {
+					names.Insert(ref.Name)
+				}
+			}
+		}
+	}
+	return names, nil
+}
+
+// filterByName returns the objects of objs named in names.
+func filterByName(objs []unstructured.Unstructured, names sets.String) []unstructured.Unstructured {
+	var filtered []unstructured.Unstructured
+	for _, obj := range objs {
+		if names.Has(obj.GetName()) {
+			filtered = append(filtered, obj)
+		}
+	}
+	return filtered
+}
+
+// filterWatchByName filters the watch events of w to those of objects named in names.
+// Errors are always passed on.
+func filterWatchByName(w watch.Interface, names sets.String) watch.Interface {
+	return watch.Filter(w, func(event watch.Event) (watch.Event, bool) {
+		if event.Type == watch.Error {
+			return event, true
+		}
+		obj, err := meta.Accessor(event.Object)
+		return event, err == nil && names.Has(obj.GetName())
+	})
+}
@@ -0,0 +1,101 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// addService adds a service named name selecting pods labelled app=name, with the named
+// pods as its ready and not ready endpoints. With no endpoints, the service has no
+// Endpoints object.
+func (s *fakeServer) addService(name string, ready, notReady []string) {
+	s.add("services", map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata":   map[string]interface{}{"name": name, "namespace": metav1.NamespaceDefault, "uid": "uid-" + name},
+		"spec":       map[string]interface{}{"selector": map[string]interface{}{"app": name}},
+	})
+	if len(ready)+len(notReady) == 0 {
+		return
+	}
+	addresses := func(pods []string) []interface{} {
+		var addresses []interface{}
+		for i, pod := range pods {
+			addresses = append(addresses, map[string]interface{}{
+				"ip":        fmt.Sprintf("10.0.0.%d", i+1),
+				"targetRef": map[string]interface{}{"kind": "Pod", "namespace": metav1.NamespaceDefault, "name": pod},
+			})
+		}
+		return addresses
+	}
+	s.add("endpoints", map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Endpoints",
+		"metadata":   map[string]interface{}{"name": name, "namespace": metav1.NamespaceDefault},
+		"subsets": []interface{}{map[string]interface{}{
+			"addresses":         addresses(ready),
+			"notReadyAddresses": addresses(notReady),
+		}},
+	})
+}
+
+func TestGetPodsForService(t *testing.T) {
+	tests := []struct {
+		name            string
+		ready, notReady []string
+		args            []string
+		want            []string
+		wantStderr      string
+	}{
+		{name: "endpoints", ready: []string{"web-1"}, notReady: []string{"web-3"}, want: []string{"pod/web-1", "pod/web-3"}},
+		{name: "endpoints and selector", ready: []string{"web-1", "web-3"}, args: []string{"-l", "tier=front"}, want: []string{"pod/web-1"}},
+		{name: "no endpoints", wantStderr: "No resources found."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+			// web-2 matches the selector of the service, but isn't one of its endpoints.
+			server.addPod("web-1", map[string]string{"app": "web", "tier": "front"})
+			server.addPod("web-2", map[string]string{"app": "web", "tier": "front"})
+			server.addPod("web-3", map[string]string{"app": "web", "tier": "back"})
+			server.addService("web", tt.ready, tt.notReady)
+
+			result := runCommand(t, server, append([]string{"get", "pods", "--for", "service/web", "-o", "name"}, tt.args...)...)
+			if result.err != nil {
+				t.Fatalf("unexpected error: %v (stderr: %s)", result.err, result.stderr)
+			}
+			var got []string
+			for _, line := range strings.Split(strings.TrimSpace(result.stdout), "\n") {
+				if line != "" {
+					got = append(got, line)
+				}
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("printed %v, want %v", got, tt.want)
+			}
+			if !strings.Contains(result.stderr, tt.wantStderr) {
+				t.Errorf("stderr = %q, want it to contain %q", result.stderr, tt.wantStderr)
+			}
+		})
+	}
+}
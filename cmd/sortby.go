@@ -0,0 +1,84 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// sortObjects sorts items in ascending order of the field at the JSONPath expression
+// field, e.g. ".count". Numbers are compared numerically and anything else as text.
+// Objects missing the field sort first.
+func sortObjects(items []unstructured.Unstructured, field string) error {
+	if !strings.HasPrefix(field, "{") {
+		field = "{" + field + "}"
+	}
+	j := jsonpath.New("sort-by").AllowMissingKeys(true)
+	if err := j.Parse(field); err != nil {
+		return fmt.Errorf("invalid --sort-by %s: %v", field, err)
+	}
+
+	keys := make([]interface{}, len(items))
+	for i := range items {
+		results, err := j.FindResults(items[i].Object)
+		if err != nil {
+			return fmt.Errorf("failed to evaluate --sort-by %s: %v", field, err)
+		}
+		if len(results) > 0 && len(results[0]) > 0 {
+			keys[i] = results[0][0].Interface()
+		}
+	}
+
+	sorted := make([]int, len(items))
+	for i := range sorted {
+		sorted[i] = i
+	}
+	sort.SliceStable(sorted, func(a, b int) bool { return lessSortKey(keys[sorted[a]], keys[sorted[b]]) })
+	reordered := make([]unstructured.Unstructured, len(items))
+	for i, j := range sorted {
+		reordered[i] = items[j]
+	}
+	copy(items, reordered)
+	return nil
+}
+
+func lessSortKey(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b != nil
+	}
+	af, aNumber := sortNumber(a)
+	bf, bNumber := sortNumber(b)
+	if aNumber && bNumber {
+		return af < bf
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
+
+func sortNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
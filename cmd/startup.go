@@ -0,0 +1,49 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// startupTimer times the phases of startup, to find where slow invocations spend
+// their time.
+type startupTimer struct {
+	start      time.Time
+	phaseStart time.Time
+	phases     []zapcore.Field
+}
+
+func newStartupTimer() *startupTimer {
+	now := time.Now()
+	return &startupTimer{start: now, phaseStart: now}
+}
+
+// phase logs the time taken since the previous phase ended as the named phase.
+func (t *startupTimer) phase(name string) {
+	now := time.Now()
+	elapsed := now.Sub(t.phaseStart)
+	logger.Debug("startup phase finished", zap.String("phase", name), zap.Duration("elapsed", elapsed))
+	t.phases = append(t.phases, zap.Duration(name, elapsed))
+	t.phaseStart = now
+}
+
+// done logs a summary of all phases and the total startup time.
+func (t *startupTimer) done() {
+	logger.Debug("startup finished", append(t.phases, zap.Duration("total", time.Since(t.start)))...)
+}
@@ -0,0 +1,109 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jimmidyson/kube-client-template/pkg/output"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// defaultSummaryInterval is the window over which watch events are counted by --summary.
+const defaultSummaryInterval = 10 * time.Second
+
+// eventCounts counts watch events by type.
+type eventCounts struct {
+	added, modified, deleted int
+}
+
+func (c *eventCounts) add(t watch.EventType) {
+	switch t {
+	case watch.Added:
+		c.added++
+	case watch.Modified:
+		c.modified++
+	case watch.Deleted:
+		c.deleted++
+	}
+}
+
+// summarizeEvents counts the events of w by type, printing the counts of each window of
+// interval with printer and then resetting them. When the watch closes, or the command is
+// interrupted, the counts of the last window and the total over the whole watch are
+// printed.
+func summarizeEvents(w watch.Interface, resource string, interval time.Duration, printer output.Printer) error {
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupted)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var window, total eventCounts
+	windowEnd := func(label string) error {
+		err := printCounts(printer, label, resource, window)
+		window = eventCounts{}
+		return err
+	}
+	finish := func(err error) error {
+		if windowErr := windowEnd(time.Now().Format(time.RFC3339)); err == nil {
+			err = windowErr
+		}
+		if totalErr := printCounts(printer, "TOTAL", resource, total); err == nil {
+			err = totalErr
+		}
+		return err
+	}
+
+	for {
+		select {
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return finish(nil)
+			}
+			if event.Type == watch.Error {
+				return finish(watchError(event.Object))
+			}
+			window.add(event.Type)
+			total.add(event.Type)
+		case now := <-ticker.C:
+			if err := windowEnd(now.Format(time.RFC3339)); err != nil {
+				return err
+			}
+		case <-interrupted:
+			return finish(nil)
+		}
+	}
+}
+
+// summaryColumns are the columns printed by summarizeEvents.
+var summaryColumns = []string{"WINDOW", "RESOURCE", "ADDED", "MODIFIED", "DELETED"}
+
+// printCounts prints the counts c of a window as a table row. Each window is printed on
+// its own, so cells are padded to fixed widths to keep the rows of every window aligned.
+func printCounts(printer output.Printer, label, resource string, c eventCounts) error {
+	row := []string{
+		fmt.Sprintf("%-25s", label),
+		fmt.Sprintf("%-*s", len(summaryColumns[1]), resource),
+		fmt.Sprintf("%*d", len(summaryColumns[2]), c.added),
+		fmt.Sprintf("%*d", len(summaryColumns[3]), c.modified),
+		fmt.Sprintf("%*d", len(summaryColumns[4]), c.deleted),
+	}
+	return printer.PrintObj(&output.Table{Columns: summaryColumns, Rows: [][]string{row}}, os.Stdout)
+}
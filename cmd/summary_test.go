@@ -0,0 +1,103 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jimmidyson/kube-client-template/pkg/output"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestSummarizeEvents(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags output.Flags
+		// wantLines are the fields of each printed line, without the window time.
+		wantLines [][]string
+	}{
+		{
+			name:      "headers",
+			wantLines: [][]string{{"WINDOW", "RESOURCE", "ADDED", "MODIFIED", "DELETED"}, {"pods", "2", "1", "1"}, {"TOTAL", "pods", "2", "1", "1"}},
+		},
+		{
+			name:      "no headers",
+			flags:     output.Flags{NoHeaders: true},
+			wantLines: [][]string{{"pods", "2", "1", "1"}, {"TOTAL", "pods", "2", "1", "1"}},
+		},
+		{
+			name:      "bordered",
+			flags:     output.Flags{TableStyle: output.TableStyleBordered},
+			wantLines: [][]string{{"WINDOW", "|", "RESOURCE", "|", "ADDED", "|", "MODIFIED", "|", "DELETED"}, nil, {"|", "pods", "|", "2", "|", "1", "|", "1"}, {"TOTAL", "|", "pods", "|", "2", "|", "1", "|", "1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			printer, err := output.PrinterFor(tt.flags)
+			if err != nil {
+				t.Fatalf("PrinterFor() failed: %v", err)
+			}
+			w := watch.NewFake()
+			go func() {
+				pod := &unstructured.Unstructured{}
+				for _, send := range []func(){func() { w.Add(pod) }, func() { w.Add(pod) }, func() { w.Modify(pod) }, func() { w.Delete(pod) }} {
+					send()
+				}
+				w.Stop()
+			}()
+
+			stdout := captureOutput(t, &os.Stdout)
+			err = summarizeEvents(w, "pods", time.Hour, printer)
+			printed := stdout()
+			if err != nil {
+				t.Fatalf("summarizeEvents() failed: %v", err)
+			}
+
+			lines := strings.Split(strings.TrimSuffix(printed, "\n"), "\n")
+			if len(lines) != len(tt.wantLines) {
+				t.Fatalf("summarizeEvents() printed %d lines, want %d:\n%s", len(lines), len(tt.wantLines), printed)
+			}
+			for i, want := range tt.wantLines {
+				if want == nil {
+					continue
+				}
+				got := strings.Fields(lines[i])
+				// Window rows start with the time the window ended.
+				if want[0] != "WINDOW" && want[0] != "TOTAL" {
+					got = got[1:]
+				}
+				if strings.Join(got, " ") != strings.Join(want, " ") {
+					t.Errorf("line %d is %q, want fields %q", i, lines[i], want)
+				}
+			}
+			if widths := lineWidths(lines); tt.flags.TableStyle == "" && widths[len(widths)-1] != widths[len(widths)-2] {
+				t.Errorf("rows aren't aligned:\n%s", printed)
+			}
+		})
+	}
+}
+
+// lineWidths returns the length of each of lines.
+func lineWidths(lines []string) []int {
+	var widths []int
+	for _, line := range lines {
+		widths = append(widths, len(line))
+	}
+	return widths
+}
@@ -0,0 +1,47 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/jimmidyson/kube-client-template/pkg/output"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var (
+	tableStyle string
+	// stdoutWidth is the width of the terminal stdout is attached to, or 0 if it isn't a
+	// terminal. It is read at startup, as paging replaces os.Stdout with a pipe.
+	stdoutWidth int
+)
+
+func detectStdoutWidth() {
+	fd := int(os.Stdout.Fd())
+	if !terminal.IsTerminal(fd) {
+		return
+	}
+	if width, _, err := terminal.GetSize(fd); err == nil {
+		stdoutWidth = width
+	}
+}
+
+// withTableStyle returns flags with the table style selected by --table-style, fitted to
+// the terminal.
+func withTableStyle(flags output.Flags) output.Flags {
+	flags.TableStyle = tableStyle
+	flags.Width = stdoutWidth
+	return flags
+}
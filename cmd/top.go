@@ -0,0 +1,507 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/jimmidyson/kube-client-template/pkg/output"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// metricsAPIPath is the path of the resource metrics API served by metrics-server.
+const metricsAPIPath = "/apis/metrics.k8s.io/v1beta1"
+
+var (
+	topPodsSelector      string
+	topPodsAllNamespaces bool
+	topPodsContainers    bool
+	topPodsSortBy        string
+	topPodsOutput        string
+
+	topNodesSelector string
+	topNodesSortBy   string
+	topNodesOutput   string
+)
+
+// topCmd represents the top command
+var topCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Display resource (CPU/memory) usage",
+	Long: `Display resource (CPU/memory) usage.
+
+Usage is read from the resource metrics API, which requires metrics-server
+(or another implementation of metrics.k8s.io) to be running in the cluster.
+
+With -o json, usage is printed as records for programs, with CPU in
+millicores and memory in bytes alongside the quantities shown in tables.`,
+}
+
+// topPodsCmd represents the top pods command
+var topPodsCmd = &cobra.Command{
+	Use:   "pods [NAME]",
+	Short: "Display resource (CPU/memory) usage of pods",
+	Long: `Display resource (CPU/memory) usage of pods.
+
+Pods can be filtered by label selector to analyze a single workload. With
+--containers, the usage of each container is shown rather than the total of
+each pod, to right-size the resources of individual containers. Rows can be
+sorted by usage, highest first, with --sort-by. For example:
+
+  kube-client-template top pods -l app=nginx --all-namespaces
+  kube-client-template top pods -l app=nginx --containers --sort-by=memory
+  kube-client-template top pods --all-namespaces -o json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var name string
+		if len(args) > 0 {
+			name = args[0]
+		}
+		if name != "" && topPodsSelector != "" {
+			return fmt.Errorf("a pod name and a selector cannot both be specified")
+		}
+		if err := validateTopFlags(topPodsSortBy, topPodsOutput); err != nil {
+			return err
+		}
+		selector, err := labels.Parse(topPodsSelector)
+		if err != nil {
+			return fmt.Errorf("invalid selector %q: %v", topPodsSelector, err)
+		}
+
+		ns := namespace
+		if topPodsAllNamespaces {
+			ns = metav1.NamespaceAll
+		}
+		metrics, err := podMetrics(ns, name, selector)
+		if err != nil {
+			return err
+		}
+		if len(metrics) == 0 {
+			fmt.Fprintln(os.Stderr, "No resources found.")
+			return nil
+		}
+		rows := usageRows(metrics)
+		if topPodsOutput == "json" {
+			var records []usageRecord
+			for _, r := range rows {
+				records = append(records, newUsageRecord(r.namespace, r.pod, r.container, r.usage))
+			}
+			return printUsageJSON(os.Stdout, records)
+		}
+		defer pageOutput(true)()
+		return printPodMetrics(os.Stdout, rows, topPodsAllNamespaces)
+	},
+}
+
+// topNodesCmd represents the top nodes command
+var topNodesCmd = &cobra.Command{
+	Use:   "nodes [NAME]",
+	Short: "Display resource (CPU/memory) usage of nodes",
+	Long: `Display resource (CPU/memory) usage of nodes, along with the percentage of
+each node's allocatable resources that is used.
+
+Nodes can be filtered by label selector, and rows can be sorted by usage,
+highest first, with --sort-by. For example:
+
+  kube-client-template top nodes -l pool=default --sort-by=cpu
+  kube-client-template top nodes -o json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var name string
+		if len(args) > 0 {
+			name = args[0]
+		}
+		if name != "" && topNodesSelector != "" {
+			return fmt.Errorf("a node name and a selector cannot both be specified")
+		}
+		if err := validateTopFlags(topNodesSortBy, topNodesOutput); err != nil {
+			return err
+		}
+		selector, err := labels.Parse(topNodesSelector)
+		if err != nil {
+			return fmt.Errorf("invalid selector %q: %v", topNodesSelector, err)
+		}
+
+		rows, err := nodeUsageRows(name, selector)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			fmt.Fprintln(os.Stderr, "No resources found.")
+			return nil
+		}
+		if topNodesOutput == "json" {
+			var records []usageRecord
+			for _, r := range rows {
+				record := newUsageRecord("", r.name, "", r.usage)
+				record.CPUPercent = usagePercent(*r.usage.Cpu(), *r.allocatable.Cpu())
+				record.MemoryPercent = usagePercent(*r.usage.Memory(), *r.allocatable.Memory())
+				records = append(records, record)
+			}
+			return printUsageJSON(os.Stdout, records)
+		}
+		defer pageOutput(true)()
+		return printNodeMetrics(os.Stdout, rows)
+	},
+}
+
+func validateTopFlags(sortBy, outputFormat string) error {
+	switch sortBy {
+	case "", "cpu", "memory":
+	default:
+		return fmt.Errorf("invalid sort field %q: must be one of cpu or memory", sortBy)
+	}
+	switch outputFormat {
+	case "", "json":
+	default:
+		return fmt.Errorf("unsupported output format %q: must be json", outputFormat)
+	}
+	return nil
+}
+
+// podMetricsList and the types below mirror the subset of metrics.k8s.io/v1beta1
+// that is needed to report usage.
+type podMetricsList struct {
+	Items []podMetricsItem `json:"items"`
+}
+
+type podMetricsItem struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Timestamp         metav1.Time        `json:"timestamp"`
+	Window            metav1.Duration    `json:"window"`
+	Containers        []containerMetrics `json:"containers"`
+}
+
+type containerMetrics struct {
+	Name  string              `json:"name"`
+	Usage corev1.ResourceList `json:"usage"`
+}
+
+// usage returns the summed usage of all containers in the pod.
+func (m *podMetricsItem) usage() corev1.ResourceList {
+	total := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.Quantity{},
+		corev1.ResourceMemory: resource.Quantity{},
+	}
+	for _, c := range m.Containers {
+		for name, quantity := range c.Usage {
+			sum := total[name]
+			sum.Add(quantity)
+			total[name] = sum
+		}
+	}
+	return total
+}
+
+// nodeMetricsList and nodeMetricsItem mirror the node metrics of metrics.k8s.io/v1beta1.
+type nodeMetricsList struct {
+	Items []nodeMetricsItem `json:"items"`
+}
+
+type nodeMetricsItem struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Timestamp         metav1.Time         `json:"timestamp"`
+	Window            metav1.Duration     `json:"window"`
+	Usage             corev1.ResourceList `json:"usage"`
+}
+
+// getMetrics gets the metrics at the path of segments under the metrics API, either of the
+// named object or of all objects matching selector. The selector is evaluated by the
+// metrics API.
+func getMetrics(segments []string, name string, selector labels.Selector) ([]byte, error) {
+	segments = append([]string{metricsAPIPath}, segments...)
+	if name != "" {
+		segments = append(segments, name)
+	}
+	req := kubeClient.CoreV1().RESTClient().Get().AbsPath(segments...)
+	if !selector.Empty() {
+		req = req.Param("labelSelector", selector.String())
+	}
+	data, err := req.DoRaw()
+	if err != nil && apierrors.IsNotFound(err) && name == "" {
+		return nil, fmt.Errorf("metrics API not available: %v", err)
+	}
+	return data, err
+}
+
+// podMetrics fetches pod metrics in namespace, either for the named pod or for all pods
+// matching selector.
+func podMetrics(namespace, name string, selector labels.Selector) ([]podMetricsItem, error) {
+	var segments []string
+	if namespace != metav1.NamespaceAll {
+		segments = append(segments, "namespaces", namespace)
+	}
+	data, err := getMetrics(append(segments, "pods"), name, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	if name != "" {
+		var item podMetricsItem
+		if err := json.Unmarshal(data, &item); err != nil {
+			return nil, fmt.Errorf("failed to decode pod metrics: %v", err)
+		}
+		return []podMetricsItem{item}, nil
+	}
+	var list podMetricsList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to decode pod metrics: %v", err)
+	}
+	return list.Items, nil
+}
+
+// usageRow is the usage of a pod, or of one of its containers with --containers.
+type usageRow struct {
+	namespace string
+	pod       string
+	container string
+	usage     corev1.ResourceList
+}
+
+// usageRows returns the rows of metrics, sorted by namespace and name or by --sort-by.
+func usageRows(metrics []podMetricsItem) []usageRow {
+	var rows []usageRow
+	for i := range metrics {
+		m := &metrics[i]
+		if !topPodsContainers {
+			rows = append(rows, usageRow{namespace: m.Namespace, pod: m.Name, usage: m.usage()})
+			continue
+		}
+		for _, c := range m.Containers {
+			rows = append(rows, usageRow{namespace: m.Namespace, pod: m.Name, container: c.Name, usage: c.Usage})
+		}
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if c := compareUsage(a.usage, b.usage, topPodsSortBy); c != 0 {
+			return c > 0
+		}
+		if a.namespace != b.namespace {
+			return a.namespace < b.namespace
+		}
+		if a.pod != b.pod {
+			return a.pod < b.pod
+		}
+		return a.container < b.container
+	})
+	return rows
+}
+
+// compareUsage compares the usage of the resource named by sortBy, cpu or memory, of a and
+// b. It returns 0 if sortBy is empty.
+func compareUsage(a, b corev1.ResourceList, sortBy string) int {
+	switch sortBy {
+	case "cpu":
+		return a.Cpu().Cmp(*b.Cpu())
+	case "memory":
+		return a.Memory().Cmp(*b.Memory())
+	}
+	return 0
+}
+
+func printPodMetrics(out io.Writer, rows []usageRow, withNamespace bool) error {
+	table := &output.Table{Columns: []string{"NAME", "CPU(cores)", "MEMORY(bytes)"}}
+	if topPodsContainers {
+		table.Columns = []string{"POD", "NAME", "CPU(cores)", "MEMORY(bytes)"}
+	}
+	if withNamespace {
+		table.Columns = append([]string{"NAMESPACE"}, table.Columns...)
+	}
+	for _, r := range rows {
+		row := []string{r.pod}
+		if topPodsContainers {
+			row = append(row, r.container)
+		}
+		row = append(row, formatCPU(*r.usage.Cpu()), formatMemory(*r.usage.Memory()))
+		if withNamespace {
+			row = append([]string{r.namespace}, row...)
+		}
+		table.Rows = append(table.Rows, row)
+	}
+
+	printer, err := output.PrinterFor(withTableStyle(output.Flags{}))
+	if err != nil {
+		return err
+	}
+	return printer.PrintObj(table, out)
+}
+
+// nodeUsageRow is the usage of a node along with its allocatable resources.
+type nodeUsageRow struct {
+	name        string
+	usage       corev1.ResourceList
+	allocatable corev1.ResourceList
+}
+
+// nodeUsageRows returns the usage of the named node, or of the nodes matching selector,
+// sorted by name or by --sort-by. Nodes without metrics are skipped.
+func nodeUsageRows(name string, selector labels.Selector) ([]nodeUsageRow, error) {
+	data, err := getMetrics([]string{"nodes"}, name, selector)
+	if err != nil {
+		return nil, err
+	}
+	var metrics []nodeMetricsItem
+	if name != "" {
+		var item nodeMetricsItem
+		if err := json.Unmarshal(data, &item); err != nil {
+			return nil, fmt.Errorf("failed to decode node metrics: %v", err)
+		}
+		metrics = []nodeMetricsItem{item}
+	} else {
+		var list nodeMetricsList
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, fmt.Errorf("failed to decode node metrics: %v", err)
+		}
+		metrics = list.Items
+	}
+
+	allocatable := map[string]corev1.ResourceList{}
+	if name != "" {
+		node, err := kubeClient.CoreV1().Nodes().Get(name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		allocatable[node.Name] = node.Status.Allocatable
+	} else {
+		nodes, err := kubeClient.CoreV1().Nodes().List(metav1.ListOptions{LabelSelector: selector.String()})
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range nodes.Items {
+			allocatable[node.Name] = node.Status.Allocatable
+		}
+	}
+
+	var rows []nodeUsageRow
+	for _, m := range metrics {
+		rows = append(rows, nodeUsageRow{name: m.Name, usage: m.Usage, allocatable: allocatable[m.Name]})
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if c := compareUsage(rows[i].usage, rows[j].usage, topNodesSortBy); c != 0 {
+			return c > 0
+		}
+		return rows[i].name < rows[j].name
+	})
+	return rows, nil
+}
+
+func printNodeMetrics(out io.Writer, rows []nodeUsageRow) error {
+	table := &output.Table{Columns: []string{"NAME", "CPU(cores)", "CPU%", "MEMORY(bytes)", "MEMORY%"}}
+	for _, r := range rows {
+		table.Rows = append(table.Rows, []string{
+			r.name,
+			formatCPU(*r.usage.Cpu()),
+			formatPercent(usagePercent(*r.usage.Cpu(), *r.allocatable.Cpu())),
+			formatMemory(*r.usage.Memory()),
+			formatPercent(usagePercent(*r.usage.Memory(), *r.allocatable.Memory())),
+		})
+	}
+
+	printer, err := output.PrinterFor(withTableStyle(output.Flags{}))
+	if err != nil {
+		return err
+	}
+	return printer.PrintObj(table, out)
+}
+
+// usagePercent returns usage as a percentage of allocatable, or nil if nothing is
+// allocatable, e.g. as the node has gone.
+func usagePercent(usage, allocatable resource.Quantity) *float64 {
+	if allocatable.IsZero() {
+		return nil
+	}
+	percent := 100 * float64(usage.MilliValue()) / float64(allocatable.MilliValue())
+	return &percent
+}
+
+func formatPercent(percent *float64) string {
+	if percent == nil {
+		return "<unknown>"
+	}
+	return fmt.Sprintf("%.0f%%", *percent)
+}
+
+// usageRecord is the usage of a pod, container or node in JSON output, with CPU and memory
+// both as numbers for programs and as the quantities shown in tables.
+type usageRecord struct {
+	Namespace     string   `json:"namespace,omitempty"`
+	Name          string   `json:"name"`
+	Container     string   `json:"container,omitempty"`
+	CPU           string   `json:"cpu"`
+	CPUMillicores int64    `json:"cpuMillicores"`
+	Memory        string   `json:"memory"`
+	MemoryBytes   int64    `json:"memoryBytes"`
+	CPUPercent    *float64 `json:"cpuPercent,omitempty"`
+	MemoryPercent *float64 `json:"memoryPercent,omitempty"`
+}
+
+func newUsageRecord(namespace, name, container string, usage corev1.ResourceList) usageRecord {
+	return usageRecord{
+		Namespace:     namespace,
+		Name:          name,
+		Container:     container,
+		CPU:           formatCPU(*usage.Cpu()),
+		CPUMillicores: usage.Cpu().MilliValue(),
+		Memory:        formatMemory(*usage.Memory()),
+		MemoryBytes:   usage.Memory().Value(),
+	}
+}
+
+// printUsageJSON prints records as a JSON document with an items list.
+func printUsageJSON(out io.Writer, records []usageRecord) error {
+	doc := struct {
+		Items []usageRecord `json:"items"`
+	}{Items: records}
+	data, err := json.MarshalIndent(doc, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, string(data))
+	return err
+}
+
+func formatCPU(q resource.Quantity) string {
+	return fmt.Sprintf("%dm", q.MilliValue())
+}
+
+func formatMemory(q resource.Quantity) string {
+	return fmt.Sprintf("%dMi", q.Value()/(1024*1024))
+}
+
+func init() {
+	rootCmd.AddCommand(topCmd)
+	topCmd.AddCommand(topPodsCmd)
+	topCmd.AddCommand(topNodesCmd)
+
+	topPodsCmd.Flags().StringVarP(&topPodsSelector, "selector", "l", "", "label selector to filter on, supports '=', '==', and '!=' (e.g. -l key1=value1,key2=value2)")
+	topPodsCmd.Flags().BoolVar(&topPodsAllNamespaces, "all-namespaces", false, "show metrics for pods across all namespaces")
+	topPodsCmd.Flags().BoolVar(&topPodsContainers, "containers", false, "show the usage of each container, rather than the total of each pod")
+	topPodsCmd.Flags().StringVar(&topPodsSortBy, "sort-by", "", "sort by usage, highest first, one of: cpu|memory, rather than by name")
+	topPodsCmd.Flags().StringVarP(&topPodsOutput, "output", "o", "", "output format, one of: json, the default prints a table")
+
+	topNodesCmd.Flags().StringVarP(&topNodesSelector, "selector", "l", "", "label selector to filter on, supports '=', '==', and '!=' (e.g. -l key1=value1,key2=value2)")
+	topNodesCmd.Flags().StringVar(&topNodesSortBy, "sort-by", "", "sort by usage, highest first, one of: cpu|memory, rather than by name")
+	topNodesCmd.Flags().StringVarP(&topNodesOutput, "output", "o", "", "output format, one of: json, the default prints a table")
+}
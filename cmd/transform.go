@@ -0,0 +1,26 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import "github.com/jimmidyson/kube-client-template/pkg/output"
+
+// outputTransforms returns the output transforms enabled by flags, in the order they apply.
+func outputTransforms() []output.Transform {
+	var transforms []output.Transform
+	if redactSecrets {
+		transforms = append(transforms, output.RedactSecretData)
+	}
+	return transforms
+}
@@ -0,0 +1,353 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jimmidyson/kube-client-template/pkg/output"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// waitProgressInterval is how often wait logs how many objects satisfy the condition.
+const waitProgressInterval = 10 * time.Second
+
+var (
+	waitFor      string
+	waitSelector string
+	waitTimeout  time.Duration
+
+	waitIgnoreNotFound bool
+)
+
+// waitCmd represents the wait command
+var waitCmd = &cobra.Command{
+	Use:   "wait TYPE[/NAME] [NAME...] --for=jsonpath='{...}'=VALUE",
+	Short: "Wait for a field of objects to have a value",
+	Long: `Wait for a field of objects to have a value, by name or label selector.
+
+Any resource can be waited on, including custom resources, so that readiness
+reported in the status of operator-managed objects can be waited for. An
+object that doesn't have the field yet, e.g. as its status hasn't been
+written, is waited on until it does. Without =VALUE, the field only has to
+be set.
+
+The objects are watched together rather than polled, so many objects can be
+waited on at once. How many of them satisfy the condition is logged
+periodically, and on timeout the objects that still don't are listed.
+
+Waiting fails if a named object doesn't exist, or if no object matches the
+selector, as the condition can never be met. With --ignore-not-found, the
+missing objects are skipped and finding none isn't a failure. For example:
+
+  kube-client-template wait pod/nginx --for=jsonpath='{.status.phase}'=Running
+  kube-client-template wait databases.example.com -l app=shop --for=jsonpath='{.status.ready}'=true --timeout=10m`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cond, err := parseWaitCondition(waitFor)
+		if err != nil {
+			return err
+		}
+		resourceArg, names, err := splitResourceArgs(args)
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 && waitSelector == "" {
+			return errors.New("must specify the names of the objects to wait for, or a selector with -l")
+		}
+		if len(names) > 0 && waitSelector != "" {
+			return errors.New("names and a selector cannot both be specified")
+		}
+
+		mapping, err := resourceMapping(resourceArg)
+		if err != nil {
+			return err
+		}
+		client, err := resourceClient(mapping, namespace)
+		if err != nil {
+			return err
+		}
+		return waitForCondition(client, mapping, cond, names, waitTimeout)
+	},
+}
+
+// waitCondition is a JSONPath template whose result must equal a value.
+type waitCondition struct {
+	expr     string
+	path     *jsonpath.JSONPath
+	value    string
+	hasValue bool
+}
+
+// parseWaitCondition parses a --for expression of the form jsonpath={...}[=VALUE].
+func parseWaitCondition(expr string) (*waitCondition, error) {
+	const prefix = "jsonpath="
+	if !strings.HasPrefix(expr, prefix) {
+		return nil, fmt.Errorf("invalid --for %q: must be of the form jsonpath='{...}'=VALUE", expr)
+	}
+	// The template ends at its last closing brace, the value follows it.
+	template := expr[len(prefix):]
+	end := strings.LastIndex(template, "}")
+	if !strings.HasPrefix(template, "{") || end < 0 {
+		return nil, fmt.Errorf("invalid --for %q: must be of the form jsonpath='{...}'=VALUE", expr)
+	}
+	cond := &waitCondition{expr: expr}
+	if rest := template[end+1:]; rest != "" {
+		if !strings.HasPrefix(rest, "=") {
+			return nil, fmt.Errorf("invalid --for %q: must be of the form jsonpath='{...}'=VALUE", expr)
+		}
+		cond.value, cond.hasValue = rest[1:], true
+	}
+
+	// Missing fields aren't an error, the object is waited on until they are set.
+	cond.path = jsonpath.New("wait").AllowMissingKeys(true)
+	if err := cond.path.Parse(template[:end+1]); err != nil {
+		return nil, fmt.Errorf("invalid --for %q: %v", expr, err)
+	}
+	return cond, nil
+}
+
+// satisfied returns whether every value selected from obj equals the expected value. An
+// object without the field doesn't satisfy the condition.
+func (c *waitCondition) satisfied(obj *unstructured.Unstructured) (bool, error) {
+	results, err := c.path.FindResults(obj.Object)
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate --for %s: %v", c.expr, err)
+	}
+	var found bool
+	for _, result := range results {
+		for _, v := range result {
+			found = true
+			if c.hasValue && fmt.Sprint(v.Interface()) != c.value {
+				return false, nil
+			}
+		}
+	}
+	return found, nil
+}
+
+// waitForCondition waits for the named objects, or those matching --selector when no
+// names are given, to satisfy cond. The objects are listed and then watched with a single
+// watch, so that none of them are polled. Objects matching the selector that are created
+// while waiting aren't waited for. How many objects satisfy cond is logged every
+// waitProgressInterval. A timeout of zero waits forever.
+func waitForCondition(client dynamic.ResourceInterface, mapping *meta.RESTMapping, cond *waitCondition, names []string, timeout time.Duration) error {
+	var timeoutCh <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+
+	listOpts := waitListOptions(names)
+	list, err := listUnstructured(client, listOpts)
+	if err != nil {
+		return err
+	}
+	targets, err := waitTargets(list, mapping, names)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		if !waitIgnoreNotFound {
+			return errors.New("no matching resources found")
+		}
+		fmt.Fprintln(os.Stderr, "No resources found.")
+		return nil
+	}
+	// pending holds the qualified names of the objects that don't satisfy cond yet, by
+	// object name.
+	pending := map[string]string{}
+	for _, obj := range targets {
+		if pending[obj.GetName()], err = output.QualifiedName(obj); err != nil {
+			return err
+		}
+	}
+	check := func(obj *unstructured.Unstructured) error {
+		ok, err := cond.satisfied(obj)
+		if err != nil || !ok {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "%s condition met\n", pending[obj.GetName()])
+		delete(pending, obj.GetName())
+		return nil
+	}
+	progress := time.NewTicker(waitProgressInterval)
+	defer progress.Stop()
+
+	for {
+		listed := map[string]bool{}
+		for i := range list.Items {
+			obj := &list.Items[i]
+			listed[obj.GetName()] = true
+			if _, isPending := pending[obj.GetName()]; !isPending {
+				continue
+			}
+			if err := check(obj); err != nil {
+				return err
+			}
+		}
+		for name, qualified := range pending {
+			if !listed[name] {
+				return fmt.Errorf("%s was deleted while waiting for it", qualified)
+			}
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+
+		watchOpts := listOpts
+		watchOpts.ResourceVersion = list.GetResourceVersion()
+		w, err := client.Watch(watchOpts)
+		if err != nil {
+			return err
+		}
+		done, err := func() (bool, error) {
+			defer w.Stop()
+			for {
+				select {
+				case event, ok := <-w.ResultChan():
+					if !ok {
+						return false, nil
+					}
+					if event.Type == watch.Error {
+						err := watchError(event.Object)
+						if isExpiredWatch(err) {
+							// The objects changed too much since they were listed
+							// to watch from there, so they are listed again.
+							logger.Debug("watch expired", zap.Error(err))
+							return false, nil
+						}
+						return false, err
+					}
+					obj, ok := event.Object.(*unstructured.Unstructured)
+					if !ok {
+						return false, fmt.Errorf("unexpected object type %T", event.Object)
+					}
+					name, isPending := pending[obj.GetName()]
+					if !isPending {
+						continue
+					}
+					if event.Type == watch.Deleted {
+						return false, fmt.Errorf("%s was deleted while waiting for it", name)
+					}
+					if err := check(obj); err != nil {
+						return false, err
+					}
+					if len(pending) == 0 {
+						return true, nil
+					}
+				case <-progress.C:
+					logger.Info("waiting for condition", zap.Int("satisfied", len(targets)-len(pending)), zap.Int("pending", len(pending)), zap.Int("total", len(targets)))
+				case <-timeoutCh:
+					return false, waitTimeoutError(cond, pending)
+				}
+			}
+		}()
+		if done || err != nil {
+			return err
+		}
+		logger.Debug("watch closed, restarting", zap.Int("pending", len(pending)))
+		if list, err = listUnstructured(client, listOpts); err != nil {
+			return err
+		}
+	}
+}
+
+// isExpiredWatch returns whether err, from a watch error event, means that the resource
+// version watched from is too old, so the objects must be listed again to watch them.
+func isExpiredWatch(err error) bool {
+	if apierrors.IsGone(err) || apierrors.IsResourceExpired(err) {
+		return true
+	}
+	if status, ok := err.(apierrors.APIStatus); ok && status.Status().Code == http.StatusGone {
+		return true
+	}
+	return strings.Contains(err.Error(), "too old resource version")
+}
+
+// waitListOptions returns the options to list and watch the objects to wait for with.
+// A single named object is selected by name, so that only its changes are watched.
+func waitListOptions(names []string) metav1.ListOptions {
+	opts := metav1.ListOptions{LabelSelector: waitSelector}
+	if len(names) == 1 {
+		opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", names[0]).String()
+	}
+	return opts
+}
+
+// waitTargets returns the objects of list to wait for: those named, or all of them if no
+// names are given. It returns a NotFound error if any of names is missing from list,
+// unless --ignore-not-found is set.
+func waitTargets(list *unstructured.UnstructuredList, mapping *meta.RESTMapping, names []string) ([]*unstructured.Unstructured, error) {
+	var targets []*unstructured.Unstructured
+	if len(names) == 0 {
+		for i := range list.Items {
+			targets = append(targets, &list.Items[i])
+		}
+		return targets, nil
+	}
+	byName := map[string]*unstructured.Unstructured{}
+	for i := range list.Items {
+		byName[list.Items[i].GetName()] = &list.Items[i]
+	}
+	for _, name := range names {
+		obj, found := byName[name]
+		if !found {
+			if waitIgnoreNotFound {
+				logger.Debug("ignoring object that was not found", zap.String("name", name))
+				continue
+			}
+			return nil, apierrors.NewNotFound(schema.GroupResource{Group: mapping.GroupVersionKind.Group, Resource: mapping.Resource}, name)
+		}
+		targets = append(targets, obj)
+	}
+	return targets, nil
+}
+
+// waitTimeoutError lists the objects that still don't satisfy cond.
+func waitTimeoutError(cond *waitCondition, pending map[string]string) error {
+	var names []string
+	for _, name := range pending {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("timed out waiting for %s on %d objects: %s", cond.expr, len(names), strings.Join(names, ", "))
+}
+
+func init() {
+	rootCmd.AddCommand(waitCmd)
+
+	waitCmd.Flags().StringVar(&waitFor, "for", "", "the condition to wait for, in jsonpath='{...}'=VALUE form")
+	waitCmd.Flags().StringVarP(&waitSelector, "selector", "l", "", "label selector of the objects to wait for, supports '=', '==', and '!=' (e.g. -l key1=value1,key2=value2)")
+	waitCmd.Flags().BoolVar(&waitIgnoreNotFound, "ignore-not-found", false, "skip named objects that don't exist, and don't fail when no objects are found")
+	waitCmd.Flags().DurationVar(&waitTimeout, "timeout", 0, "how long to wait before failing, zero means wait forever")
+}
@@ -0,0 +1,127 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+// waitPod returns a pod named name in phase.
+func waitPod(name, phase string) unstructured.Unstructured {
+	return unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "default", "resourceVersion": "1"},
+		"status":     map[string]interface{}{"phase": phase},
+	}}
+}
+
+// waitClient returns lists in turn, and watches that fail with watchErr, recording the
+// options of each request.
+type waitClient struct {
+	dynamic.ResourceInterface
+	lists    [][]unstructured.Unstructured
+	watchErr *metav1.Status
+	requests []metav1.ListOptions
+}
+
+func (c *waitClient) List(opts metav1.ListOptions) (runtime.Object, error) {
+	c.requests = append(c.requests, opts)
+	items := c.lists[0]
+	if len(c.lists) > 1 {
+		c.lists = c.lists[1:]
+	}
+	return &unstructured.UnstructuredList{Object: map[string]interface{}{"apiVersion": "v1", "kind": "PodList"}, Items: items}, nil
+}
+
+func (c *waitClient) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	c.requests = append(c.requests, opts)
+	w := watch.NewFake()
+	go w.Error(c.watchErr)
+	return w, nil
+}
+
+var waitPodMapping = &meta.RESTMapping{Resource: "pods", GroupVersionKind: schema.GroupVersionKind{Version: "v1", Kind: "Pod"}}
+
+// TestWaitRelistsExpiredWatch checks that an expired watch is recovered from by listing
+// the objects again, and that a single named object is selected by name.
+func TestWaitRelistsExpiredWatch(t *testing.T) {
+	cond, err := parseWaitCondition("jsonpath={.status.phase}=Running")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &waitClient{
+		lists: [][]unstructured.Unstructured{{waitPod("web", "Pending")}, {waitPod("web", "Running")}},
+		watchErr: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    http.StatusGone,
+			Reason:  metav1.StatusReasonExpired,
+			Message: "too old resource version: 1 (42)",
+		},
+	}
+	stdout := captureOutput(t, &os.Stdout)
+	err = waitForCondition(client, waitPodMapping, cond, []string{"web"}, time.Minute)
+	out := stdout()
+	if err != nil {
+		t.Fatalf("waiting failed: %v", err)
+	}
+	if out != "pod/web condition met\n" {
+		t.Errorf("printed %q, want the condition met", out)
+	}
+	if len(client.requests) != 3 {
+		t.Fatalf("made %d requests, want a list, a watch and a list again", len(client.requests))
+	}
+	for _, opts := range client.requests {
+		if opts.FieldSelector != "metadata.name=web" {
+			t.Errorf("requested with field selector %q, want metadata.name=web", opts.FieldSelector)
+		}
+	}
+}
+
+// TestWaitFailsOnWatchError checks that watch errors other than expiry fail the wait.
+func TestWaitFailsOnWatchError(t *testing.T) {
+	cond, err := parseWaitCondition("jsonpath={.status.phase}=Running")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &waitClient{
+		lists: [][]unstructured.Unstructured{{waitPod("web", "Pending"), waitPod("api", "Pending")}},
+		watchErr: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    http.StatusInternalServerError,
+			Reason:  metav1.StatusReasonInternalError,
+			Message: "etcd is down",
+		},
+	}
+	err = waitForCondition(client, waitPodMapping, cond, []string{"web", "api"}, time.Minute)
+	if err == nil || !strings.Contains(err.Error(), "etcd is down") {
+		t.Errorf("error is %v, want the watch error", err)
+	}
+	if selector := client.requests[0].FieldSelector; selector != "" {
+		t.Errorf("listed several objects with field selector %q, want none", selector)
+	}
+}
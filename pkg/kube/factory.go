@@ -0,0 +1,375 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kube constructs clients for the clusters configured in kubeconfig files.
+package kube
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// Factory constructs clients for one kubeconfig context. Clients are constructed on
+// first use and cached, and all methods are safe for concurrent use. The returned
+// clients are shared by all callers of the factory and are themselves safe for
+// concurrent use.
+type Factory interface {
+	// RawConfig returns the merged kubeconfig, loading it on first use.
+	RawConfig() (clientcmdapi.Config, error)
+	// RESTConfig returns a copy of the REST config of the context.
+	RESTConfig() (*rest.Config, error)
+	// ClientSet returns the clientset of the context.
+	ClientSet() (kubernetes.Interface, error)
+	// DiscoveryClient returns a discovery client of the context that caches discovery
+	// results in memory. Invalidating it discards the results for all its callers.
+	DiscoveryClient() (discovery.CachedDiscoveryInterface, error)
+	// RESTMapper returns a REST mapper of the context backed by DiscoveryClient. It
+	// discovers resources on first use, and again when it fails to map a resource
+	// after the discovery client is invalidated.
+	RESTMapper() (meta.RESTMapper, error)
+	// ResourceClient returns a dynamic client for the resource described by mapping in
+	// namespace, which is ignored for cluster scoped resources.
+	ResourceClient(mapping *meta.RESTMapping, namespace string) (dynamic.ResourceInterface, error)
+	// Namespace returns the namespace of the context, or the overridden namespace.
+	Namespace() (string, error)
+	// WithContext returns a factory bound to the named context of the same kubeconfig.
+	// Factories, and so their clients, are cached per context.
+	WithContext(name string) (Factory, error)
+}
+
+// ConfigFunc modifies a REST config before clients are constructed from it, e.g. to
+// set a rate limiter.
+type ConfigFunc func(config *rest.Config)
+
+// NewFactory returns a factory for the current context of the kubeconfig loaded by
+// rules, with overrides applied. The configure functions are applied to the REST config
+// of every context, including those of factories returned by WithContext.
+func NewFactory(rules *clientcmd.ClientConfigLoadingRules, overrides *clientcmd.ConfigOverrides, configure ...ConfigFunc) Factory {
+	return &factory{
+		clientConfig: clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides),
+		shared: &sharedState{
+			rules:     rules,
+			overrides: overrides,
+			configure: configure,
+			contexts:  map[string]*factory{},
+		},
+	}
+}
+
+// sharedState is shared by a factory and the factories derived from it with WithContext.
+type sharedState struct {
+	rules     *clientcmd.ClientConfigLoadingRules
+	overrides *clientcmd.ConfigOverrides
+	configure []ConfigFunc
+
+	mu        sync.Mutex
+	rawConfig *clientcmdapi.Config
+	contexts  map[string]*factory
+}
+
+type factory struct {
+	clientConfig clientcmd.ClientConfig
+	shared       *sharedState
+
+	mu              sync.Mutex
+	restConfig      *rest.Config
+	clientSet       kubernetes.Interface
+	discoveryClient discovery.CachedDiscoveryInterface
+	restMapper      meta.RESTMapper
+	dynamicClients  dynamic.ClientPool
+}
+
+func (f *factory) RawConfig() (clientcmdapi.Config, error) {
+	return f.clientConfig.RawConfig()
+}
+
+func (f *factory) RESTConfig() (*rest.Config, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	config, err := f.restConfigLocked()
+	if err != nil {
+		return nil, err
+	}
+	copied := *config
+	return &copied, nil
+}
+
+func (f *factory) restConfigLocked() (*rest.Config, error) {
+	if f.restConfig != nil {
+		return f.restConfig, nil
+	}
+	config, err := f.clientConfig.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	for _, configure := range f.shared.configure {
+		configure(config)
+	}
+	f.restConfig = config
+	return config, nil
+}
+
+func (f *factory) ClientSet() (kubernetes.Interface, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.clientSetLocked()
+}
+
+func (f *factory) clientSetLocked() (kubernetes.Interface, error) {
+	if f.clientSet != nil {
+		return f.clientSet, nil
+	}
+	config, err := f.restConfigLocked()
+	if err != nil {
+		return nil, err
+	}
+	clientSet, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	f.clientSet = clientSet
+	return clientSet, nil
+}
+
+func (f *factory) DiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.discoveryClientLocked()
+}
+
+func (f *factory) discoveryClientLocked() (discovery.CachedDiscoveryInterface, error) {
+	if f.discoveryClient != nil {
+		return f.discoveryClient, nil
+	}
+	clientSet, err := f.clientSetLocked()
+	if err != nil {
+		return nil, err
+	}
+	f.discoveryClient = &fillOnUseClient{CachedDiscoveryInterface: cached.NewMemCacheClient(clientSet.Discovery())}
+	return f.discoveryClient, nil
+}
+
+// fillOnUseClient fills a memory cached discovery client the first time it is read from.
+// The cached client returns ErrCacheEmpty until it is first invalidated, and the deferred
+// REST mapper doesn't invalidate it on that error, so resources could never be mapped.
+type fillOnUseClient struct {
+	discovery.CachedDiscoveryInterface
+
+	fillOnce sync.Once
+}
+
+func (c *fillOnUseClient) fill() {
+	c.fillOnce.Do(c.CachedDiscoveryInterface.Invalidate)
+}
+
+func (c *fillOnUseClient) ServerGroups() (*metav1.APIGroupList, error) {
+	c.fill()
+	return c.CachedDiscoveryInterface.ServerGroups()
+}
+
+func (c *fillOnUseClient) ServerResourcesForGroupVersion(groupVersion string) (*metav1.APIResourceList, error) {
+	c.fill()
+	return c.CachedDiscoveryInterface.ServerResourcesForGroupVersion(groupVersion)
+}
+
+// ServerResources returns the resources of the group versions that were discovered, and
+// reports the others as the uncached client does, rather than failing on the first.
+func (c *fillOnUseClient) ServerResources() ([]*metav1.APIResourceList, error) {
+	groups, err := c.ServerGroups()
+	if err != nil {
+		return nil, err
+	}
+	var lists []*metav1.APIResourceList
+	failed := map[schema.GroupVersion]error{}
+	for _, group := range groups.Groups {
+		for _, version := range group.Versions {
+			list, err := c.ServerResourcesForGroupVersion(version.GroupVersion)
+			if err != nil {
+				failed[schema.GroupVersion{Group: group.Name, Version: version.Version}] = err
+				continue
+			}
+			lists = append(lists, list)
+		}
+	}
+	if len(failed) > 0 {
+		return lists, &discovery.ErrGroupDiscoveryFailed{Groups: failed}
+	}
+	return lists, nil
+}
+
+// ServerPreferredResources is computed from the cached groups and resources, as the
+// cached client would ask the server each time.
+func (c *fillOnUseClient) ServerPreferredResources() ([]*metav1.APIResourceList, error) {
+	groups, err := c.ServerGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	// The preferred version of a resource is the preferred version of its group if it
+	// is served there, and otherwise the first version of the group serving it.
+	lists := map[string]*metav1.APIResourceList{}
+	versions := map[schema.GroupResource]string{}
+	failed := map[schema.GroupVersion]error{}
+	for _, group := range groups.Groups {
+		for _, version := range group.Versions {
+			list, err := c.ServerResourcesForGroupVersion(version.GroupVersion)
+			if err != nil {
+				failed[schema.GroupVersion{Group: group.Name, Version: version.Version}] = err
+				continue
+			}
+			lists[version.GroupVersion] = list
+			for _, resource := range list.APIResources {
+				if strings.Contains(resource.Name, "/") {
+					continue
+				}
+				gr := schema.GroupResource{Group: group.Name, Resource: resource.Name}
+				if _, ok := versions[gr]; !ok || version.Version == group.PreferredVersion.Version {
+					versions[gr] = version.Version
+				}
+			}
+		}
+	}
+
+	var preferred []*metav1.APIResourceList
+	for _, group := range groups.Groups {
+		for _, version := range group.Versions {
+			list, ok := lists[version.GroupVersion]
+			if !ok {
+				continue
+			}
+			filtered := &metav1.APIResourceList{GroupVersion: version.GroupVersion}
+			for _, resource := range list.APIResources {
+				if versions[schema.GroupResource{Group: group.Name, Resource: resource.Name}] == version.Version {
+					filtered.APIResources = append(filtered.APIResources, resource)
+				}
+			}
+			preferred = append(preferred, filtered)
+		}
+	}
+	if len(failed) > 0 {
+		return preferred, &discovery.ErrGroupDiscoveryFailed{Groups: failed}
+	}
+	return preferred, nil
+}
+
+func (c *fillOnUseClient) ServerPreferredNamespacedResources() ([]*metav1.APIResourceList, error) {
+	all, err := c.ServerPreferredResources()
+	return discovery.FilteredBy(discovery.ResourcePredicateFunc(func(groupVersion string, r *metav1.APIResource) bool {
+		return r.Namespaced
+	}), all), err
+}
+
+func (c *fillOnUseClient) Invalidate() {
+	c.fillOnce.Do(func() {})
+	c.CachedDiscoveryInterface.Invalidate()
+}
+
+func (f *factory) RESTMapper() (meta.RESTMapper, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.restMapperLocked()
+}
+
+func (f *factory) restMapperLocked() (meta.RESTMapper, error) {
+	if f.restMapper != nil {
+		return f.restMapper, nil
+	}
+	discoveryClient, err := f.discoveryClientLocked()
+	if err != nil {
+		return nil, err
+	}
+	f.restMapper = discovery.NewDeferredDiscoveryRESTMapper(discoveryClient, dynamic.VersionInterfaces)
+	return f.restMapper, nil
+}
+
+func (f *factory) ResourceClient(mapping *meta.RESTMapping, namespace string) (dynamic.ResourceInterface, error) {
+	pool, err := f.dynamicClientPool()
+	if err != nil {
+		return nil, err
+	}
+	client, err := pool.ClientForGroupVersionKind(mapping.GroupVersionKind)
+	if err != nil {
+		return nil, err
+	}
+	namespaced := mapping.Scope.Name() == meta.RESTScopeNameNamespace
+	if !namespaced {
+		namespace = ""
+	}
+	return client.Resource(&metav1.APIResource{Name: mapping.Resource, Namespaced: namespaced}, namespace), nil
+}
+
+func (f *factory) dynamicClientPool() (dynamic.ClientPool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.dynamicClients != nil {
+		return f.dynamicClients, nil
+	}
+	config, err := f.restConfigLocked()
+	if err != nil {
+		return nil, err
+	}
+	restMapper, err := f.restMapperLocked()
+	if err != nil {
+		return nil, err
+	}
+	f.dynamicClients = dynamic.NewClientPool(config, restMapper, dynamic.LegacyAPIPathResolverFunc)
+	return f.dynamicClients, nil
+}
+
+func (f *factory) Namespace() (string, error) {
+	namespace, _, err := f.clientConfig.Namespace()
+	return namespace, err
+}
+
+func (f *factory) WithContext(name string) (Factory, error) {
+	s := f.shared
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cached, ok := s.contexts[name]; ok {
+		return cached, nil
+	}
+
+	// The kubeconfig is only loaded once, however many contexts are used.
+	if s.rawConfig == nil {
+		rawConfig, err := f.clientConfig.RawConfig()
+		if err != nil {
+			return nil, err
+		}
+		s.rawConfig = &rawConfig
+	}
+	if _, ok := s.rawConfig.Contexts[name]; !ok {
+		return nil, fmt.Errorf("context %q not found in kubeconfig", name)
+	}
+
+	overrides := *s.overrides
+	overrides.CurrentContext = name
+	derived := &factory{
+		clientConfig: clientcmd.NewNonInteractiveClientConfig(*s.rawConfig, name, &overrides, s.rules),
+		shared:       s,
+	}
+	s.contexts[name] = derived
+	return derived, nil
+}
@@ -0,0 +1,168 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+)
+
+// fakeCachedDiscovery serves discovery from memory, counting the requests that would go
+// to the server.
+type fakeCachedDiscovery struct {
+	discovery.CachedDiscoveryInterface
+
+	groups    *metav1.APIGroupList
+	resources map[string]*metav1.APIResourceList
+	requests  int
+}
+
+func (d *fakeCachedDiscovery) ServerGroups() (*metav1.APIGroupList, error) {
+	return d.groups, nil
+}
+
+func (d *fakeCachedDiscovery) ServerResourcesForGroupVersion(groupVersion string) (*metav1.APIResourceList, error) {
+	list, ok := d.resources[groupVersion]
+	if !ok {
+		return nil, fmt.Errorf("%s not found", groupVersion)
+	}
+	return list, nil
+}
+
+func (d *fakeCachedDiscovery) ServerPreferredResources() ([]*metav1.APIResourceList, error) {
+	d.requests++
+	return nil, nil
+}
+
+func (d *fakeCachedDiscovery) Invalidate() {
+	d.requests++
+}
+
+func version(groupVersion, v string) metav1.GroupVersionForDiscovery {
+	return metav1.GroupVersionForDiscovery{GroupVersion: groupVersion, Version: v}
+}
+
+func resourceNames(lists []*metav1.APIResourceList) map[string][]string {
+	names := map[string][]string{}
+	for _, list := range lists {
+		for _, r := range list.APIResources {
+			names[list.GroupVersion] = append(names[list.GroupVersion], r.Name)
+		}
+	}
+	return names
+}
+
+// TestServerPreferredResourcesCached checks that preferred resources are computed from the
+// cached discovery, in the preferred version of their group where it is served.
+func TestServerPreferredResourcesCached(t *testing.T) {
+	fake := &fakeCachedDiscovery{
+		groups: &metav1.APIGroupList{Groups: []metav1.APIGroup{
+			{Name: "", Versions: []metav1.GroupVersionForDiscovery{version("v1", "v1")}, PreferredVersion: version("v1", "v1")},
+			{
+				Name:             "apps",
+				Versions:         []metav1.GroupVersionForDiscovery{version("apps/v1beta1", "v1beta1"), version("apps/v1", "v1")},
+				PreferredVersion: version("apps/v1", "v1"),
+			},
+		}},
+		resources: map[string]*metav1.APIResourceList{
+			"v1": {GroupVersion: "v1", APIResources: []metav1.APIResource{
+				{Name: "pods", Namespaced: true}, {Name: "pods/log", Namespaced: true}, {Name: "nodes"},
+			}},
+			"apps/v1beta1": {GroupVersion: "apps/v1beta1", APIResources: []metav1.APIResource{
+				{Name: "deployments", Namespaced: true}, {Name: "controllerrevisions", Namespaced: true},
+			}},
+			"apps/v1": {GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+				{Name: "deployments", Namespaced: true},
+			}},
+		},
+	}
+	client := &fillOnUseClient{CachedDiscoveryInterface: fake}
+
+	preferred, err := client.ServerPreferredResources()
+	if err != nil {
+		t.Fatalf("ServerPreferredResources failed: %v", err)
+	}
+	want := map[string][]string{
+		"v1":           {"pods", "nodes"},
+		"apps/v1beta1": {"controllerrevisions"},
+		"apps/v1":      {"deployments"},
+	}
+	if got := resourceNames(preferred); !reflect.DeepEqual(got, want) {
+		t.Errorf("preferred resources are %v, want %v", got, want)
+	}
+
+	namespaced, err := client.ServerPreferredNamespacedResources()
+	if err != nil {
+		t.Fatalf("ServerPreferredNamespacedResources failed: %v", err)
+	}
+	want["v1"] = []string{"pods"}
+	if got := resourceNames(namespaced); !reflect.DeepEqual(got, want) {
+		t.Errorf("preferred namespaced resources are %v, want %v", got, want)
+	}
+
+	// Only the first use fills the cache.
+	if fake.requests != 1 {
+		t.Errorf("made %d requests to the server, want 1 to fill the cache", fake.requests)
+	}
+}
+
+// TestServerPreferredResourcesPartial checks that groups that fail discovery are reported
+// along with the resources of the others.
+func TestServerPreferredResourcesPartial(t *testing.T) {
+	fake := &fakeCachedDiscovery{
+		groups: &metav1.APIGroupList{Groups: []metav1.APIGroup{
+			{Name: "", Versions: []metav1.GroupVersionForDiscovery{version("v1", "v1")}, PreferredVersion: version("v1", "v1")},
+			{Name: "metrics.k8s.io", Versions: []metav1.GroupVersionForDiscovery{version("metrics.k8s.io/v1beta1", "v1beta1")}},
+		}},
+		resources: map[string]*metav1.APIResourceList{
+			"v1": {GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "pods", Namespaced: true}}},
+		},
+	}
+	preferred, err := (&fillOnUseClient{CachedDiscoveryInterface: fake}).ServerPreferredResources()
+	if !discovery.IsGroupDiscoveryFailedError(err) {
+		t.Errorf("error is %v, want the failed group reported", err)
+	}
+	if got, want := resourceNames(preferred), map[string][]string{"v1": {"pods"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("preferred resources are %v, want %v", got, want)
+	}
+}
+
+// TestServerResourcesPartial checks that the resources of all discovered group versions
+// are returned when others fail discovery.
+func TestServerResourcesPartial(t *testing.T) {
+	fake := &fakeCachedDiscovery{
+		groups: &metav1.APIGroupList{Groups: []metav1.APIGroup{
+			{Name: "metrics.k8s.io", Versions: []metav1.GroupVersionForDiscovery{version("metrics.k8s.io/v1beta1", "v1beta1")}},
+			{Name: "", Versions: []metav1.GroupVersionForDiscovery{version("v1", "v1")}, PreferredVersion: version("v1", "v1")},
+		}},
+		resources: map[string]*metav1.APIResourceList{
+			"v1": {GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "pods", Namespaced: true}}},
+		},
+	}
+	lists, err := (&fillOnUseClient{CachedDiscoveryInterface: fake}).ServerResources()
+	if !discovery.IsGroupDiscoveryFailedError(err) {
+		t.Errorf("error is %v, want the failed group reported", err)
+	}
+	if got, want := resourceNames(lists), map[string][]string{"v1": {"pods"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("resources are %v, want %v", got, want)
+	}
+	if fake.requests != 1 {
+		t.Errorf("made %d requests to the server, want 1 to fill the cache", fake.requests)
+	}
+}
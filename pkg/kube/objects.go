@@ -0,0 +1,99 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+)
+
+// Result is the outcome of reading objects with List or Get.
+type Result struct {
+	// List holds the objects that were read. For List it is the list returned by the
+	// API server, whose resource version a watch can be started from. For Get it is a
+	// v1 List of the objects that were got, in the order they were named.
+	List *unstructured.UnstructuredList
+	// Failures are the named objects that couldn't be got, in the order they were named.
+	Failures []Failure
+
+	named bool
+}
+
+// Failure is the failure to get a named object.
+type Failure struct {
+	Name string
+	Err  error
+}
+
+// Object returns the objects of r as they would be printed: a single named object on its
+// own, and otherwise List.
+func (r *Result) Object() runtime.Object {
+	if r.named && len(r.List.Items) == 1 {
+		return &r.List.Items[0]
+	}
+	return r.List
+}
+
+// List lists the objects of client matching opts.
+func List(client dynamic.ResourceInterface, opts metav1.ListOptions) (*Result, error) {
+	obj, err := client.List(opts)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := obj.(*unstructured.UnstructuredList)
+	if !ok {
+		return nil, fmt.Errorf("unexpected list type %T", obj)
+	}
+	return &Result{List: list}, nil
+}
+
+// Get gets the named objects of client. Failing to get an object doesn't stop the others
+// from being got, the failures are recorded in the result instead.
+func Get(client dynamic.ResourceInterface, names []string) *Result {
+	return GetWith(func(name string) (*unstructured.Unstructured, error) {
+		return client.Get(name, metav1.GetOptions{})
+	}, names)
+}
+
+// GetWith gets the named objects with get, e.g. to read them from a subresource, as Get
+// does.
+func GetWith(get func(name string) (*unstructured.Unstructured, error), names []string) *Result {
+	result := &Result{List: NewList(nil), named: true}
+	for _, name := range names {
+		obj, err := get(name)
+		if err != nil {
+			result.Failures = append(result.Failures, Failure{Name: name, Err: err})
+			continue
+		}
+		result.List.Items = append(result.List.Items, *obj)
+	}
+	return result
+}
+
+// NewList returns a v1 List containing items.
+func NewList(items []unstructured.Unstructured) *unstructured.UnstructuredList {
+	return &unstructured.UnstructuredList{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "List",
+			"metadata":   map[string]interface{}{},
+		},
+		Items: items,
+	}
+}
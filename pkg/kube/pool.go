@@ -0,0 +1,82 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import "sync"
+
+// Pool runs tasks concurrently with a bounded number of them running at once, so that
+// fanning out over many objects or namespaces doesn't flood the API server. A pool is
+// meant to be shared: concurrent calls of Run, and nested calls of RunNested, all share
+// its slots.
+type Pool struct {
+	slots chan struct{}
+}
+
+// NewPool returns a pool running at most size tasks at once. A size below 1 runs them
+// one at a time.
+func NewPool(size int) *Pool {
+	if size < 1 {
+		size = 1
+	}
+	return &Pool{slots: make(chan struct{}, size)}
+}
+
+// Size returns the maximum number of tasks the pool runs at once.
+func (p *Pool) Size() int {
+	return cap(p.slots)
+}
+
+// Run calls task with each index from 0 to n-1 and waits for them all to return. The
+// error returned by each call is returned at its index. Each task waits for a free slot.
+func (p *Pool) Run(n int, task func(i int) error) []error {
+	return p.run(n, task, false)
+}
+
+// RunNested is Run for tasks of the pool that fan out again. Their tasks run in the
+// pool's slots while any are free, and otherwise on the calling task's own slot, so that
+// they make progress instead of waiting for slots held by the tasks waiting for them.
+func (p *Pool) RunNested(n int, task func(i int) error) []error {
+	return p.run(n, task, true)
+}
+
+func (p *Pool) run(n int, task func(i int) error, holdsSlot bool) []error {
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	start := func(i int) {
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-p.slots
+				wg.Done()
+			}()
+			errs[i] = task(i)
+		}()
+	}
+	for i := 0; i < n; i++ {
+		if !holdsSlot {
+			p.slots <- struct{}{}
+			start(i)
+			continue
+		}
+		select {
+		case p.slots <- struct{}{}:
+			start(i)
+		default:
+			errs[i] = task(i)
+		}
+	}
+	wg.Wait()
+	return errs
+}
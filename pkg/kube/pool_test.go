@@ -0,0 +1,113 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// concurrency tracks how many tasks run at once.
+type concurrency struct {
+	mu           sync.Mutex
+	running, max int
+}
+
+func (c *concurrency) run(task func()) {
+	c.mu.Lock()
+	c.running++
+	if c.running > c.max {
+		c.max = c.running
+	}
+	c.mu.Unlock()
+	task()
+	c.mu.Lock()
+	c.running--
+	c.mu.Unlock()
+}
+
+func TestPoolReturnsErrorsByIndex(t *testing.T) {
+	failed := errors.New("failed")
+	errs := NewPool(3).Run(10, func(i int) error {
+		if i%3 == 0 {
+			return failed
+		}
+		return nil
+	})
+	for i, err := range errs {
+		if want := i%3 == 0; (err == failed) != want {
+			t.Errorf("error %d = %v, want failed %t", i, err, want)
+		}
+	}
+}
+
+func TestPoolSharesSlotsBetweenRuns(t *testing.T) {
+	for _, size := range []int{1, 4} {
+		pool := NewPool(size)
+		var c concurrency
+		var wg sync.WaitGroup
+		for run := 0; run < 3; run++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				pool.Run(20, func(int) error {
+					c.run(func() { time.Sleep(time.Millisecond) })
+					return nil
+				})
+			}()
+		}
+		wg.Wait()
+		if c.max > pool.Size() {
+			t.Errorf("%d tasks ran at once in a pool of %d", c.max, pool.Size())
+		}
+	}
+}
+
+func TestPoolRunsNestedRuns(t *testing.T) {
+	pool := NewPool(2)
+	var c concurrency
+	done := make(chan []error)
+	go func() {
+		done <- pool.Run(4, func(int) error {
+			c.run(func() { time.Sleep(10 * time.Millisecond) })
+			// Every slot is held by an outer task when the nested runs start, and the
+			// outer tasks make no requests of their own while they wait.
+			for _, err := range pool.RunNested(4, func(int) error {
+				c.run(func() { time.Sleep(time.Millisecond) })
+				return nil
+			}) {
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}()
+	select {
+	case errs := <-done:
+		for i, err := range errs {
+			if err != nil {
+				t.Errorf("task %d failed: %v", i, err)
+			}
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("nested runs didn't finish, they are waiting for slots held by their callers")
+	}
+	if c.max > pool.Size() {
+		t.Errorf("%d tasks ran at once in a pool of %d", c.max, pool.Size())
+	}
+}
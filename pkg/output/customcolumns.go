@@ -0,0 +1,219 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// noneValue is printed in custom columns whose fields are missing.
+const noneValue = "<none>"
+
+// columnFunc is a function that custom columns can apply to fields. It is passed the
+// value of each of its fields, or nil if the field is missing.
+type columnFunc struct {
+	args  int
+	apply func(values []interface{}) string
+}
+
+// columnFuncs are the functions available to custom columns, e.g.
+// "AGE:age(.metadata.creationTimestamp)". They are documented in the help of get.
+var columnFuncs = map[string]columnFunc{
+	// age(TIMESTAMP) prints the time since an RFC 3339 timestamp as in AGE columns.
+	"age": {args: 1, apply: columnAge},
+	// ready(READY,TOTAL) prints two counts as in READY columns, with missing counts as 0.
+	"ready": {args: 2, apply: columnReady},
+}
+
+// customColumn is a column of a custom-columns table.
+type customColumn struct {
+	header string
+	// fn is the name of the function applied to the values of paths, if any.
+	fn    string
+	paths []*jsonpath.JSONPath
+}
+
+// CustomColumnsTable returns a TableFunc building a table from spec, a comma separated
+// list of HEADER:FIELD columns. FIELD is either a JSONPath expression, e.g.
+// ".metadata.name", or a function of columnFuncs applied to JSONPath expressions, e.g.
+// "age(.metadata.creationTimestamp)".
+func CustomColumnsTable(spec string) (TableFunc, error) {
+	if spec == "" {
+		return nil, errors.New("custom-columns format specified but no custom columns given")
+	}
+	var columns []customColumn
+	for _, part := range splitTopLevel(spec) {
+		i := strings.Index(part, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("unexpected custom-columns spec %q, expected HEADER:FIELD", part)
+		}
+		column, err := parseCustomColumn(part[:i], part[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+
+	return func(obj runtime.Object, wide bool) (*Table, error) {
+		table := &Table{}
+		for _, column := range columns {
+			table.Columns = append(table.Columns, column.header)
+		}
+		err := eachObject(obj, func(obj runtime.Object) error {
+			data, err := toGeneric(obj)
+			if err != nil {
+				return err
+			}
+			var row []string
+			for _, column := range columns {
+				cell, err := column.value(data)
+				if err != nil {
+					return err
+				}
+				row = append(row, cell)
+			}
+			table.Rows = append(table.Rows, row)
+			return nil
+		})
+		return table, err
+	}, nil
+}
+
+func parseCustomColumn(header, field string) (customColumn, error) {
+	column := customColumn{header: header}
+	args := []string{field}
+	if i := strings.Index(field, "("); i > 0 && strings.HasSuffix(field, ")") {
+		column.fn = field[:i]
+		fn, found := columnFuncs[column.fn]
+		if !found {
+			return column, fmt.Errorf("unknown custom-columns function %q in column %s", column.fn, header)
+		}
+		args = strings.Split(field[i+1:len(field)-1], ",")
+		if len(args) != fn.args {
+			return column, fmt.Errorf("custom-columns function %s takes %d fields, got %d in column %s", column.fn, fn.args, len(args), header)
+		}
+	}
+	for _, arg := range args {
+		// Fields may be given with or without the braces of a JSONPath template.
+		arg = strings.TrimSpace(arg)
+		if !strings.HasPrefix(arg, "{") {
+			arg = "{" + arg + "}"
+		}
+		j := jsonpath.New(header).AllowMissingKeys(true)
+		if err := j.Parse(arg); err != nil {
+			return column, fmt.Errorf("error parsing custom-columns field %s of column %s: %v", arg, header, err)
+		}
+		column.paths = append(column.paths, j)
+	}
+	return column, nil
+}
+
+// value returns the cell of the column for data, the generic representation of an object.
+func (c customColumn) value(data interface{}) (string, error) {
+	var values []interface{}
+	for _, path := range c.paths {
+		results, err := path.FindResults(data)
+		if err != nil {
+			return "", fmt.Errorf("error executing custom-columns field of column %s: %v", c.header, err)
+		}
+		var found []interface{}
+		for _, result := range results {
+			for _, v := range result {
+				found = append(found, v.Interface())
+			}
+		}
+		switch len(found) {
+		case 0:
+			values = append(values, nil)
+		case 1:
+			values = append(values, found[0])
+		default:
+			values = append(values, found)
+		}
+	}
+
+	if c.fn != "" {
+		return columnFuncs[c.fn].apply(values), nil
+	}
+	return formatColumnValue(values[0]), nil
+}
+
+func formatColumnValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return noneValue
+	case []interface{}:
+		var s []string
+		for _, item := range v {
+			s = append(s, fmt.Sprint(item))
+		}
+		return strings.Join(s, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func columnAge(values []interface{}) string {
+	s, ok := values[0].(string)
+	if !ok {
+		return noneValue
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return "<invalid>"
+	}
+	return TranslateTimestamp(metav1.NewTime(t))
+}
+
+func columnReady(values []interface{}) string {
+	count := func(v interface{}) string {
+		if v == nil {
+			return "0"
+		}
+		return fmt.Sprint(v)
+	}
+	return count(values[0]) + "/" + count(values[1])
+}
+
+// splitTopLevel splits s on the commas that aren't within parentheses, so that functions
+// taking several fields can be used in custom columns.
+func splitTopLevel(s string) []string {
+	var (
+		parts []string
+		depth int
+		start int
+	)
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
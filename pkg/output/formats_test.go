@@ -0,0 +1,191 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// seededObjects are the objects every output format is checked against: namespaced
+// and cluster scoped, in the core and a named API group, with nested and empty fields.
+func seededObjects() []*unstructured.Unstructured {
+	return []*unstructured.Unstructured{
+		testDeployment("default", "web", 3),
+		{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Pod",
+			"metadata": map[string]interface{}{
+				"name":        "web-5d4f8",
+				"namespace":   "default",
+				"annotations": map[string]interface{}{"example.com/note": "a: b, c"},
+			},
+			"spec": map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{"name": "app", "image": "nginx:1.13", "args": []interface{}{"-g", "daemon off;"}},
+				},
+			},
+			"status": map[string]interface{}{"phase": "Running"},
+		}},
+		{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Namespace",
+			"metadata":   map[string]interface{}{"name": "shop"},
+			"spec":       map[string]interface{}{"finalizers": []interface{}{"kubernetes"}},
+		}},
+		{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "empty", "namespace": "shop"},
+			"data":       map[string]interface{}{},
+		}},
+	}
+}
+
+// render prints obj with the printer for output, failing t if it can't.
+func render(t *testing.T, output string, obj runtime.Object) string {
+	printer, err := PrinterFor(Flags{Output: output})
+	if err != nil {
+		t.Fatalf("PrinterFor(%q) failed: %v", output, err)
+	}
+	var out bytes.Buffer
+	if err := printer.PrintObj(obj, &out); err != nil {
+		t.Fatalf("printing %s failed: %v", output, err)
+	}
+	return out.String()
+}
+
+// TestJSONAndYAMLRoundTrip checks that JSON and YAML output decode back to the printed
+// objects, so that they can be applied again.
+func TestJSONAndYAMLRoundTrip(t *testing.T) {
+	for _, output := range []string{"json", "yaml"} {
+		for _, obj := range seededObjects() {
+			data := []byte(render(t, output, obj))
+			if output == "yaml" {
+				var err error
+				if data, err = yaml.YAMLToJSON(data); err != nil {
+					t.Fatalf("%s of %s isn't valid YAML: %v", output, obj.GetName(), err)
+				}
+			}
+			decoded, err := runtime.Decode(unstructured.UnstructuredJSONScheme, data)
+			if err != nil {
+				t.Fatalf("%s of %s can't be decoded: %v", output, obj.GetName(), err)
+			}
+			if got := decoded.(*unstructured.Unstructured).Object; !reflect.DeepEqual(got, obj.Object) {
+				t.Errorf("%s of %s decoded to %v, want %v", output, obj.GetName(), got, obj.Object)
+			}
+		}
+	}
+}
+
+// TestListRoundTrip checks that a list printed as JSON or YAML decodes back to its items.
+func TestListRoundTrip(t *testing.T) {
+	objs := seededObjects()
+	list := testList(objs...)
+	for _, output := range []string{"json", "yaml"} {
+		data, err := yaml.YAMLToJSON([]byte(render(t, output, list)))
+		if err != nil {
+			t.Fatalf("%s of the list isn't valid: %v", output, err)
+		}
+		decoded, err := runtime.Decode(unstructured.UnstructuredJSONScheme, data)
+		if err != nil {
+			t.Fatalf("%s of the list can't be decoded: %v", output, err)
+		}
+		items := decoded.(*unstructured.UnstructuredList).Items
+		if len(items) != len(objs) {
+			t.Fatalf("%s of the list decoded to %d items, want %d", output, len(items), len(objs))
+		}
+		for i, obj := range objs {
+			if !reflect.DeepEqual(items[i].Object, obj.Object) {
+				t.Errorf("%s of the list decoded item %d to %v, want %v", output, i, items[i].Object, obj.Object)
+			}
+		}
+	}
+}
+
+// TestNameRoundTrip checks that name output identifies each object by its kind, API
+// group and name.
+func TestNameRoundTrip(t *testing.T) {
+	for _, obj := range seededObjects() {
+		name := strings.TrimSuffix(render(t, "name", obj), "\n")
+		slash := strings.Index(name, "/")
+		if slash < 0 {
+			t.Fatalf("name of %s is %q, want KIND/NAME", obj.GetName(), name)
+		}
+		kind, group := name[:slash], ""
+		if dot := strings.Index(kind, "."); dot >= 0 {
+			kind, group = kind[:dot], kind[dot+1:]
+		}
+		gvk := obj.GroupVersionKind()
+		if kind != strings.ToLower(gvk.Kind) || group != gvk.Group || name[slash+1:] != obj.GetName() {
+			t.Errorf("name of %s is %q, want kind %s, group %q and name %s", obj.GetName(), name, gvk.Kind, gvk.Group, obj.GetName())
+		}
+	}
+}
+
+// TestFormatsAgree checks that the formats that print the same field agree on it, and
+// that printing a list prints the same as printing each of its items.
+func TestFormatsAgree(t *testing.T) {
+	objs := seededObjects()
+	var names, rows, nameLines []string
+	for _, obj := range objs {
+		name := obj.GetName()
+		names = append(names, name)
+		if got := render(t, "jsonpath={.metadata.name}", obj); got != name {
+			t.Errorf("jsonpath printed %q for %s", got, name)
+		}
+		if got := render(t, "go-template={{.metadata.name}}", obj); got != name {
+			t.Errorf("go-template printed %q for %s", got, name)
+		}
+		if got := render(t, "custom-columns=NAME:.metadata.name", obj); got != "NAME\n"+name+"\n" {
+			t.Errorf("custom-columns printed %q for %s", got, name)
+		}
+		table := strings.Split(strings.TrimSuffix(render(t, "", obj), "\n"), "\n")
+		if len(table) != 2 || !strings.HasPrefix(table[1], name+" ") {
+			t.Errorf("table printed %q for %s, want a header and a row starting with its name", table, name)
+		}
+		if wide := strings.Split(strings.TrimSuffix(render(t, "wide", obj), "\n"), "\n"); !reflect.DeepEqual(wide, table) {
+			t.Errorf("wide table printed %q for %s, want %q as no wide columns are defined", wide, name, table)
+		}
+		rows = append(rows, strings.Fields(table[1])[0])
+		nameLines = append(nameLines, render(t, "name", obj))
+	}
+
+	list := testList(objs...)
+	if got := render(t, "name", list); got != strings.Join(nameLines, "") {
+		t.Errorf("name printed %q for the list, want the names of its items %q", got, strings.Join(nameLines, ""))
+	}
+	if !reflect.DeepEqual(rows, names) {
+		t.Errorf("table rows are for %v, want %v", rows, names)
+	}
+	if got := render(t, "jsonpath={.items[*].metadata.name}", list); got != strings.Join(names, " ") {
+		t.Errorf("jsonpath printed %q for the list, want %q", got, strings.Join(names, " "))
+	}
+	table := strings.Split(strings.TrimSuffix(render(t, "", list), "\n"), "\n")
+	if len(table) != len(objs)+1 {
+		t.Fatalf("table printed %d lines for %d objects, want a header and a row each", len(table), len(objs))
+	}
+	for i, row := range table[1:] {
+		if name := strings.Fields(row)[0]; name != names[i] {
+			t.Errorf("table row %d is for %s, want %s", i, name, names[i])
+		}
+	}
+}
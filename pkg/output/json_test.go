@@ -0,0 +1,83 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// orderedConfigMap returns a config map whose labels, annotations and data are built by
+// inserting keys in order, or in reverse order if reverse is set.
+func orderedConfigMap(reverse bool) *unstructured.Unstructured {
+	build := func(prefix string) map[string]interface{} {
+		m := map[string]interface{}{}
+		for i := 0; i < 20; i++ {
+			n := i
+			if reverse {
+				n = 19 - i
+			}
+			m[fmt.Sprintf("%s-%02d", prefix, n)] = fmt.Sprintf("value-%02d", n)
+		}
+		return m
+	}
+	metadata := map[string]interface{}{}
+	if reverse {
+		metadata["annotations"] = build("annotation")
+		metadata["labels"] = build("label")
+		metadata["namespace"] = "default"
+		metadata["name"] = "settings"
+	} else {
+		metadata["name"] = "settings"
+		metadata["namespace"] = "default"
+		metadata["labels"] = build("label")
+		metadata["annotations"] = build("annotation")
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   metadata,
+		"data":       build("key"),
+	}}
+}
+
+// TestStableOrdering checks that JSON and YAML print an object the same way however its
+// maps were built, so that exported manifests diff cleanly.
+func TestStableOrdering(t *testing.T) {
+	for _, output := range []string{"json", "yaml"} {
+		t.Run(output, func(t *testing.T) {
+			var printed []string
+			for _, reverse := range []bool{false, true, false, true} {
+				printer, err := PrinterFor(Flags{Output: output})
+				if err != nil {
+					t.Fatalf("PrinterFor() failed: %v", err)
+				}
+				var out bytes.Buffer
+				if err := printer.PrintObj(orderedConfigMap(reverse), &out); err != nil {
+					t.Fatalf("PrintObj() failed: %v", err)
+				}
+				printed = append(printed, out.String())
+			}
+			for i := 1; i < len(printed); i++ {
+				if printed[i] != printed[0] {
+					t.Fatalf("print %d differs from the first:\n%s\nwant:\n%s", i+1, printed[i], printed[0])
+				}
+			}
+		})
+	}
+}
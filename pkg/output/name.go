@@ -0,0 +1,65 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// NamePrinter prints the kind/name of each object, e.g. "pod/nginx" or "deployment.apps/nginx".
+type NamePrinter struct{}
+
+// PrintObj implements Printer.
+func (p *NamePrinter) PrintObj(obj runtime.Object, w io.Writer) error {
+	return eachObject(obj, func(obj runtime.Object) error {
+		name, err := QualifiedName(obj)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, name)
+		return err
+	})
+}
+
+// QualifiedName returns the kind/name form of obj, qualifying the kind with its API
+// group where it has one.
+func QualifiedName(obj runtime.Object) (string, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return "", err
+	}
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if gvk.Kind == "" {
+		return accessor.GetName(), nil
+	}
+	kind := strings.ToLower(gvk.Kind)
+	if gvk.Group != "" {
+		kind += "." + gvk.Group
+	}
+	return kind + "/" + accessor.GetName(), nil
+}
+
+// eachObject calls fn for obj, or for each item if obj is a list.
+func eachObject(obj runtime.Object, fn func(runtime.Object) error) error {
+	if meta.IsListType(obj) {
+		return meta.EachListItem(obj, fn)
+	}
+	return fn(obj)
+}
@@ -0,0 +1,111 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package output renders API objects in the output formats supported by commands.
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Printer prints objects to a writer.
+type Printer interface {
+	// PrintObj prints obj, which may be a single object or a list, to w.
+	PrintObj(obj runtime.Object, w io.Writer) error
+}
+
+// Flags configures the printer returned by PrinterFor.
+type Flags struct {
+	// Output is the output format as passed to --output, e.g. "yaml" or "jsonpath={.metadata.name}".
+	// An empty format selects the table printer.
+	Output string
+	// NoHeaders suppresses the header row of tables.
+	NoHeaders bool
+	// WithNamespace adds a namespace column to tables built by ObjectTable.
+	WithNamespace bool
+	// Table converts objects to tables. It defaults to ObjectTable.
+	Table TableFunc
+	// ServerTables prints Tables rendered by the server, falling back to Table for
+	// any other object.
+	ServerTables bool
+	// Transforms are applied to unstructured objects before they are printed.
+	Transforms []Transform
+	// TableStyle is the style of tables, one of TableStyleCompact or TableStyleBordered.
+	// It defaults to TableStyleCompact.
+	TableStyle string
+	// Width is the width of the terminal that bordered tables are fitted to, or 0 if
+	// output isn't to a terminal.
+	Width int
+}
+
+// PrinterFor returns the printer for the output format in flags.
+func PrinterFor(flags Flags) (Printer, error) {
+	format, arg := flags.Output, ""
+	if i := strings.Index(format, "="); i >= 0 {
+		format, arg = format[:i], format[i+1:]
+	}
+
+	switch flags.TableStyle {
+	case "", TableStyleCompact, TableStyleBordered:
+	default:
+		return nil, fmt.Errorf("unsupported table style %q: must be one of %s|%s", flags.TableStyle, TableStyleCompact, TableStyleBordered)
+	}
+
+	var (
+		printer Printer
+		err     error
+	)
+	switch format {
+	case "", "wide":
+		table := flags.Table
+		if table == nil {
+			table = ObjectTable(flags.WithNamespace)
+		}
+		if flags.ServerTables {
+			table = ServerTable(flags.WithNamespace, table)
+		}
+		printer = &TablePrinter{NoHeaders: flags.NoHeaders, Wide: format == "wide", Convert: table, Style: flags.TableStyle, Width: flags.Width}
+	case "custom-columns":
+		var table TableFunc
+		if table, err = CustomColumnsTable(arg); err == nil {
+			printer = &TablePrinter{NoHeaders: flags.NoHeaders, Convert: table, Style: flags.TableStyle, Width: flags.Width}
+		}
+	case "json":
+		printer = &JSONPrinter{}
+	case "yaml":
+		printer = &YAMLPrinter{}
+	case "name":
+		printer = &NamePrinter{}
+	case "jsonpath":
+		printer, err = NewJSONPathPrinter(arg)
+	case "jsonpath-as-json":
+		printer, err = NewJSONPathJSONPrinter(arg)
+	case "go-template":
+		printer, err = NewGoTemplatePrinter(arg)
+	default:
+		return nil, fmt.Errorf("unsupported output format %q: must be one of json|yaml|name|wide|custom-columns=...|jsonpath=...|jsonpath-as-json=...|go-template=...", flags.Output)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(flags.Transforms) > 0 {
+		printer = &transformingPrinter{delegate: printer, transforms: flags.Transforms}
+	}
+	return printer, nil
+}
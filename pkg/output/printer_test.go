@@ -0,0 +1,283 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// testDeployment returns a deployment named name in namespace. It has no creation
+// timestamp, so that its age prints the same whenever the tests are run.
+func testDeployment(namespace, name string, replicas int64) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": namespace,
+			"labels":    map[string]interface{}{"app": name},
+		},
+		"spec": map[string]interface{}{
+			"replicas": replicas,
+		},
+	}}
+}
+
+// testList returns a v1 List of objs.
+func testList(objs ...*unstructured.Unstructured) *unstructured.UnstructuredList {
+	list := &unstructured.UnstructuredList{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"metadata":   map[string]interface{}{},
+	}}
+	for _, obj := range objs {
+		list.Items = append(list.Items, *obj)
+	}
+	return list
+}
+
+func TestPrinterFor(t *testing.T) {
+	tests := []struct {
+		flags   Flags
+		want    string
+		wantErr bool
+	}{
+		{flags: Flags{}, want: "*output.TablePrinter"},
+		{flags: Flags{Output: "wide"}, want: "*output.TablePrinter"},
+		{flags: Flags{Output: "custom-columns=NAME:.metadata.name"}, want: "*output.TablePrinter"},
+		{flags: Flags{Output: "json"}, want: "*output.JSONPrinter"},
+		{flags: Flags{Output: "yaml"}, want: "*output.YAMLPrinter"},
+		{flags: Flags{Output: "name"}, want: "*output.NamePrinter"},
+		{flags: Flags{Output: "jsonpath={.metadata.name}"}, want: "*output.JSONPathPrinter"},
+		{flags: Flags{Output: "jsonpath-as-json={.metadata.name}"}, want: "*output.JSONPathPrinter"},
+		{flags: Flags{Output: "go-template={{.metadata.name}}"}, want: "*output.GoTemplatePrinter"},
+		{flags: Flags{Output: "json", Transforms: []Transform{RedactSecretData}}, want: "*output.transformingPrinter"},
+		{flags: Flags{TableStyle: TableStyleBordered}, want: "*output.TablePrinter"},
+
+		{flags: Flags{Output: "xml"}, wantErr: true},
+		{flags: Flags{Output: "jsonpath="}, wantErr: true},
+		{flags: Flags{Output: "jsonpath={.metadata.name"}, wantErr: true},
+		{flags: Flags{Output: "go-template="}, wantErr: true},
+		{flags: Flags{Output: "go-template={{.metadata.name"}, wantErr: true},
+		{flags: Flags{Output: "custom-columns="}, wantErr: true},
+		{flags: Flags{Output: "custom-columns=NAME"}, wantErr: true},
+		{flags: Flags{TableStyle: "fancy"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%+v", tt.flags), func(t *testing.T) {
+			printer, err := PrinterFor(tt.flags)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("PrinterFor() = %T, want an error", printer)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PrinterFor() failed: %v", err)
+			}
+			if got := fmt.Sprintf("%T", printer); got != tt.want {
+				t.Errorf("PrinterFor() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrinterOutput(t *testing.T) {
+	web := testDeployment("default", "web", 2)
+	list := testList(web, testDeployment("shop", "cart", 1))
+
+	tests := []struct {
+		name  string
+		flags Flags
+		obj   runtime.Object
+		want  string
+	}{
+		{
+			name: "table",
+			obj:  web,
+			want: "NAME  AGE\n" +
+				"web   <unknown>\n",
+		},
+		{
+			name:  "table with namespace and no headers",
+			flags: Flags{WithNamespace: true, NoHeaders: true},
+			obj:   list,
+			want: "default  web   <unknown>\n" +
+				"shop     cart  <unknown>\n",
+		},
+		{
+			name:  "bordered table",
+			flags: Flags{TableStyle: TableStyleBordered},
+			obj:   list,
+			want: "NAME | AGE\n" +
+				"-----+----------\n" +
+				"web  | <unknown>\n" +
+				"cart | <unknown>\n",
+		},
+		{
+			name:  "custom columns",
+			flags: Flags{Output: "custom-columns=NAME:.metadata.name,REPLICAS:.spec.replicas,READY:.status.readyReplicas"},
+			obj:   list,
+			want: "NAME  REPLICAS  READY\n" +
+				"web   2         <none>\n" +
+				"cart  1         <none>\n",
+		},
+		{
+			name:  "json",
+			flags: Flags{Output: "json"},
+			obj:   web,
+			want: `{
+    "apiVersion": "apps/v1",
+    "kind": "Deployment",
+    "metadata": {
+        "labels": {
+            "app": "web"
+        },
+        "name": "web",
+        "namespace": "default"
+    },
+    "spec": {
+        "replicas": 2
+    }
+}
+`,
+		},
+		{
+			name:  "yaml",
+			flags: Flags{Output: "yaml"},
+			obj:   web,
+			want: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    app: web
+  name: web
+  namespace: default
+spec:
+  replicas: 2
+`,
+		},
+		{
+			name:  "name",
+			flags: Flags{Output: "name"},
+			obj:   list,
+			want: "deployment.apps/web\n" +
+				"deployment.apps/cart\n",
+		},
+		{
+			name:  "jsonpath",
+			flags: Flags{Output: "jsonpath={.items[*].metadata.name}"},
+			obj:   list,
+			want:  "web cart",
+		},
+		{
+			name:  "jsonpath as json",
+			flags: Flags{Output: "jsonpath-as-json={.items[*].metadata.name}"},
+			obj:   list,
+			want:  "[\n    \"web\",\n    \"cart\"\n]\n",
+		},
+		{
+			name:  "jsonpath as json of a single value",
+			flags: Flags{Output: "jsonpath-as-json={.spec.replicas}"},
+			obj:   web,
+			want:  "2\n",
+		},
+		{
+			name:  "go-template",
+			flags: Flags{Output: `go-template={{range .items}}{{.metadata.namespace}}/{{.metadata.name}}{{"\n"}}{{end}}`},
+			obj:   list,
+			want: "default/web\n" +
+				"shop/cart\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			printer, err := PrinterFor(tt.flags)
+			if err != nil {
+				t.Fatalf("PrinterFor() failed: %v", err)
+			}
+			var out bytes.Buffer
+			if err := printer.PrintObj(tt.obj, &out); err != nil {
+				t.Fatalf("PrintObj() failed: %v", err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("PrintObj() printed:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTablePrinterPrintsHeadersOnce(t *testing.T) {
+	printer, err := PrinterFor(Flags{})
+	if err != nil {
+		t.Fatalf("PrinterFor() failed: %v", err)
+	}
+	var out bytes.Buffer
+	for _, obj := range []runtime.Object{testDeployment("default", "web", 1), testDeployment("default", "api", 1)} {
+		if err := printer.PrintObj(obj, &out); err != nil {
+			t.Fatalf("PrintObj() failed: %v", err)
+		}
+	}
+	// Each call is aligned on its own, as the rows of later calls aren't known yet.
+	want := "NAME  AGE\n" +
+		"web   <unknown>\n" +
+		"api  <unknown>\n"
+	if got := out.String(); got != want {
+		t.Errorf("PrintObj() printed:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestYAMLPrinterSeparatesDocuments(t *testing.T) {
+	printer := &YAMLPrinter{}
+	var out bytes.Buffer
+	for _, name := range []string{"web", "api"} {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{"kind": "Deployment", "metadata": map[string]interface{}{"name": name}}}
+		if err := printer.PrintObj(obj, &out); err != nil {
+			t.Fatalf("PrintObj() failed: %v", err)
+		}
+	}
+	want := "kind: Deployment\nmetadata:\n  name: web\n---\nkind: Deployment\nmetadata:\n  name: api\n"
+	if got := out.String(); got != want {
+		t.Errorf("PrintObj() printed:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRedactingPrinter(t *testing.T) {
+	secret := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": "creds"},
+		"data":       map[string]interface{}{"password": "aHVudGVyMg=="},
+	}}
+	printer, err := PrinterFor(Flags{Output: "jsonpath={.data.password}", Transforms: []Transform{RedactSecretData}})
+	if err != nil {
+		t.Fatalf("PrinterFor() failed: %v", err)
+	}
+	var out bytes.Buffer
+	if err := printer.PrintObj(secret, &out); err != nil {
+		t.Fatalf("PrintObj() failed: %v", err)
+	}
+	if got := out.String(); got != RedactedValue {
+		t.Errorf("PrintObj() printed %q, want %q", got, RedactedValue)
+	}
+	if password := secret.Object["data"].(map[string]interface{})["password"]; password != "aHVudGVyMg==" {
+		t.Errorf("printing modified the secret, its password is now %v", password)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ServerTableAccept is the Accept header requesting that the server render objects as a
+// Table, preferring the beta version, and falling back to the objects themselves if the
+// server can't.
+const ServerTableAccept = "application/json;as=Table;v=v1beta1;g=meta.k8s.io,application/json;as=Table;v=v1alpha1;g=meta.k8s.io,application/json"
+
+// ServerTable returns a TableFunc printing Tables rendered by the server, or lists of
+// them, and converting any other object with fallback.
+func ServerTable(withNamespace bool, fallback TableFunc) TableFunc {
+	return func(obj runtime.Object, wide bool) (*Table, error) {
+		switch t := obj.(type) {
+		case *unstructured.Unstructured:
+			if IsServerTable(t) {
+				return fromServerTable(t, wide, withNamespace)
+			}
+		case *unstructured.UnstructuredList:
+			if len(t.Items) == 0 || !IsServerTable(&t.Items[0]) {
+				break
+			}
+			merged := &Table{}
+			for i := range t.Items {
+				table, err := fromServerTable(&t.Items[i], wide, withNamespace)
+				if err != nil {
+					return nil, err
+				}
+				merged.Columns = table.Columns
+				merged.Rows = append(merged.Rows, table.Rows...)
+			}
+			return merged, nil
+		}
+		return fallback(obj, wide)
+	}
+}
+
+// IsServerTable returns whether obj is a Table rendered by the server.
+func IsServerTable(obj *unstructured.Unstructured) bool {
+	gvk := obj.GroupVersionKind()
+	return gvk.Group == "meta.k8s.io" && gvk.Kind == "Table"
+}
+
+// fromServerTable converts a server Table to a Table. Columns with a non-zero priority are
+// only included in wide output, as with kubectl.
+func fromServerTable(obj *unstructured.Unstructured, wide, withNamespace bool) (*Table, error) {
+	columns, _, err := unstructured.NestedSlice(obj.Object, "columnDefinitions")
+	if err != nil {
+		return nil, err
+	}
+	rows, _, err := unstructured.NestedSlice(obj.Object, "rows")
+	if err != nil {
+		return nil, err
+	}
+
+	table := &Table{}
+	if withNamespace {
+		table.Columns = append(table.Columns, "NAMESPACE")
+	}
+	var include []bool
+	for _, c := range columns {
+		column, ok := c.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected table column %v", c)
+		}
+		priority, _, _ := unstructured.NestedInt64(column, "priority")
+		include = append(include, wide || priority == 0)
+		if wide || priority == 0 {
+			name, _, _ := unstructured.NestedString(column, "name")
+			table.Columns = append(table.Columns, strings.ToUpper(name))
+		}
+	}
+
+	for _, r := range rows {
+		row, ok := r.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected table row %v", r)
+		}
+		cells, _, err := unstructured.NestedSlice(row, "cells")
+		if err != nil {
+			return nil, err
+		}
+		var printed []string
+		if withNamespace {
+			ns, _, _ := unstructured.NestedString(row, "object", "metadata", "namespace")
+			printed = append(printed, ns)
+		}
+		for i, cell := range cells {
+			if i < len(include) && include[i] {
+				printed = append(printed, formatCell(cell))
+			}
+		}
+		table.Rows = append(table.Rows, printed)
+	}
+	return table, nil
+}
+
+func formatCell(cell interface{}) string {
+	if cell == nil {
+		return "<none>"
+	}
+	return fmt.Sprint(cell)
+}
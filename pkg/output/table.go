@@ -0,0 +1,247 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Table is a tabular view of objects. Commands that print something other than API
+// objects, such as discovery or metrics results, build a Table and print it directly.
+type Table struct {
+	Columns []string
+	Rows    [][]string
+}
+
+// GetObjectKind implements runtime.Object.
+func (t *Table) GetObjectKind() schema.ObjectKind {
+	return schema.EmptyObjectKind
+}
+
+// DeepCopyObject implements runtime.Object.
+func (t *Table) DeepCopyObject() runtime.Object {
+	out := &Table{Columns: append([]string(nil), t.Columns...)}
+	for _, row := range t.Rows {
+		out.Rows = append(out.Rows, append([]string(nil), row...))
+	}
+	return out
+}
+
+// TableFunc converts an object, or a list of objects, to a table. Wide is set when the
+// wide output format was requested.
+type TableFunc func(obj runtime.Object, wide bool) (*Table, error)
+
+// ObjectTable returns a TableFunc listing the name and age of each object, preceded by
+// its namespace if withNamespace is set.
+func ObjectTable(withNamespace bool) TableFunc {
+	return func(obj runtime.Object, wide bool) (*Table, error) {
+		table := &Table{Columns: []string{"NAME", "AGE"}}
+		if withNamespace {
+			table.Columns = append([]string{"NAMESPACE"}, table.Columns...)
+		}
+		err := eachObject(obj, func(obj runtime.Object) error {
+			accessor, err := meta.Accessor(obj)
+			if err != nil {
+				return err
+			}
+			row := []string{accessor.GetName(), TranslateTimestamp(accessor.GetCreationTimestamp())}
+			if withNamespace {
+				row = append([]string{accessor.GetNamespace()}, row...)
+			}
+			table.Rows = append(table.Rows, row)
+			return nil
+		})
+		return table, err
+	}
+}
+
+// Table styles selectable with Flags.TableStyle.
+const (
+	// TableStyleCompact separates columns with spaces only.
+	TableStyleCompact = "compact"
+	// TableStyleBordered separates columns with lines, and the header from the rows.
+	TableStyleBordered = "bordered"
+)
+
+// minColumnWidth is the narrowest a bordered column is truncated to when fitting a table
+// to the terminal.
+const minColumnWidth = 8
+
+// TablePrinter prints objects as aligned columns. The header row is printed once, before
+// the first printed rows, so that the same printer can be used for a stream of objects.
+type TablePrinter struct {
+	NoHeaders bool
+	Wide      bool
+	// Convert converts objects that are not already a *Table.
+	Convert TableFunc
+	// Style is one of the table styles, defaulting to TableStyleCompact.
+	Style string
+	// Width is the width bordered tables are fitted to by truncating their widest
+	// columns, or 0 to never truncate.
+	Width int
+
+	printedHeaders bool
+}
+
+// PrintObj implements Printer.
+func (p *TablePrinter) PrintObj(obj runtime.Object, w io.Writer) error {
+	table, ok := obj.(*Table)
+	if !ok {
+		if p.Convert == nil {
+			return errors.New("no table conversion available for object")
+		}
+		var err error
+		if table, err = p.Convert(obj, p.Wide); err != nil {
+			return err
+		}
+	}
+
+	if p.Style == TableStyleBordered {
+		return p.printBordered(table, w)
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	if !p.NoHeaders && !p.printedHeaders {
+		fmt.Fprintln(tw, strings.Join(table.Columns, "\t"))
+		p.printedHeaders = true
+	}
+	for _, row := range table.Rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// printBordered prints table with "|" between columns and a line under the header.
+func (p *TablePrinter) printBordered(table *Table, w io.Writer) error {
+	printHeaders := !p.NoHeaders && !p.printedHeaders
+	widths := make([]int, len(table.Columns))
+	measure := func(row []string) {
+		for i, cell := range row {
+			if i < len(widths) && utf8.RuneCountInString(cell) > widths[i] {
+				widths[i] = utf8.RuneCountInString(cell)
+			}
+		}
+	}
+	if printHeaders {
+		measure(table.Columns)
+	}
+	for _, row := range table.Rows {
+		measure(row)
+	}
+	fitWidths(widths, p.Width)
+
+	format := func(row []string) string {
+		cells := make([]string, len(widths))
+		for i, width := range widths {
+			var cell string
+			if i < len(row) {
+				cell = truncate(row[i], width)
+			}
+			cells[i] = cell + strings.Repeat(" ", width-utf8.RuneCountInString(cell))
+		}
+		return strings.TrimRight(strings.Join(cells, " | "), " ")
+	}
+
+	var b bytes.Buffer
+	if printHeaders {
+		b.WriteString(format(table.Columns) + "\n")
+		var lines []string
+		for _, width := range widths {
+			lines = append(lines, strings.Repeat("-", width))
+		}
+		b.WriteString(strings.Join(lines, "-+-") + "\n")
+		p.printedHeaders = true
+	}
+	for _, row := range table.Rows {
+		b.WriteString(format(row) + "\n")
+	}
+	_, err := b.WriteTo(w)
+	return err
+}
+
+// fitWidths narrows the widest of the column widths until the row, with its " | "
+// separators, fits in total, without narrowing any column below minColumnWidth. A total
+// of 0 leaves the widths unchanged.
+func fitWidths(widths []int, total int) {
+	if total <= 0 {
+		return
+	}
+	for {
+		sum := 3 * (len(widths) - 1)
+		widest := 0
+		for i, width := range widths {
+			sum += width
+			if width > widths[widest] {
+				widest = i
+			}
+		}
+		if sum <= total || widths[widest] <= minColumnWidth {
+			return
+		}
+		widths[widest] -= sum - total
+		if widths[widest] < minColumnWidth {
+			widths[widest] = minColumnWidth
+		}
+	}
+}
+
+// truncate shortens s to width runes, marking that it was truncated with an ellipsis.
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// TranslateTimestamp returns the elapsed time since timestamp in human-readable
+// approximate format, as used in AGE columns.
+func TranslateTimestamp(timestamp metav1.Time) string {
+	if timestamp.IsZero() {
+		return "<unknown>"
+	}
+	return ShortHumanDuration(time.Since(timestamp.Time))
+}
+
+// ShortHumanDuration formats d using its largest unit, e.g. "5m" or "3d".
+func ShortHumanDuration(d time.Duration) string {
+	// Allow deviation no more than 2 seconds (excluded) to tolerate machine time
+	// inconsistency, it can be considered as almost now.
+	if seconds := int(d.Seconds()); seconds < -1 {
+		return "<invalid>"
+	} else if seconds < 0 {
+		return "0s"
+	} else if seconds < 60 {
+		return fmt.Sprintf("%ds", seconds)
+	} else if minutes := int(d.Minutes()); minutes < 60 {
+		return fmt.Sprintf("%dm", minutes)
+	} else if hours := int(d.Hours()); hours < 24 {
+		return fmt.Sprintf("%dh", hours)
+	} else if hours < 24*365 {
+		return fmt.Sprintf("%dd", hours/24)
+	}
+	return fmt.Sprintf("%dy", int(d.Hours()/24/365))
+}
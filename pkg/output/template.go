@@ -0,0 +1,131 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// JSONPathPrinter prints the result of a JSONPath template applied to each printed object.
+type JSONPathPrinter struct {
+	jsonPath *jsonpath.JSONPath
+	// asJSON prints the values selected by the template as JSON, rather than as text.
+	asJSON bool
+}
+
+// NewJSONPathPrinter returns a printer for the JSONPath template tmpl, e.g. "{.metadata.name}".
+func NewJSONPathPrinter(tmpl string) (*JSONPathPrinter, error) {
+	if tmpl == "" {
+		return nil, errors.New("template format specified but no template given")
+	}
+	j := jsonpath.New("output")
+	if err := j.Parse(tmpl); err != nil {
+		return nil, fmt.Errorf("error parsing jsonpath %s: %v", tmpl, err)
+	}
+	return &JSONPathPrinter{jsonPath: j}, nil
+}
+
+// AllowMissingKeys sets whether fields missing from printed objects print as empty,
+// rather than failing to print. It returns p.
+func (p *JSONPathPrinter) AllowMissingKeys(allow bool) *JSONPathPrinter {
+	p.jsonPath.AllowMissingKeys(allow)
+	return p
+}
+
+// NewJSONPathJSONPrinter returns a printer for the JSONPath template tmpl that prints the
+// selected values as JSON: a single value as is, and several values as an array.
+func NewJSONPathJSONPrinter(tmpl string) (*JSONPathPrinter, error) {
+	p, err := NewJSONPathPrinter(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	p.asJSON = true
+	return p, nil
+}
+
+// PrintObj implements Printer.
+func (p *JSONPathPrinter) PrintObj(obj runtime.Object, w io.Writer) error {
+	data, err := toGeneric(obj)
+	if err != nil {
+		return err
+	}
+	if p.asJSON {
+		return p.printJSON(data, w)
+	}
+	if err := p.jsonPath.Execute(w, data); err != nil {
+		return fmt.Errorf("error executing jsonpath: %v", err)
+	}
+	return nil
+}
+
+func (p *JSONPathPrinter) printJSON(data interface{}, w io.Writer) error {
+	results, err := p.jsonPath.FindResults(data)
+	if err != nil {
+		return fmt.Errorf("error executing jsonpath: %v", err)
+	}
+	var values []interface{}
+	for _, result := range results {
+		for _, v := range result {
+			values = append(values, v.Interface())
+		}
+	}
+
+	var out interface{} = values
+	if len(values) == 1 {
+		out = values[0]
+	}
+	encoded, err := json.MarshalIndent(out, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", encoded)
+	return err
+}
+
+// GoTemplatePrinter prints the result of a Go template applied to each printed object.
+type GoTemplatePrinter struct {
+	template *template.Template
+}
+
+// NewGoTemplatePrinter returns a printer for the Go template tmpl, e.g. "{{.metadata.name}}".
+func NewGoTemplatePrinter(tmpl string) (*GoTemplatePrinter, error) {
+	if tmpl == "" {
+		return nil, errors.New("template format specified but no template given")
+	}
+	t, err := template.New("output").Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing template %s: %v", tmpl, err)
+	}
+	return &GoTemplatePrinter{template: t.Option("missingkey=error")}, nil
+}
+
+// PrintObj implements Printer.
+func (p *GoTemplatePrinter) PrintObj(obj runtime.Object, w io.Writer) error {
+	data, err := toGeneric(obj)
+	if err != nil {
+		return err
+	}
+	if err := p.template.Execute(w, data); err != nil {
+		return fmt.Errorf("error executing template: %v", err)
+	}
+	return nil
+}
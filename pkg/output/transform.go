@@ -0,0 +1,125 @@
+// Copyright © 2018 Jimmi Dyson <jimmidyson@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"io"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// RedactedValue replaces redacted values in printed objects.
+const RedactedValue = "REDACTED"
+
+// Transform modifies an object before it is printed. Transforms must not mutate the
+// object they are passed: they return either it unchanged or a modified copy.
+type Transform func(obj *unstructured.Unstructured) *unstructured.Unstructured
+
+// RedactSecretData replaces the values of a Secret's data and stringData with
+// RedactedValue, keeping the keys so the shape of the Secret is still visible.
+func RedactSecretData(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	if obj.GroupVersionKind().GroupKind() != (schema.GroupKind{Kind: "Secret"}) {
+		return obj
+	}
+
+	redacted := obj.DeepCopy()
+	for _, field := range []string{"data", "stringData"} {
+		values, found, err := unstructured.NestedMap(redacted.Object, field)
+		if err != nil || !found {
+			continue
+		}
+		for k := range values {
+			values[k] = RedactedValue
+		}
+		_ = unstructured.SetNestedMap(redacted.Object, values, field)
+	}
+	return redacted
+}
+
+// DefaultCleanFields are the fields removed by get --clean: those set by the API server,
+// which would make exported manifests diff noisily or fail to be applied again.
+var DefaultCleanFields = []string{
+	"status",
+	"metadata.managedFields",
+	"metadata.resourceVersion",
+	"metadata.uid",
+	"metadata.selfLink",
+	"metadata.creationTimestamp",
+	"metadata.generation",
+}
+
+// RemoveFields returns a Transform removing the fields at paths from objects. Paths are
+// dot separated, e.g. "metadata.uid".
+func RemoveFields(paths []string) Transform {
+	var fields [][]string
+	for _, path := range paths {
+		fields = append(fields, strings.Split(path, "."))
+	}
+	return func(obj *unstructured.Unstructured) *unstructured.Unstructured {
+		cleaned := obj.DeepCopy()
+		for _, f := range fields {
+			unstructured.RemoveNestedField(cleaned.Object, f...)
+		}
+		return cleaned
+	}
+}
+
+// transformingPrinter applies transforms to unstructured objects, and the items of
+// unstructured lists, before delegating to another printer.
+type transformingPrinter struct {
+	delegate   Printer
+	transforms []Transform
+}
+
+func (p *transformingPrinter) PrintObj(obj runtime.Object, w io.Writer) error {
+	switch t := obj.(type) {
+	case *unstructured.Unstructured:
+		obj = p.transform(t)
+	case *unstructured.UnstructuredList:
+		list := &unstructured.UnstructuredList{Object: t.Object}
+		for i := range t.Items {
+			list.Items = append(list.Items, *p.transform(&t.Items[i]))
+		}
+		obj = list
+	}
+	return p.delegate.PrintObj(obj, w)
+}
+
+func (p *transformingPrinter) transform(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	for _, t := range p.transforms {
+		obj = t(obj)
+	}
+	return obj
+}
+
+// StatusOnly reduces an object to its status, keeping only the type and the identifying
+// metadata needed to tell objects apart.
+func StatusOnly(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	reduced := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	reduced.SetAPIVersion(obj.GetAPIVersion())
+	reduced.SetKind(obj.GetKind())
+	reduced.SetName(obj.GetName())
+	if ns := obj.GetNamespace(); ns != "" {
+		reduced.SetNamespace(ns)
+	}
+	reduced.SetCreationTimestamp(obj.GetCreationTimestamp())
+	if status, found, err := unstructured.NestedFieldCopy(obj.Object, "status"); err == nil && found {
+		_ = unstructured.SetNestedField(reduced.Object, status, "status")
+	}
+	return reduced
+}